@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/zanz1n/blog/internal/keyring"
+)
+
+// Manages a directory keyring, as loaded by the server with JWT_KEYS_DIR.
+//
+//	keyring generate <dir>
+//	keyring promote <dir> <kid>
+//	keyring retire <dir> <kid>
+//	keyring list <dir>
+func keyringCmd(cmd, dir, kid string) {
+	if dir == "" {
+		fmt.Printf("A keyring directory must be provided\n")
+		os.Exit(1)
+	}
+
+	var err error
+	switch cmd {
+	case "generate":
+		var key keyring.Key
+		key, err = keyring.GenerateKey()
+		if err == nil {
+			err = keyring.WriteKey(dir, key)
+		}
+		if err == nil {
+			fmt.Println(key.ID)
+		}
+
+	case "promote":
+		err = keyring.Promote(dir, kid)
+
+	case "retire":
+		err = keyring.Retire(dir, kid)
+
+	case "list":
+		var keys *keyring.Keyring
+		keys, err = keyring.LoadDir(dir)
+		if err == nil {
+			current := keys.Current().ID
+			for _, key := range keys.Keys() {
+				if key.ID == current {
+					fmt.Printf("%s (current)\n", key.ID)
+				} else {
+					fmt.Println(key.ID)
+				}
+			}
+		}
+
+	default:
+		invalidArg("keyring " + cmd)
+	}
+
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+}
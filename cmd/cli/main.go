@@ -19,6 +19,9 @@ func main() {
 	if arg == "export-routes" {
 		exportRoutes()
 		return
+	} else if arg == "keyring" {
+		keyringCmd(flag.Arg(1), flag.Arg(2), flag.Arg(3))
+		return
 	} else {
 		invalidArg(arg)
 		return
@@ -36,7 +39,7 @@ func invalidArg(arg string) {
 
 func exportRoutes() {
 	router := &RoutesMockup{}
-	server.New(nil, nil, nil).Wire(router)
+	server.New(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, server.Limits{}, nil, nil).Wire(router)
 
 	arr := make([]string, len(router.Inner))
 
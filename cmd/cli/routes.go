@@ -73,9 +73,16 @@ func (r *RoutesMockup) MethodFunc(method string, pattern string, h http.HandlerF
 	r.add(method, pattern)
 }
 
+// Group implements chi.Router.
+func (r *RoutesMockup) Group(fn func(r chi.Router)) chi.Router {
+	if fn != nil {
+		fn(r)
+	}
+	return r
+}
+
 func (r *RoutesMockup) Connect(pattern string, h http.HandlerFunc)                     {}
 func (r *RoutesMockup) Find(rctx *chi.Context, method string, path string) string      { return "" }
-func (r *RoutesMockup) Group(fn func(r chi.Router)) chi.Router                         { return r }
 func (r *RoutesMockup) Handle(pattern string, h http.Handler)                          {}
 func (r *RoutesMockup) HandleFunc(pattern string, h http.HandlerFunc)                  {}
 func (r *RoutesMockup) Match(rctx *chi.Context, method string, path string) bool       { return false }
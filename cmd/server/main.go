@@ -14,6 +14,7 @@ import (
 	"github.com/go-chi/cors"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/zanz1n/blog/config"
+	"github.com/zanz1n/blog/internal/mailer"
 	"github.com/zanz1n/blog/internal/repository"
 	"github.com/zanz1n/blog/internal/server"
 )
@@ -24,7 +25,12 @@ var (
 		false,
 		"enables or disables json logging",
 	)
-	debugLogs = flag.Bool("debug", false, "enables debug logs")
+	debugLogs  = flag.Bool("debug", false, "enables debug logs")
+	grantAdmin = flag.String(
+		"grant-admin",
+		"",
+		"grants the admin permission to the user with the given email and exits",
+	)
 )
 
 var interrupt = make(chan os.Signal, 1)
@@ -91,12 +97,52 @@ func main2(ctx context.Context) error {
 	articlesRepo := repository.NewArticleRepository(db)
 	defer articlesRepo.Close()
 
-	jwtPub, jwtPriv, err := jwtKeyPair()
+	tagsRepo := repository.NewTagRepository(db)
+	defer tagsRepo.Close()
+
+	commentsRepo := repository.NewCommentRepository(db)
+	defer commentsRepo.Close()
+
+	tokensRepo := repository.NewAccessTokenRepository(db)
+	defer tokensRepo.Close()
+
+	totpRepo := repository.NewTOTPRepository(db)
+	defer totpRepo.Close()
+
+	auditRepo := repository.NewAuditRepository(db)
+	defer auditRepo.Close()
+
+	jwtKeys, err := jwtKeyring()
 	if err != nil {
 		return err
 	}
 
-	authRepo := repository.NewAuthRepository(jwtPriv, jwtPub, "SRV", kv)
+	authRepo := repository.NewAuthRepository(jwtKeys, cfg.JWT.Issuer, kv)
+
+	if *grantAdmin != "" {
+		return grantAdminPermission(ctx, userRepo, auditRepo, authRepo, *grantAdmin)
+	}
+
+	wa, err := webAuthn(cfg)
+	if err != nil {
+		return err
+	}
+
+	passkeyRepo := repository.NewPasskeyRepository(db, wa, kv)
+	defer passkeyRepo.Close()
+
+	identityRepo := repository.NewIdentityRepository(db)
+	defer identityRepo.Close()
+
+	provider, err := oidcProvider(ctx, cfg, kv)
+	if err != nil {
+		return err
+	}
+
+	mail, err := mailer.New(cfg.Mail.URL, cfg.Mail.From)
+	if err != nil {
+		return err
+	}
 
 	r := chi.NewRouter()
 	r.Use(cors.Handler(cors.Options{
@@ -118,7 +164,22 @@ func main2(ctx context.Context) error {
 		return err
 	}
 
-	s := server.New(userRepo, authRepo, cfg)
+	s := server.New(
+		userRepo,
+		articlesRepo,
+		tagsRepo,
+		commentsRepo,
+		tokensRepo,
+		totpRepo,
+		passkeyRepo,
+		authRepo,
+		identityRepo,
+		auditRepo,
+		provider,
+		server.NewLimits(kv, cfg.RateLimit),
+		mail,
+		cfg,
+	)
 
 	r.NotFound(s.NotFoundHandler())
 	s.Wire(r)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/x509"
@@ -8,14 +9,145 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"slices"
 	"strings"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/zanz1n/blog/config"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/keyring"
+	"github.com/zanz1n/blog/internal/kv"
+	"github.com/zanz1n/blog/internal/oidc"
+	"github.com/zanz1n/blog/internal/repository"
 	"github.com/zanz1n/blog/internal/utils/errutils"
+	"github.com/zanz1n/blog/web/templates"
 )
 
+// Configures the webauthn relying party, which defaults to the
+// public url of the server.
+func webAuthn(cfg *config.Config) (*webauthn.WebAuthn, error) {
+	rpId := cfg.WebAuthn.RPID
+	if rpId == "" {
+		u, err := url.Parse(cfg.PublicURL)
+		if err != nil {
+			return nil, fmt.Errorf("webauthn: parse public url: %w", err)
+		}
+		rpId = u.Hostname()
+	}
+
+	origins := cfg.WebAuthn.Origins
+	if len(origins) == 0 {
+		origins = []string{cfg.PublicURL}
+	}
+
+	timeout := webauthn.TimeoutConfig{
+		Enforce:    true,
+		Timeout:    repository.PasskeyChallengeExpiry,
+		TimeoutUVD: repository.PasskeyChallengeExpiry,
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpId,
+		RPDisplayName: cfg.WebAuthn.RPName,
+		RPOrigins:     origins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+}
+
+// Discovers the configured identity provider, returning nil if
+// signing in with one is disabled.
+func oidcProvider(
+	ctx context.Context,
+	cfg *config.Config,
+	kv kv.KVStorer,
+) (*oidc.Provider, error) {
+	if cfg.OIDC.Issuer == "" {
+		return nil, nil
+	}
+
+	provider, err := oidc.New(ctx, oidc.Config{
+		Issuer:       cfg.OIDC.Issuer,
+		ClientID:     cfg.OIDC.ClientID,
+		ClientSecret: cfg.OIDC.ClientSecret,
+		RedirectURL:  cfg.PublicURL + "/auth/oidc/callback",
+		Scopes:       cfg.OIDC.Scopes,
+	}, kv)
+	if err != nil {
+		return nil, err
+	}
+
+	templates.SetOIDCProvider(cfg.OIDC.Name)
+	return provider, nil
+}
+
+// Grants the admin permission to the user, so that it can manage the
+// other users from the server.
+func grantAdminPermission(
+	ctx context.Context,
+	users *repository.UserRepository,
+	audits *repository.AuditRepository,
+	auth *repository.AuthRepository,
+	email string,
+) error {
+	user, err := users.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	updated, err := users.UpdatePermission(
+		ctx,
+		user.ID,
+		user.Permission|dto.PermissionAdmin,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = auth.RevokeUserTokens(ctx, user.ID); err != nil {
+		return err
+	}
+
+	entry, err := dto.NewAuditEntry(nil, dto.AuditUserPermission, user.ID, dto.AuditPermissionData{
+		Old: user.Permission,
+		New: updated.Permission,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = audits.Create(ctx, entry); err != nil {
+		return err
+	}
+
+	fmt.Printf("Granted the admin permission to %s\n", user.Email)
+	return nil
+}
+
+func jwtKeyring() (*keyring.Keyring, error) {
+	cfg, err := config.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.JWT.KeysDir != "" {
+		return keyring.LoadDir(cfg.JWT.KeysDir)
+	} else if len(cfg.JWT.Keys) != 0 {
+		return keyring.LoadEnv(cfg.JWT.Keys, cfg.JWT.CurrentKey)
+	}
+
+	_, priv, err := jwtKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	return keyring.Single(priv), nil
+}
+
 func jwtKeyPair() (ed25519.PublicKey, ed25519.PrivateKey, error) {
 	cfg, err := config.Get()
 	if err != nil {
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+type Op string
+
+const (
+	OpEqual  Op = "equal"
+	OpInsert Op = "insert"
+	OpDelete Op = "delete"
+)
+
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+	// Line number in the old text, zero if inserted
+	Old int `json:"old,omitempty"`
+	// Line number in the new text, zero if deleted
+	New int `json:"new,omitempty"`
+}
+
+// Computes the line by line difference between two texts, replaced
+// lines being reported as deleted and then inserted.
+func Lines(a, b string) []Line {
+	la, lb := splitLines(a), splitLines(b)
+
+	// Autojunk would treat frequent lines, like empty ones, as junk
+	matcher := difflib.NewMatcherWithJunk(la, lb, false, nil)
+
+	lines := []Line{}
+	for _, op := range matcher.GetOpCodes() {
+		if op.Tag == 'e' {
+			for i := op.I1; i < op.I2; i++ {
+				lines = append(lines, Line{
+					Op:   OpEqual,
+					Text: la[i],
+					Old:  i + 1,
+					New:  op.J1 + (i - op.I1) + 1,
+				})
+			}
+			continue
+		}
+
+		if op.Tag == 'r' || op.Tag == 'd' {
+			for i := op.I1; i < op.I2; i++ {
+				lines = append(lines, Line{Op: OpDelete, Text: la[i], Old: i + 1})
+			}
+		}
+		if op.Tag == 'r' || op.Tag == 'i' {
+			for j := op.J1; j < op.J2; j++ {
+				lines = append(lines, Line{Op: OpInsert, Text: lb[j], New: j + 1})
+			}
+		}
+	}
+
+	return lines
+}
+
+// Reports whether any line differs.
+func Changed(lines []Line) bool {
+	for _, line := range lines {
+		if line.Op != OpEqual {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,70 @@
+package diff_test
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/diff"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []diff.Line
+	}{
+		{
+			name: "Empty",
+			want: []diff.Line{},
+		},
+		{
+			name: "Equal",
+			a:    "a\nb\n",
+			b:    "a\r\nb",
+			want: []diff.Line{
+				{Op: diff.OpEqual, Text: "a", Old: 1, New: 1},
+				{Op: diff.OpEqual, Text: "b", Old: 2, New: 2},
+			},
+		},
+		{
+			name: "Insert",
+			a:    "a\nc",
+			b:    "a\nb\nc",
+			want: []diff.Line{
+				{Op: diff.OpEqual, Text: "a", Old: 1, New: 1},
+				{Op: diff.OpInsert, Text: "b", New: 2},
+				{Op: diff.OpEqual, Text: "c", Old: 2, New: 3},
+			},
+		},
+		{
+			name: "Delete",
+			a:    "a\nb\nc",
+			b:    "a\nc",
+			want: []diff.Line{
+				{Op: diff.OpEqual, Text: "a", Old: 1, New: 1},
+				{Op: diff.OpDelete, Text: "b", Old: 2},
+				{Op: diff.OpEqual, Text: "c", Old: 3, New: 2},
+			},
+		},
+		{
+			name: "Replace",
+			a:    "a\nb\n\nc",
+			b:    "a\nd\n\nc",
+			want: []diff.Line{
+				{Op: diff.OpEqual, Text: "a", Old: 1, New: 1},
+				{Op: diff.OpDelete, Text: "b", Old: 2},
+				{Op: diff.OpInsert, Text: "d", New: 2},
+				{Op: diff.OpEqual, Text: "", Old: 3, New: 3},
+				{Op: diff.OpEqual, Text: "c", Old: 4, New: 4},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := diff.Lines(test.a, test.b)
+			assert.Equal(t, test.want, lines)
+			assert.Equal(t, test.name != "Empty" && test.name != "Equal", diff.Changed(lines))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package dto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// Prefix of personal access tokens, telling them apart
+// from jwts when sent as bearer credentials.
+const AccessTokenPrefix = "blog_pat_"
+
+// Number of random bytes of personal access tokens
+const accessTokenRandLen = 24
+
+var base64t = base64.RawURLEncoding
+
+// A long-lived token a user creates for automation, whose
+// permission is a subset of the permission of the user.
+type AccessToken struct {
+	ID         Snowflake  `db:"id" json:"id"`
+	CreatedAt  Timestamp  `db:"created_at" json:"created_at"`
+	UserID     Snowflake  `db:"user_id" json:"user_id"`
+	Name       string     `db:"name" json:"name"`
+	Permission Permission `db:"permission" json:"permission"`
+	// Nil if the token never expires
+	ExpiresAt *Timestamp `db:"expires_at" json:"expires_at,omitempty"`
+	// SHA-256 hash of the token
+	TokenHash []byte `db:"token_hash" json:"-"`
+}
+
+func (t *AccessToken) Expired() bool {
+	return t.ExpiresAt != nil && t.ExpiresAt.Before(time.Now())
+}
+
+func (t *AccessToken) TokenMatches(token string) bool {
+	hash := sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare(hash[:], t.TokenHash) == 1
+}
+
+type AccessTokenCreateData struct {
+	Name string `json:"name" validate:"required,max=64"`
+	// Permission bits granted to the token, or-ed together
+	Scopes []Permission `json:"scopes" validate:"required,min=1"`
+	// Number of days until the token expires, zero if it never does
+	ExpiresIn int `json:"expires_in,omitempty" validate:"min=0,max=365"`
+}
+
+func (d *AccessTokenCreateData) Permission() Permission {
+	var perm Permission
+	for _, scope := range d.Scopes {
+		perm |= scope
+	}
+	return perm
+}
+
+// Creates a token for the user, returning it together with its
+// string representation, which is not stored anywhere.
+func NewAccessToken(userId Snowflake, data AccessTokenCreateData) (AccessToken, string) {
+	now := Timestamp{time.Now().Round(time.Millisecond)}
+
+	id := NewSnowflakeTime(now.Time)
+
+	b := make([]byte, 8+accessTokenRandLen)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	rand.Read(b[8:])
+
+	token := AccessTokenPrefix + base64t.EncodeToString(b)
+	hash := sha256.Sum256([]byte(token))
+
+	var expiresAt *Timestamp
+	if data.ExpiresIn > 0 {
+		exp := now.AddDate(0, 0, data.ExpiresIn)
+		expiresAt = &Timestamp{exp}
+	}
+
+	return AccessToken{
+		ID:         id,
+		CreatedAt:  now,
+		UserID:     userId,
+		Name:       data.Name,
+		Permission: data.Permission(),
+		ExpiresAt:  expiresAt,
+		TokenHash:  hash[:],
+	}, token
+}
+
+// Returns the id of the access token, failing if the string is
+// not a well formed one.
+func ParseAccessToken(token string) (Snowflake, bool) {
+	s, ok := strings.CutPrefix(token, AccessTokenPrefix)
+	if !ok {
+		return 0, false
+	}
+
+	b, err := base64t.DecodeString(s)
+	if err != nil || len(b) != 8+accessTokenRandLen {
+		return 0, false
+	}
+
+	return Snowflake(binary.BigEndian.Uint64(b)), true
+}
+
+// Reports whether the string looks like a personal access token.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type ActionPurpose string
+
+const (
+	ActionVerifyEmail   ActionPurpose = "verify_email"
+	ActionResetPassword ActionPurpose = "reset_password"
+)
+
+var _ jwt.Claims = &ActionToken{}
+
+// A single-use token sent to the email of the user, allowing
+// an action to be performed on the account.
+type ActionToken struct {
+	TokenID   Snowflake       `json:"jti"`
+	ID        Snowflake       `json:"sub"`
+	IssuedAt  jwt.NumericDate `json:"iat"`
+	ExpiresAt jwt.NumericDate `json:"exp"`
+	Issuer    string          `json:"iss"`
+	// The email the token was sent to
+	Email   string        `json:"email"`
+	Purpose ActionPurpose `json:"pur"`
+}
+
+func NewActionToken(user *User, purpose ActionPurpose, exp time.Duration) ActionToken {
+	issued := time.Now()
+	now := issued.Round(time.Second)
+
+	return ActionToken{
+		TokenID:   NewSnowflakeTime(issued),
+		ID:        user.ID,
+		IssuedAt:  jwt.NumericDate{Time: now},
+		ExpiresAt: jwt.NumericDate{Time: now.Add(exp)},
+		Email:     user.Email,
+		Purpose:   purpose,
+	}
+}
+
+// GetAudience implements jwt.Claims.
+func (t *ActionToken) GetAudience() (jwt.ClaimStrings, error) {
+	return nil, nil
+}
+
+// GetExpirationTime implements jwt.Claims.
+func (t *ActionToken) GetExpirationTime() (*jwt.NumericDate, error) {
+	return &t.ExpiresAt, nil
+}
+
+// GetIssuedAt implements jwt.Claims.
+func (t *ActionToken) GetIssuedAt() (*jwt.NumericDate, error) {
+	return &t.IssuedAt, nil
+}
+
+// GetIssuer implements jwt.Claims.
+func (t *ActionToken) GetIssuer() (string, error) {
+	return t.Issuer, nil
+}
+
+// GetNotBefore implements jwt.Claims.
+func (t *ActionToken) GetNotBefore() (*jwt.NumericDate, error) {
+	return nil, nil
+}
+
+// GetSubject implements jwt.Claims.
+func (t *ActionToken) GetSubject() (string, error) {
+	return "", nil
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"io"
 	"time"
 
 	"github.com/a-h/templ"
+	"github.com/zanz1n/blog/internal/slug"
 	"github.com/zanz1n/blog/internal/utils"
 )
 
@@ -23,6 +25,19 @@ const (
 	HeadingTypeH4
 )
 
+type ArticleStatus string
+
+const (
+	// Only visible to its author and editors
+	ArticleStatusDraft ArticleStatus = "draft"
+	// Published articles with a publish time in the future.
+	// Never stored, as it is reported by EffectiveStatus.
+	ArticleStatusScheduled ArticleStatus = "scheduled"
+	ArticleStatusPublished ArticleStatus = "published"
+	// Taken down from the listings, but kept as it was
+	ArticleStatusArchived ArticleStatus = "archived"
+)
+
 type Article struct {
 	ID          Snowflake `db:"id" json:"id"`
 	CreatedAt   Timestamp `db:"created_at" json:"created_at"`
@@ -30,9 +45,17 @@ type Article struct {
 	UserID      Snowflake `db:"user_id" json:"user_id"`
 	Title       string    `db:"title" json:"title"`
 	Description string    `db:"description" json:"description"`
+	// Unique, generated from the title
+	Slug string `db:"slug" json:"slug"`
+
+	Status ArticleStatus `db:"status" json:"status"`
+	// Nil if the article was never published
+	PublishAt *Timestamp `db:"publish_at" json:"publish_at,omitempty"`
 
 	// Can be nil if not fetched with user
 	User *User `json:"user,omitempty"`
+	// Can be nil if not fetched with tags
+	Tags []Tag `json:"tags,omitempty"`
 
 	// Can be empty if not fetched with content
 	Indexing ArticleIndexing `db:"indexing" json:"indexing,omitempty"`
@@ -43,8 +66,10 @@ type Article struct {
 }
 
 type ArticleCreateData struct {
-	Title       string `json:"title" validate:"required"`
-	Description string `json:"description"`
+	Title       string `json:"title" schema:"title" validate:"required"`
+	Description string `json:"description" schema:"description"`
+	// Defaults to published
+	Status ArticleStatus `json:"status" schema:"status" validate:"omitempty,oneof=draft published"`
 }
 
 func NewArticle(
@@ -54,10 +79,17 @@ func NewArticle(
 	rawContent ArticleRawContent,
 	data ArticleCreateData,
 ) Article {
-	now := Timestamp{time.Now().Round(time.Millisecond)}
+	now := Timestamp{time.Now().Truncate(time.Millisecond)}
 
 	id := NewSnowflakeTime(now.Time)
 
+	status := data.Status
+	var publishAt *Timestamp
+	if status != ArticleStatusDraft {
+		status = ArticleStatusPublished
+		publishAt = &now
+	}
+
 	return Article{
 		ID:          id,
 		CreatedAt:   now,
@@ -65,12 +97,32 @@ func NewArticle(
 		UserID:      userId,
 		Title:       data.Title,
 		Description: data.Description,
+		Slug:        slug.Make(data.Title),
+		Status:      status,
+		PublishAt:   publishAt,
 		Indexing:    idx,
 		Content:     content,
 		RawContent:  rawContent,
 	}
 }
 
+// Reports whether the article is published and its publish time has
+// passed, being visible to everyone.
+func (a *Article) Published() bool {
+	return a.Status == ArticleStatusPublished &&
+		a.PublishAt != nil &&
+		!a.PublishAt.After(time.Now())
+}
+
+// Returns the status of the article, reporting published articles
+// whose publish time has not passed yet as scheduled.
+func (a *Article) EffectiveStatus() ArticleStatus {
+	if a.Status == ArticleStatusPublished && !a.Published() {
+		return ArticleStatusScheduled
+	}
+	return a.Status
+}
+
 var (
 	_nullArticleIndexing = ArticleIndexing(nil)
 
@@ -117,9 +169,11 @@ func (a ArticleIndexing) Value() (driver.Value, error) {
 var (
 	_nullArticleContent = ArticleContent(nil)
 
-	_ templ.Component = _nullArticleContent
-	_ sql.Scanner     = &_nullArticleContent
-	_ driver.Valuer   = _nullArticleContent
+	_ templ.Component          = _nullArticleContent
+	_ sql.Scanner              = &_nullArticleContent
+	_ driver.Valuer            = _nullArticleContent
+	_ encoding.TextMarshaler   = _nullArticleContent
+	_ encoding.TextUnmarshaler = &_nullArticleContent
 )
 
 type ArticleContent []byte
@@ -157,11 +211,24 @@ func (c ArticleContent) Value() (driver.Value, error) {
 	return utils.UnsafeString(c), nil
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (c ArticleContent) MarshalText() ([]byte, error) {
+	return c, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *ArticleContent) UnmarshalText(text []byte) error {
+	*c = append((*c)[:0], text...)
+	return nil
+}
+
 var (
 	_nullRawArticleContent = ArticleRawContent(nil)
 
-	_ sql.Scanner   = &_nullRawArticleContent
-	_ driver.Valuer = _nullRawArticleContent
+	_ sql.Scanner              = &_nullRawArticleContent
+	_ driver.Valuer            = _nullRawArticleContent
+	_ encoding.TextMarshaler   = _nullRawArticleContent
+	_ encoding.TextUnmarshaler = &_nullRawArticleContent
 )
 
 type ArticleRawContent []byte
@@ -187,6 +254,17 @@ func (c ArticleRawContent) Value() (driver.Value, error) {
 	return utils.UnsafeString(c), nil
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (c ArticleRawContent) MarshalText() ([]byte, error) {
+	return c, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *ArticleRawContent) UnmarshalText(text []byte) error {
+	*c = append((*c)[:0], text...)
+	return nil
+}
+
 func articleIndexingScanErr(src any, err error) error {
 	if err == nil {
 		return fmt.Errorf("Scan: unable to scan type %T into ArticleIndexing", src)
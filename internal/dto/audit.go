@@ -0,0 +1,77 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditUserPermission AuditAction = "user.permission"
+	AuditUserBan        AuditAction = "user.ban"
+	AuditUserUnban      AuditAction = "user.unban"
+	AuditUserDelete     AuditAction = "user.delete"
+)
+
+// A change made by an administrator, kept for accountability.
+type AuditEntry struct {
+	ID        Snowflake `db:"id" json:"id"`
+	CreatedAt Timestamp `db:"created_at" json:"created_at"`
+	// Nil if the change was made outside the server, or the
+	// administrator was deleted
+	ActorID  *Snowflake  `db:"actor_id" json:"actor_id"`
+	Action   AuditAction `db:"action" json:"action"`
+	TargetID Snowflake   `db:"target_id" json:"target_id"`
+	// Json encoded details of the change
+	Data AuditData `db:"data" json:"data"`
+}
+
+func NewAuditEntry(
+	actorId *Snowflake,
+	action AuditAction,
+	targetId Snowflake,
+	data any,
+) (AuditEntry, error) {
+	now := Timestamp{time.Now().Round(time.Millisecond)}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	return AuditEntry{
+		ID:        NewSnowflakeTime(now.Time),
+		CreatedAt: now,
+		ActorID:   actorId,
+		Action:    action,
+		TargetID:  targetId,
+		Data:      AuditData(b),
+	}, nil
+}
+
+// Json text, which is embedded as is when marshaled.
+type AuditData string
+
+var _ json.Marshaler = AuditData("")
+
+// MarshalJSON implements json.Marshaler.
+func (d AuditData) MarshalJSON() ([]byte, error) {
+	if !json.Valid([]byte(d)) {
+		return []byte("null"), nil
+	}
+	return []byte(d), nil
+}
+
+// The details of a permission change.
+type AuditPermissionData struct {
+	Old Permission `json:"old"`
+	New Permission `json:"new"`
+}
+
+// The details of a banned, unbanned or deleted user, which can
+// not be looked up once it is deleted.
+type AuditUserData struct {
+	Email    string `json:"email"`
+	Nickname string `json:"nickname"`
+}
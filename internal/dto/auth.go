@@ -16,6 +16,10 @@ const (
 	PermissionModerateAllComments
 	PermissionReadProfiles
 	PermissionWriteProfiles
+	// Manage other users and their permissions
+	PermissionAdmin
+	// Read the unpublished articles of other users
+	PermissionReadDrafts
 
 	PermissionVisitor = PermissionReadPosts |
 		PermissionReadComments |
@@ -23,6 +27,7 @@ const (
 	PermissionDefault   = PermissionVisitor | PermissionWriteComments
 	PermisisonPublisher = PermissionDefault | PermissionWritePosts
 	PermissionModerator = PermissionDefault | PermissionModerateAllComments
+	PermissionEditor    = PermisisonPublisher | PermissionReadDrafts
 )
 
 var _ fmt.Stringer = PermissionDefault
@@ -34,9 +39,17 @@ func (p Permission) String() string {
 	return strconv.Itoa(int(p))
 }
 
+// Reports whether all the bits of perm are set in p.
+func (p Permission) Has(perm Permission) bool {
+	return p&perm == perm
+}
+
 var _ jwt.Claims = &AuthToken{}
 
 type AuthToken struct {
+	// Unique id of the token, its timestamp being the issuing time
+	// in milliseconds precision
+	TokenID    Snowflake       `json:"jti"`
 	ID         Snowflake       `json:"sub"`
 	IssuedAt   jwt.NumericDate `json:"iat"`
 	ExpiresAt  jwt.NumericDate `json:"exp"`
@@ -45,12 +58,16 @@ type AuthToken struct {
 	Name       string          `json:"name"`
 	Email      string          `json:"email"`
 	Permission Permission      `json:"perm"`
+	// The session the token was issued for, zero if none
+	SessionID Snowflake `json:"sid,omitempty"`
 }
 
 func NewAuthToken(user *User, issuer string, exp time.Duration) AuthToken {
-	now := time.Now().Round(time.Second)
+	issued := time.Now()
+	now := issued.Round(time.Second)
 
 	return AuthToken{
+		TokenID:    NewSnowflakeTime(issued),
 		ID:         user.ID,
 		IssuedAt:   jwt.NumericDate{Time: now},
 		ExpiresAt:  jwt.NumericDate{Time: now.Add(exp)},
@@ -58,7 +75,7 @@ func NewAuthToken(user *User, issuer string, exp time.Duration) AuthToken {
 		Nickname:   user.Nickname,
 		Name:       user.Name,
 		Email:      user.Email,
-		Permission: user.Permission,
+		Permission: user.EffectivePermission(),
 	}
 }
 
@@ -0,0 +1,65 @@
+package dto
+
+import "time"
+
+// Max depth of comment replies, top-level comments having depth zero.
+const MaxCommentDepth = 4
+
+type Comment struct {
+	ID        Snowflake `db:"id" json:"id"`
+	CreatedAt Timestamp `db:"created_at" json:"created_at"`
+	UpdatedAt Timestamp `db:"updated_at" json:"updated_at"`
+	ArticleID Snowflake `db:"article_id" json:"article_id"`
+	UserID    Snowflake `db:"user_id" json:"user_id"`
+	Content   string    `db:"content" json:"content"`
+	// Hidden comments can only be seen by their
+	// authors and by moderators
+	Hidden bool `db:"hidden" json:"hidden"`
+	// Zero for top-level comments
+	ParentID Snowflake `db:"parent_id" json:"parent_id,omitempty"`
+	Depth    int       `db:"depth" json:"depth"`
+
+	// Only set if fetched as part of a thread
+	ReplyCount int `db:"reply_count" json:"reply_count,omitempty"`
+	// The first replies of the comment, only set if fetched as
+	// part of a thread
+	Replies []Comment `json:"replies,omitempty"`
+
+	// Can be nil if not fetched with user
+	User *CommentAuthor `json:"user,omitempty"`
+}
+
+// The public fields of the author of a comment.
+type CommentAuthor struct {
+	ID       Snowflake `db:"id" json:"id"`
+	Nickname string    `db:"nickname" json:"nickname"`
+	Name     string    `db:"name" json:"name,omitempty"`
+}
+
+type CommentCreateData struct {
+	Content string `json:"content" schema:"content" validate:"required,max=4096"`
+}
+
+func NewComment(articleId, userId Snowflake, data CommentCreateData) Comment {
+	now := Timestamp{time.Now().Round(time.Millisecond)}
+
+	id := NewSnowflakeTime(now.Time)
+
+	return Comment{
+		ID:        id,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ArticleID: articleId,
+		UserID:    userId,
+		Content:   data.Content,
+		Hidden:    false,
+	}
+}
+
+func NewReply(parent Comment, userId Snowflake, data CommentCreateData) Comment {
+	comment := NewComment(parent.ArticleID, userId, data)
+	comment.ParentID = parent.ID
+	comment.Depth = parent.Depth + 1
+
+	return comment
+}
@@ -0,0 +1,29 @@
+package dto
+
+import "time"
+
+// Links a user to its account on an external identity provider.
+type Identity struct {
+	ID        Snowflake `db:"id" json:"id"`
+	CreatedAt Timestamp `db:"created_at" json:"created_at"`
+	UserID    Snowflake `db:"user_id" json:"user_id"`
+	// The issuer url of the provider
+	Issuer string `db:"issuer" json:"issuer"`
+	// The id of the account on the provider, only unique per issuer
+	Subject string `db:"subject" json:"subject"`
+	// The email of the account on the provider when it was linked
+	Email string `db:"email" json:"email"`
+}
+
+func NewIdentity(userId Snowflake, issuer, subject, email string) Identity {
+	now := Timestamp{time.Now().Round(time.Millisecond)}
+
+	return Identity{
+		ID:        NewSnowflakeTime(now.Time),
+		CreatedAt: now,
+		UserID:    userId,
+		Issuer:    issuer,
+		Subject:   subject,
+		Email:     email,
+	}
+}
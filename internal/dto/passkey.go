@@ -0,0 +1,158 @@
+package dto
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// A WebAuthn public key credential users can log in with instead
+// of their password.
+type Passkey struct {
+	ID         Snowflake  `db:"id" json:"id"`
+	CreatedAt  Timestamp  `db:"created_at" json:"created_at"`
+	UserID     Snowflake  `db:"user_id" json:"user_id"`
+	Name       string     `db:"name" json:"name"`
+	LastUsedAt *Timestamp `db:"last_used_at" json:"last_used_at,omitempty"`
+
+	CredentialID []byte `db:"credential_id" json:"-"`
+	// COSE encoded public key
+	PublicKey       []byte `db:"public_key" json:"-"`
+	AttestationType string `db:"attestation_type" json:"-"`
+	// Comma separated transports the authenticator supports
+	Transports string `db:"transports" json:"-"`
+	AAGUID     []byte `db:"aaguid" json:"-"`
+	SignCount  int64  `db:"sign_count" json:"-"`
+	// The protocol.AuthenticatorFlags set on registration, of which
+	// the backup state is updated on each login
+	Flags int16 `db:"flags" json:"-"`
+}
+
+func NewPasskey(userId Snowflake, name string, cred *webauthn.Credential) Passkey {
+	now := Timestamp{time.Now().Round(time.Millisecond)}
+
+	transports := make([]string, len(cred.Transport))
+	for i, t := range cred.Transport {
+		transports[i] = string(t)
+	}
+
+	p := Passkey{
+		ID:              NewSnowflakeTime(now.Time),
+		CreatedAt:       now,
+		UserID:          userId,
+		Name:            name,
+		CredentialID:    cred.ID,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       int64(cred.Authenticator.SignCount),
+	}
+	p.SetFlags(cred.Flags)
+
+	if p.AAGUID == nil {
+		p.AAGUID = []byte{}
+	}
+	return p
+}
+
+func (p *Passkey) SetFlags(flags webauthn.CredentialFlags) {
+	var f protocol.AuthenticatorFlags
+	if flags.UserPresent {
+		f |= protocol.FlagUserPresent
+	}
+	if flags.UserVerified {
+		f |= protocol.FlagUserVerified
+	}
+	if flags.BackupEligible {
+		f |= protocol.FlagBackupEligible
+	}
+	if flags.BackupState {
+		f |= protocol.FlagBackupState
+	}
+	p.Flags = int16(f)
+}
+
+// Converts the passkey back to the credential the ceremonies
+// are validated against.
+func (p *Passkey) Credential() webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	if p.Transports != "" {
+		for _, t := range strings.Split(p.Transports, ",") {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+	}
+
+	flags := protocol.AuthenticatorFlags(p.Flags)
+
+	return webauthn.Credential{
+		ID:              p.CredentialID,
+		PublicKey:       p.PublicKey,
+		AttestationType: p.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			UserPresent:    flags.HasUserPresent(),
+			UserVerified:   flags.HasUserVerified(),
+			BackupEligible: flags.HasBackupEligible(),
+			BackupState:    flags.HasBackupState(),
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    p.AAGUID,
+			SignCount: uint32(p.SignCount),
+		},
+	}
+}
+
+// Adapts a user and its passkeys to the webauthn.User interface.
+type PasskeyUser struct {
+	User     *User
+	Passkeys []Passkey
+}
+
+var _ webauthn.User = PasskeyUser{}
+
+// The user handle stored by authenticators, which discoverable
+// logins identify the user by.
+func (u PasskeyUser) WebAuthnID() []byte {
+	return PasskeyUserHandle(u.User.ID)
+}
+
+func (u PasskeyUser) WebAuthnName() string {
+	return u.User.Email
+}
+
+func (u PasskeyUser) WebAuthnDisplayName() string {
+	if u.User.Name != "" {
+		return u.User.Name
+	}
+	return u.User.Nickname
+}
+
+func (u PasskeyUser) WebAuthnCredentials() []webauthn.Credential {
+	creds := make([]webauthn.Credential, len(u.Passkeys))
+	for i := range u.Passkeys {
+		creds[i] = u.Passkeys[i].Credential()
+	}
+	return creds
+}
+
+func (u PasskeyUser) WebAuthnIcon() string {
+	return ""
+}
+
+func PasskeyUserHandle(userId Snowflake) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(userId))
+	return b
+}
+
+// Returns the id of the user the handle belongs to.
+func ParsePasskeyUserHandle(handle []byte) (Snowflake, bool) {
+	if len(handle) != 8 {
+		return 0, false
+	}
+	return Snowflake(binary.BigEndian.Uint64(handle)), true
+}
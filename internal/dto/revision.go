@@ -0,0 +1,31 @@
+package dto
+
+import "time"
+
+// A snapshot of the markdown source of an article, recorded every
+// time its content is written.
+type ArticleRevision struct {
+	ID        Snowflake `db:"id" json:"id"`
+	CreatedAt Timestamp `db:"created_at" json:"created_at"`
+	ArticleID Snowflake `db:"article_id" json:"article_id"`
+	// The user that wrote the content, nil if deleted
+	UserID *Snowflake `db:"user_id" json:"user_id,omitempty"`
+
+	// Can be empty if not fetched with content
+	RawContent ArticleRawContent `db:"raw_content" json:"raw_content,omitempty"`
+}
+
+func NewArticleRevision(
+	articleId, userId Snowflake,
+	rawContent ArticleRawContent,
+) ArticleRevision {
+	now := Timestamp{time.Now().Truncate(time.Millisecond)}
+
+	return ArticleRevision{
+		ID:         NewSnowflakeTime(now.Time),
+		CreatedAt:  now,
+		ArticleID:  articleId,
+		UserID:     &userId,
+		RawContent: rawContent,
+	}
+}
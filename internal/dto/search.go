@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"html"
+	"strings"
+)
+
+// Delimiters the databases wrap the matched terms of a snippet with,
+// as they can't be part of a markdown document.
+const (
+	SnippetMatchStart = "\x01"
+	SnippetMatchEnd   = "\x02"
+)
+
+type ArticleSearchResult struct {
+	Article
+	// Higher is more relevant, only comparable within the same search
+	Rank float64 `db:"rank" json:"rank"`
+	// Escaped html excerpt of the content, with the matched terms
+	// wrapped in <mark> elements
+	Snippet string `db:"snippet" json:"snippet"`
+}
+
+// Escapes a snippet returned by the database, replacing the match
+// delimiters by <mark> elements.
+func HighlightSnippet(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	open := false
+	for len(s) > 0 {
+		i := strings.IndexAny(s, SnippetMatchStart+SnippetMatchEnd)
+		if i == -1 {
+			b.WriteString(html.EscapeString(s))
+			break
+		}
+		b.WriteString(html.EscapeString(s[:i]))
+
+		if s[i:i+1] == SnippetMatchStart {
+			if !open {
+				b.WriteString("<mark>")
+				open = true
+			}
+		} else if open {
+			b.WriteString("</mark>")
+			open = false
+		}
+		s = s[i+1:]
+	}
+
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
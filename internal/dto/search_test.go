@@ -0,0 +1,25 @@
+package dto_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zanz1n/blog/internal/dto"
+)
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"plain text", "plain text"},
+		{"a \x01match\x02 b", "a <mark>match</mark> b"},
+		{"<b>\x01x\x02</b>", "&lt;b&gt;<mark>x</mark>&lt;/b&gt;"},
+		{"\x01unclosed", "<mark>unclosed</mark>"},
+		{"stray\x02 \x01\x01a\x02", "stray <mark>a</mark>"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.out, dto.HighlightSnippet(test.in))
+	}
+}
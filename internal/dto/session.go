@@ -0,0 +1,28 @@
+package dto
+
+import "time"
+
+type SessionMetadata struct {
+	UserAgent string `json:"user_agent"`
+	IP        string `json:"ip"`
+}
+
+type Session struct {
+	ID         Snowflake `json:"id"`
+	UserID     Snowflake `json:"user_id"`
+	CreatedAt  Timestamp `json:"created_at"`
+	LastUsedAt Timestamp `json:"last_used_at"`
+	SessionMetadata
+}
+
+func NewSession(userId Snowflake, meta SessionMetadata) Session {
+	now := Timestamp{time.Now().Round(time.Millisecond)}
+
+	return Session{
+		ID:              NewSnowflakeTime(now.Time),
+		UserID:          userId,
+		CreatedAt:       now,
+		LastUsedAt:      now,
+		SessionMetadata: meta,
+	}
+}
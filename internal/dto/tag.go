@@ -0,0 +1,63 @@
+package dto
+
+import (
+	"strings"
+	"time"
+
+	"github.com/zanz1n/blog/internal/slug"
+)
+
+const (
+	MaxArticleTags = 10
+	MaxTagLength   = 32
+)
+
+type Tag struct {
+	ID        Snowflake `db:"id" json:"id"`
+	CreatedAt Timestamp `db:"created_at" json:"created_at"`
+	Name      string    `db:"name" json:"name"`
+	// Unique, generated from the name
+	Slug string `db:"slug" json:"slug"`
+
+	// Number of published articles with the tag, only set
+	// if fetched with it
+	ArticleCount int `db:"article_count" json:"article_count,omitempty"`
+}
+
+func NewTag(name string) Tag {
+	now := Timestamp{time.Now().Round(time.Millisecond)}
+
+	return Tag{
+		ID:        NewSnowflakeTime(now.Time),
+		CreatedAt: now,
+		Name:      name,
+		Slug:      slug.Make(name),
+	}
+}
+
+// Splits the comma separated tag names, trimming and truncating them
+// and dropping the empty ones and the ones with repeated slugs.
+func ParseTagNames(names []string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+
+	for _, name := range names {
+		for _, name := range strings.Split(name, ",") {
+			name = strings.Join(strings.Fields(name), " ")
+			if len(name) > MaxTagLength {
+				name = strings.TrimSpace(strings.ToValidUTF8(name[:MaxTagLength], ""))
+			}
+			if name == "" {
+				continue
+			}
+
+			s := slug.Make(name)
+			if !seen[s] {
+				seen[s] = true
+				tags = append(tags, name)
+			}
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,27 @@
+package dto_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zanz1n/blog/internal/dto"
+)
+
+func TestParseTagNames(t *testing.T) {
+	names := dto.ParseTagNames([]string{
+		"Go, web  dev,,",
+		" ",
+		"go",
+		"Web-Dev",
+		"SQL",
+		strings.Repeat("a", dto.MaxTagLength+8),
+	})
+
+	assert.Equal(t, []string{
+		"Go",
+		"web dev",
+		"SQL",
+		strings.Repeat("a", dto.MaxTagLength),
+	}, names)
+}
@@ -0,0 +1,165 @@
+package dto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+const (
+	// Length of the totp step
+	totpPeriod = 30 * time.Second
+	// Steps before and after the current one whose codes are also
+	// accepted, tolerating clock drift
+	totpSkew = 1
+
+	totpSecretLen = 20
+
+	RecoveryCodeCount = 10
+	// Number of random bytes of each recovery code
+	recoveryCodeLen = 5
+)
+
+var base32t = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var totpOpts = hotp.ValidateOpts{
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// The RFC 6238 time-based one-time password credential of a user.
+// It is pending until the user confirms the enrollment with a valid code.
+type TOTP struct {
+	UserID    Snowflake `db:"user_id" json:"user_id"`
+	CreatedAt Timestamp `db:"created_at" json:"created_at"`
+	// Base32 encoded secret, without padding
+	Secret string `db:"secret" json:"-"`
+	// Nil while the enrollment is pending
+	EnabledAt *Timestamp `db:"enabled_at" json:"enabled_at,omitempty"`
+	// The last step a code was accepted for, preventing codes
+	// from being used twice
+	LastStep int64 `db:"last_step" json:"-"`
+	// SHA-256 hashes of the unused recovery codes, concatenated
+	RecoveryCodes []byte `db:"recovery_codes" json:"-"`
+}
+
+func NewTOTP(userId Snowflake) TOTP {
+	secret := make([]byte, totpSecretLen)
+	rand.Read(secret)
+
+	return TOTP{
+		UserID:        userId,
+		CreatedAt:     Timestamp{time.Now().Round(time.Millisecond)},
+		Secret:        base32t.EncodeToString(secret),
+		RecoveryCodes: []byte{},
+	}
+}
+
+func (t *TOTP) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// Returns the otpauth uri authenticator apps are set up with,
+// usually shown as a qr code.
+func (t *TOTP) URI(issuer, account string) string {
+	v := url.Values{}
+	v.Set("secret", t.Secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", "6")
+	v.Set("period", "30")
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// Returns the step the code is valid for at the given time, failing
+// if it is invalid or its step is not after the last used one.
+func (t *TOTP) Validate(code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpOpts.Digits.Length() {
+		return 0, false
+	}
+
+	current := now.Unix() / int64(totpPeriod.Seconds())
+
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= t.LastStep {
+			continue
+		}
+
+		expected, err := hotp.GenerateCodeCustom(t.Secret, uint64(step), totpOpts)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func (t *TOTP) RecoveryCodesLeft() int {
+	return len(t.RecoveryCodes) / sha256.Size
+}
+
+// Returns the hashes of the recovery codes left after the given
+// one is used, failing if it is not one of them.
+func (t *TOTP) UseRecoveryCode(code string) ([]byte, bool) {
+	hash := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+
+	for i := 0; i+sha256.Size <= len(t.RecoveryCodes); i += sha256.Size {
+		if subtle.ConstantTimeCompare(t.RecoveryCodes[i:i+sha256.Size], hash[:]) == 1 {
+			left := bytes.Clone(t.RecoveryCodes[:i])
+			return append(left, t.RecoveryCodes[i+sha256.Size:]...), true
+		}
+	}
+
+	return nil, false
+}
+
+// Generates the one-time recovery codes of a user, returning their
+// concatenated hashes together with the codes, which are not stored
+// anywhere.
+func NewRecoveryCodes() ([]byte, []string) {
+	hashes := make([]byte, 0, RecoveryCodeCount*sha256.Size)
+	codes := make([]string, RecoveryCodeCount)
+
+	b := make([]byte, recoveryCodeLen)
+	for i := range codes {
+		rand.Read(b)
+
+		s := strings.ToLower(base32t.EncodeToString(b))
+		codes[i] = s[:4] + "-" + s[4:]
+
+		hash := sha256.Sum256([]byte(s))
+		hashes = append(hashes, hash[:]...)
+	}
+
+	return hashes, codes
+}
+
+// Tells recovery codes apart from totp codes, which are only digits.
+func IsRecoveryCode(code string) bool {
+	return len(normalizeRecoveryCode(code)) == base32t.EncodedLen(recoveryCodeLen)
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,98 @@
+package dto_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zanz1n/blog/internal/dto"
+)
+
+// The sha1 test vectors of RFC 6238 appendix B, truncated to 6 digits
+var totpVectors = []struct {
+	Time int64
+	Code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+}
+
+func rfcTOTP() dto.TOTP {
+	return dto.TOTP{
+		// base32 of "12345678901234567890"
+		Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+	}
+}
+
+func TestTOTPValidate(t *testing.T) {
+	for _, v := range totpVectors {
+		totp := rfcTOTP()
+
+		step, ok := totp.Validate(v.Code, time.Unix(v.Time, 0))
+		assert.True(t, ok, "code %s at %d", v.Code, v.Time)
+		assert.Equal(t, v.Time/30, step)
+	}
+}
+
+func TestTOTPValidateSkew(t *testing.T) {
+	totp := rfcTOTP()
+	now := time.Unix(1111111109, 0)
+
+	_, ok := totp.Validate("081804", now.Add(30*time.Second))
+	assert.True(t, ok)
+
+	_, ok = totp.Validate("081804", now.Add(90*time.Second))
+	assert.False(t, ok)
+
+	_, ok = totp.Validate("000000", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPValidateReplay(t *testing.T) {
+	totp := rfcTOTP()
+	now := time.Unix(1111111109, 0)
+
+	step, ok := totp.Validate("081804", now)
+	assert.True(t, ok)
+
+	totp.LastStep = step
+	_, ok = totp.Validate("081804", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	totp := dto.NewTOTP(dto.NewSnowflake())
+	uri := totp.URI("Blog", "user@example.com")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Blog:user@example.com?"))
+	assert.Contains(t, uri, "secret="+totp.Secret)
+	assert.Contains(t, uri, "issuer=Blog")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	hashes, codes := dto.NewRecoveryCodes()
+	assert.Len(t, codes, dto.RecoveryCodeCount)
+
+	totp := dto.TOTP{RecoveryCodes: hashes}
+	assert.Equal(t, dto.RecoveryCodeCount, totp.RecoveryCodesLeft())
+
+	for _, code := range codes {
+		assert.True(t, dto.IsRecoveryCode(code))
+	}
+
+	left, ok := totp.UseRecoveryCode(strings.ToUpper(codes[3]))
+	assert.True(t, ok)
+
+	totp.RecoveryCodes = left
+	assert.Equal(t, dto.RecoveryCodeCount-1, totp.RecoveryCodesLeft())
+
+	_, ok = totp.UseRecoveryCode(codes[3])
+	assert.False(t, ok)
+
+	_, ok = totp.UseRecoveryCode(codes[4])
+	assert.True(t, ok)
+}
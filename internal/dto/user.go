@@ -18,9 +18,41 @@ type User struct {
 	Nickname   string     `db:"nickname" json:"nickname"`
 	Name       string     `db:"name" json:"name,omitempty"`
 	Password   []byte     `db:"password" json:"-"`
+	// Nil if the user has not verified the email yet
+	EmailVerifiedAt *Timestamp `db:"email_verified_at" json:"email_verified_at,omitempty"`
+	// Nil if the user is not banned
+	BannedAt *Timestamp `db:"banned_at" json:"banned_at,omitempty"`
+}
+
+// Banned users can not log in nor use their access tokens.
+func (u *User) Banned() bool {
+	return u.BannedAt != nil
+}
+
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// Returns the permission the user is granted, which is limited
+// to the visitor one until the email is verified.
+func (u *User) EffectivePermission() Permission {
+	if !u.EmailVerified() {
+		return u.Permission & PermissionVisitor
+	}
+	return u.Permission
+}
+
+// Users provisioned by an identity provider have no password
+// until they reset it.
+func (u *User) HasPassword() bool {
+	return len(u.Password) != 0
 }
 
 func (u *User) PasswordMatches(passwd string) bool {
+	if !u.HasPassword() {
+		return false
+	}
+
 	err := bcrypt.CompareHashAndPassword(u.Password, utils.UnsafeBytes(passwd))
 	if err != nil && !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 		slog.Error("User: failed to compare hashed password", "error", err)
@@ -39,11 +71,7 @@ type UserCreateData struct {
 func NewUser(data UserCreateData, permission Permission, hashCost int) (User, error) {
 	now := Timestamp{time.Now().Round(time.Millisecond)}
 
-	if bcrypt.MinCost > hashCost || bcrypt.MaxCost < hashCost {
-		hashCost = bcrypt.DefaultCost
-	}
-
-	hash, err := bcrypt.GenerateFromPassword(utils.UnsafeBytes(data.Password), hashCost)
+	hash, err := HashPassword(data.Password, hashCost)
 	if err != nil {
 		return User{}, err
 	}
@@ -61,3 +89,41 @@ func NewUser(data UserCreateData, permission Permission, hashCost int) (User, er
 		Password:   hash,
 	}, nil
 }
+
+// Creates a user logging in through an identity provider, without a
+// password. The email is only marked as verified if the provider did so.
+func NewExternalUser(
+	email string,
+	nickname string,
+	name string,
+	permission Permission,
+	emailVerified bool,
+) User {
+	now := Timestamp{time.Now().Round(time.Millisecond)}
+
+	user := User{
+		ID:         NewSnowflakeTime(now.Time),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Permission: permission,
+		Email:      email,
+		Nickname:   nickname,
+		Name:       name,
+		Password:   []byte{},
+	}
+	if emailVerified {
+		user.EmailVerifiedAt = &now
+	}
+
+	return user
+}
+
+// Hashes the password with bcrypt, falling back to the default cost
+// if the given one is out of range.
+func HashPassword(passwd string, hashCost int) ([]byte, error) {
+	if bcrypt.MinCost > hashCost || bcrypt.MaxCost < hashCost {
+		hashCost = bcrypt.DefaultCost
+	}
+
+	return bcrypt.GenerateFromPassword(utils.UnsafeBytes(passwd), hashCost)
+}
@@ -36,3 +36,40 @@ func TestUserSnowflake(t *testing.T) {
 
 	assert.Equal(user.ID.Timestamp(), user.CreatedAt.Time)
 }
+
+func TestUserEffectivePermission(t *testing.T) {
+	assert := require.New(t)
+
+	user, err := dto.NewUser(userData(), dto.PermisisonPublisher, bcrypt.MinCost)
+	assert.NoError(err)
+
+	assert.Equal(dto.PermissionVisitor, user.EffectivePermission())
+	assert.Equal(dto.PermissionVisitor, dto.NewAuthToken(&user, "", 0).Permission)
+
+	user.EmailVerifiedAt = &user.CreatedAt
+	assert.Equal(dto.PermisisonPublisher, user.EffectivePermission())
+}
+
+func TestExternalUser(t *testing.T) {
+	assert := require.New(t)
+
+	user := dto.NewExternalUser(
+		"johhdoe@example.com",
+		"johhdoe",
+		"John Doe",
+		dto.PermissionDefault,
+		true,
+	)
+	assert.True(user.EmailVerified())
+	assert.False(user.HasPassword())
+	assert.False(user.PasswordMatches(""))
+
+	user = dto.NewExternalUser(
+		"johhdoe@example.com",
+		"johhdoe",
+		"John Doe",
+		dto.PermissionDefault,
+		false,
+	)
+	assert.False(user.EmailVerified())
+}
@@ -0,0 +1,148 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	keyExt         = ".pem"
+	retiredExt     = ".retired"
+	currentKeyFile = "current"
+)
+
+// Loads a keyring from a directory containing a `{kid}.pem` PKCS #8 file
+// for every key and a `current` file with the id of the signing key.
+//
+// Retired keys are renamed to `{kid}.pem.retired`, which are ignored.
+func LoadDir(dir string) (*Keyring, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+
+	keys := []Key{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != keyExt {
+			continue
+		}
+
+		key, err := readKeyFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	current, err := CurrentKeyID(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(keys, current)
+}
+
+// Returns the id of the signing key of the directory keyring.
+func CurrentKeyID(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, currentKeyFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrCurrentMissing
+		}
+		return "", fmt.Errorf("keyring: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Stores a new key in the directory keyring. If there is no current key,
+// the new one becomes it.
+func WriteKey(dir string, key Key) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("keyring: %w", err)
+	}
+
+	data, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return fmt.Errorf("keyring: marshal key: %w", err)
+	}
+
+	name := filepath.Join(dir, key.ID+keyExt)
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("keyring: %w", err)
+	}
+	defer file.Close()
+
+	err = pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: data})
+	if err != nil {
+		return fmt.Errorf("keyring: %w", err)
+	}
+
+	if _, err = CurrentKeyID(dir); errors.Is(err, ErrCurrentMissing) {
+		return Promote(dir, key.ID)
+	}
+	return err
+}
+
+// Makes the key the one used to sign new tokens.
+func Promote(dir string, kid string) error {
+	_, err := os.Stat(filepath.Join(dir, kid+keyExt))
+	if err != nil {
+		return fmt.Errorf("keyring: key `%s`: %w", kid, err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, currentKeyFile), []byte(kid+"\n"), 0o600)
+	if err != nil {
+		return fmt.Errorf("keyring: %w", err)
+	}
+	return nil
+}
+
+// Removes the key from the keyring, tokens signed with it failing
+// verification from then on. The current key can not be retired.
+func Retire(dir string, kid string) error {
+	current, err := CurrentKeyID(dir)
+	if err != nil {
+		return err
+	}
+	if current == kid {
+		return fmt.Errorf("keyring: key `%s` is the current one", kid)
+	}
+
+	name := filepath.Join(dir, kid+keyExt)
+	if err = os.Rename(name, name+retiredExt); err != nil {
+		return fmt.Errorf("keyring: key `%s`: %w", kid, err)
+	}
+	return nil
+}
+
+func readKeyFile(name string) (Key, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return Key{}, fmt.Errorf("keyring: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("keyring: %s: invalid pem file", name)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return Key{}, fmt.Errorf("keyring: %s: %w", name, err)
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return Key{}, fmt.Errorf("keyring: %s: not an ed25519 key", name)
+	}
+
+	return NewKey(priv), nil
+}
@@ -0,0 +1,35 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+)
+
+// Loads a keyring from base64 encoded ed25519 private keys. If current
+// is empty, the first key is used to sign tokens.
+func LoadEnv(values []string, current string) (*Keyring, error) {
+	keys := make([]Key, 0, len(values))
+
+	for i, value := range values {
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %d: %w", i, err)
+		}
+
+		if len(data) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf(
+				"keyring: key %d: invalid length: expected %d, but got %d",
+				i, ed25519.PrivateKeySize, len(data),
+			)
+		}
+
+		keys = append(keys, NewKey(ed25519.PrivateKey(data)))
+	}
+
+	if current == "" && len(keys) != 0 {
+		current = keys[0].ID
+	}
+
+	return New(keys, current)
+}
@@ -0,0 +1,139 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrNoKeys         = errors.New("keyring: no keys provided")
+	ErrCurrentMissing = errors.New("keyring: current key not found")
+)
+
+type Key struct {
+	ID      string
+	Private ed25519.PrivateKey
+	Public  ed25519.PublicKey
+}
+
+// Public JSON Web Key of an ed25519 key, as defined by RFC 8037.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	X   string `json:"x"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewKey(priv ed25519.PrivateKey) Key {
+	pub := priv.Public().(ed25519.PublicKey)
+
+	return Key{
+		ID:      KeyID(pub),
+		Private: priv,
+		Public:  pub,
+	}
+}
+
+func GenerateKey() (Key, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, err
+	}
+	return NewKey(priv), nil
+}
+
+func (k Key) JWK() JWK {
+	return JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		Use: "sig",
+		Alg: "EdDSA",
+		Kid: k.ID,
+		X:   base64.RawURLEncoding.EncodeToString(k.Public),
+	}
+}
+
+// Derives the key id from the public key, so the same key
+// always gets the same id.
+func KeyID(pub ed25519.PublicKey) string {
+	hash := sha256.Sum256(pub)
+	return base64.RawURLEncoding.EncodeToString(hash[:12])
+}
+
+// Set of keys used to sign and verify tokens. Tokens are signed with the
+// current key, and verified against any of the keys in the ring, so keys can
+// be rotated without invalidating the tokens signed with the previous ones.
+//
+// Retired keys are simply left out of the ring.
+type Keyring struct {
+	current Key
+	keys    []Key
+}
+
+func New(keys []Key, current string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	idx := slices.IndexFunc(keys, func(k Key) bool {
+		return k.ID == current
+	})
+	if idx == -1 {
+		return nil, fmt.Errorf("%w: `%s`", ErrCurrentMissing, current)
+	}
+
+	if keys[idx].Private == nil {
+		return nil, fmt.Errorf("keyring: current key `%s` has no private key", current)
+	}
+
+	return &Keyring{
+		current: keys[idx],
+		keys:    slices.Clone(keys),
+	}, nil
+}
+
+// Creates a keyring with a single key, which is also the current one.
+func Single(priv ed25519.PrivateKey) *Keyring {
+	key := NewKey(priv)
+	return &Keyring{current: key, keys: []Key{key}}
+}
+
+// Returns the key used to sign new tokens.
+func (k *Keyring) Current() Key {
+	return k.current
+}
+
+// Returns the verification key with the given id.
+func (k *Keyring) Get(kid string) (Key, bool) {
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Returns all the verification keys.
+func (k *Keyring) Keys() []Key {
+	return slices.Clone(k.keys)
+}
+
+// Returns the verification keys as a JSON Web Key Set.
+func (k *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, len(k.keys))}
+	for i, key := range k.keys {
+		set.Keys[i] = key.JWK()
+	}
+	return set
+}
@@ -0,0 +1,108 @@
+package keyring_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/keyring"
+)
+
+func TestKeyringDir(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := keyring.LoadDir(dir)
+	assert.Error(t, err)
+
+	key1, err := keyring.GenerateKey()
+	assert.NoError(t, err)
+	key2, err := keyring.GenerateKey()
+	assert.NoError(t, err)
+
+	assert.NoError(t, keyring.WriteKey(dir, key1))
+	assert.NoError(t, keyring.WriteKey(dir, key2))
+
+	t.Run("FirstIsCurrent", func(t *testing.T) {
+		keys, err := keyring.LoadDir(dir)
+		assert.NoError(t, err)
+
+		assert.Equal(t, key1, keys.Current())
+		assert.Len(t, keys.Keys(), 2)
+
+		key, ok := keys.Get(key2.ID)
+		assert.True(t, ok)
+		assert.Equal(t, key2, key)
+	})
+
+	t.Run("Promote", func(t *testing.T) {
+		assert.NoError(t, keyring.Promote(dir, key2.ID))
+
+		keys, err := keyring.LoadDir(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, key2, keys.Current())
+
+		assert.Error(t, keyring.Promote(dir, "inexistent"))
+	})
+
+	t.Run("Retire", func(t *testing.T) {
+		assert.Error(t, keyring.Retire(dir, key2.ID))
+		assert.NoError(t, keyring.Retire(dir, key1.ID))
+
+		keys, err := keyring.LoadDir(dir)
+		assert.NoError(t, err)
+		assert.Len(t, keys.Keys(), 1)
+
+		_, ok := keys.Get(key1.ID)
+		assert.False(t, ok)
+	})
+}
+
+func TestKeyringEnv(t *testing.T) {
+	key1, err := keyring.GenerateKey()
+	assert.NoError(t, err)
+	key2, err := keyring.GenerateKey()
+	assert.NoError(t, err)
+
+	values := []string{
+		base64.StdEncoding.EncodeToString(key1.Private),
+		base64.StdEncoding.EncodeToString(key2.Private),
+	}
+
+	keys, err := keyring.LoadEnv(values, "")
+	assert.NoError(t, err)
+	assert.Equal(t, key1, keys.Current())
+
+	keys, err = keyring.LoadEnv(values, key2.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, key2, keys.Current())
+
+	_, err = keyring.LoadEnv(values, "inexistent")
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, keyring.ErrCurrentMissing)
+
+	_, err = keyring.LoadEnv([]string{"AAAA"}, "")
+	assert.Error(t, err)
+
+	_, err = keyring.LoadEnv(nil, "")
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, keyring.ErrNoKeys)
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	key, err := keyring.GenerateKey()
+	assert.NoError(t, err)
+
+	keys := keyring.Single(key.Private)
+
+	set := keys.JWKS()
+	assert.Len(t, set.Keys, 1)
+
+	jwk := set.Keys[0]
+	assert.Equal(t, "OKP", jwk.Kty)
+	assert.Equal(t, "Ed25519", jwk.Crv)
+	assert.Equal(t, key.ID, jwk.Kid)
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(key.Public), x)
+}
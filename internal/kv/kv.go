@@ -37,6 +37,25 @@ type KVStorer interface {
 	SetValue(ctx context.Context, key string, v any) error
 	SetValueEx(ctx context.Context, key string, v any, ttl time.Duration) error
 
+	// Increments the integer stored at key, returning the new value.
+	// Keys that do not exist are created with the given ttl, while the
+	// ttl of existing ones is kept.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+
+	// Sets the value of the key only if it currently holds old, reporting
+	// whether it was swapped. The ttl of the key is replaced.
+	CompareAndSwap(
+		ctx context.Context,
+		key string,
+		old string,
+		value string,
+		ttl time.Duration,
+	) (bool, error)
+
+	// Returns the keys starting with the prefix. All the keys may be
+	// scanned, so it must not be used in hot paths.
+	Keys(ctx context.Context, prefix string) ([]string, error)
+
 	Delete(ctx context.Context, key string) error
 
 	io.Closer
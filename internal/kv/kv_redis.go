@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/valkey-io/valkey-go"
@@ -12,6 +14,33 @@ import (
 
 var _ KVStorer = &RedisKV{}
 
+// Escapes the special characters of the glob patterns of SCAN
+var globEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"?", `\?`,
+	"[", `\[`,
+	"]", `\]`,
+)
+
+// Increments the key, setting its ttl if it was just created, atomically
+var incrScript = valkey.NewLuaScript(`
+local v = redis.call("INCR", KEYS[1])
+if v == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return v
+`)
+
+// Sets the key only if it holds the expected value, atomically
+var compareAndSwapScript = valkey.NewLuaScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
 type RedisKV struct {
 	c valkey.Client
 }
@@ -142,6 +171,67 @@ func (r *RedisKV) SetValueEx(
 	return r.SetEx(ctx, key, utils.UnsafeString(value), ttl)
 }
 
+// Incr implements KVStorer.
+func (r *RedisKV) Incr(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+) (int64, error) {
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+
+	v, err := incrScript.Exec(ctx, r.c, []string{key}, []string{ms}).AsInt64()
+	if err != nil {
+		slog.Error("RedisKV: Incr: redis error", "error", err)
+	}
+	return v, err
+}
+
+// CompareAndSwap implements KVStorer.
+func (r *RedisKV) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	old string,
+	value string,
+	ttl time.Duration,
+) (bool, error) {
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+
+	v, err := compareAndSwapScript.Exec(
+		ctx,
+		r.c,
+		[]string{key},
+		[]string{old, value, ms},
+	).AsBool()
+	if err != nil {
+		slog.Error("RedisKV: CompareAndSwap: redis error", "error", err)
+	}
+	return v, err
+}
+
+// Keys implements KVStorer.
+func (r *RedisKV) Keys(ctx context.Context, prefix string) ([]string, error) {
+	pattern := globEscaper.Replace(prefix) + "*"
+
+	keys := []string{}
+
+	var cursor uint64
+	for {
+		cmd := r.c.B().Scan().Cursor(cursor).Match(pattern).Count(256).Build()
+		entry, err := r.c.Do(ctx, cmd).AsScanEntry()
+		if err != nil {
+			slog.Error("RedisKV: Keys: redis error", "error", err)
+			return nil, err
+		}
+
+		keys = append(keys, entry.Elements...)
+		if cursor = entry.Cursor; cursor == 0 {
+			break
+		}
+	}
+
+	return keys, nil
+}
+
 // Delete implements KVStorer.
 func (r *RedisKV) Delete(ctx context.Context, key string) error {
 	cmd := r.c.B().Del().Key(key).Build()
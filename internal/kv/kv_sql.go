@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -177,6 +178,76 @@ func (r *SqlKV) SetValueEx(
 	return r.SetEx(ctx, key, utils.UnsafeString(value), ttl)
 }
 
+// Incr implements KVStorer.
+func (r *SqlKV) Incr(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+) (int64, error) {
+	sttm, err := r.q.Incr()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	exp := now.Add(ttl).Unix()
+
+	var value string
+	err = sttm.QueryRowContext(ctx, key, exp, now.Unix()).Scan(&value)
+	if err != nil {
+		slog.Error("SqlKV: Incr: sql error", "error", err)
+		return 0, err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// CompareAndSwap implements KVStorer.
+func (r *SqlKV) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	old string,
+	value string,
+	ttl time.Duration,
+) (bool, error) {
+	sttm, err := r.q.CompareAndSwap()
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	exp := now.Add(ttl).Unix()
+
+	res, err := sttm.ExecContext(ctx, value, exp, key, old, now.Unix())
+	if err != nil {
+		slog.Error("SqlKV: CompareAndSwap: sql error", "error", err)
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		slog.Error("SqlKV: CompareAndSwap: sql error", "error", err)
+		return false, err
+	}
+	return rows != 0, nil
+}
+
+// Keys implements KVStorer.
+func (r *SqlKV) Keys(ctx context.Context, prefix string) ([]string, error) {
+	sttm, err := r.q.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+
+	now := time.Now().Unix()
+	if err = sttm.SelectContext(ctx, &keys, prefix, now); err != nil {
+		slog.Error("SqlKV: Keys: sql error", "error", err)
+	}
+	return keys, err
+}
+
 // Delete implements KVStorer.
 func (r *SqlKV) Delete(ctx context.Context, key string) error {
 	sttm, err := r.q.Delete()
@@ -28,6 +28,30 @@ SET value = $2, expiry = $3`
 const kvSetQuerySQLITE = `INSERT OR REPLACE INTO keyvalue
 (key, value, expiry) VALUES ($1, $2, $3)`
 
+const kvIncrQueryPG = `INSERT INTO keyvalue
+(key, value, expiry) VALUES ($1, '1', $2)
+ON CONFLICT (key) DO UPDATE
+SET value = CASE WHEN keyvalue.expiry <= $3 THEN '1'
+ELSE CAST(CAST(keyvalue.value AS bigint) + 1 AS text) END,
+expiry = CASE WHEN keyvalue.expiry <= $3 THEN $2 ELSE keyvalue.expiry END
+RETURNING value`
+
+const kvIncrQuerySQLITE = `INSERT INTO keyvalue
+(key, value, expiry) VALUES ($1, '1', $2)
+ON CONFLICT (key) DO UPDATE
+SET value = CASE WHEN keyvalue.expiry <= $3 THEN '1'
+ELSE CAST(CAST(keyvalue.value AS integer) + 1 AS text) END,
+expiry = CASE WHEN keyvalue.expiry <= $3 THEN $2 ELSE keyvalue.expiry END
+RETURNING value`
+
+const kvCompareAndSwapQuery = `UPDATE keyvalue
+SET value = $1, expiry = $2
+WHERE key = $3 AND value = $4 AND (expiry IS NULL OR expiry > $5)`
+
+const kvKeysQuery = `SELECT key
+FROM keyvalue
+WHERE substr(key, 1, length($1)) = $1 AND (expiry IS NULL OR expiry > $2)`
+
 const kvDeleteQuery = `DELETE FROM keyvalue
 WHERE key = $1 AND (expiry IS NULL OR expiry > $2)`
 
@@ -45,13 +69,18 @@ func newKvQueries(db *sqlx.DB) kvQueries {
 	q.Add(kvGetQuery, "Get")
 	q.Add(kvGetExQuery, "GetEx")
 
+	q.Add(kvCompareAndSwapQuery, "CompareAndSwap")
+	q.Add(kvKeysQuery, "Keys")
+
 	q.Add(kvDeleteQuery, "Delete")
 	q.Add(kvCleanupQuery, "Cleanup")
 
 	if strings.Contains(db.DriverName(), "sqlite") {
 		q.Add(kvSetQuerySQLITE, "Set")
+		q.Add(kvIncrQuerySQLITE, "Incr")
 	} else {
 		q.Add(kvSetQueryPG, "Set")
+		q.Add(kvIncrQueryPG, "Incr")
 	}
 
 	return kvQueries{q}
@@ -69,10 +98,22 @@ func (q *kvQueries) GetEx() (*sqlx.Stmt, error) {
 	return q.Get("GetEx")
 }
 
+func (q *kvQueries) CompareAndSwap() (*sqlx.Stmt, error) {
+	return q.Get("CompareAndSwap")
+}
+
+func (q *kvQueries) Keys() (*sqlx.Stmt, error) {
+	return q.Get("Keys")
+}
+
 func (q *kvQueries) Set() (*sqlx.Stmt, error) {
 	return q.Get("Set")
 }
 
+func (q *kvQueries) Incr() (*sqlx.Stmt, error) {
+	return q.Get("Incr")
+}
+
 func (q *kvQueries) Delete() (*sqlx.Stmt, error) {
 	return q.Get("Delete")
 }
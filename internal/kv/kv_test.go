@@ -163,6 +163,89 @@ func TestKv(t *testing.T) {
 		assert.Error(t, err)
 		assert.ErrorIs(t, err, kv.ErrValueNotFound)
 	})
+
+	t.Run("Keys", func(t *testing.T) {
+		t.Parallel()
+
+		prefix := randString(32) + "/*"
+
+		keys := []string{prefix + "a", prefix + "b"}
+		for _, key := range keys {
+			assert.NoError(t, repo.Set(context.Background(), key, randString(8)))
+		}
+		assert.NoError(t, repo.Set(context.Background(), randString(32)+"/*c", "x"))
+
+		expired := prefix + "c"
+		err := repo.SetEx(context.Background(), expired, "x", time.Second)
+		assert.NoError(t, err)
+
+		time.Sleep(2 * time.Second)
+
+		keys2, err := repo.Keys(context.Background(), prefix)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, keys, keys2)
+
+		keys2, err = repo.Keys(context.Background(), randString(32))
+		assert.NoError(t, err)
+		assert.Empty(t, keys2)
+	})
+
+	t.Run("CompareAndSwap", func(t *testing.T) {
+		t.Parallel()
+
+		key := randString(48)
+
+		ok, err := repo.CompareAndSwap(context.Background(), key, "", "a", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		err = repo.SetEx(context.Background(), key, "a", time.Minute)
+		assert.NoError(t, err)
+
+		ok, err = repo.CompareAndSwap(context.Background(), key, "b", "c", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		ok, err = repo.CompareAndSwap(context.Background(), key, "a", "b", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		value, err := repo.Get(context.Background(), key)
+		assert.NoError(t, err)
+		assert.Equal(t, "b", value)
+
+		// The first swap already replaced the value
+		ok, err = repo.CompareAndSwap(context.Background(), key, "a", "c", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Incr", func(t *testing.T) {
+		t.Parallel()
+
+		key := randString(48)
+
+		for i := int64(1); i <= 3; i++ {
+			v, err := repo.Incr(context.Background(), key, time.Second)
+			assert.NoError(t, err)
+			assert.Equal(t, i, v)
+		}
+
+		value, err := repo.Get(context.Background(), key)
+		assert.NoError(t, err)
+		assert.Equal(t, "3", value)
+
+		time.Sleep(2 * time.Second)
+
+		exists, err := repo.Exists(context.Background(), key)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
+		// Expired keys are started over
+		v, err := repo.Incr(context.Background(), key, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), v)
+	})
 }
 
 func randString(n int) string {
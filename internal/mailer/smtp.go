@@ -0,0 +1,134 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+)
+
+var _ Mailer = &SMTPMailer{}
+
+type SMTPMailer struct {
+	host string
+	addr string
+	auth smtp.Auth
+	// Whether to use implicit tls, otherwise STARTTLS is
+	// used if the server supports it
+	tls bool
+
+	from     string
+	fromAddr string
+}
+
+func NewSMTPMailer(u *url.URL, from string) (*SMTPMailer, error) {
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("parse mailer from address: %s", err)
+	}
+
+	port := u.Port()
+	if port == "" {
+		if u.Scheme == "smtps" {
+			port = "465"
+		} else {
+			port = "587"
+		}
+	}
+
+	m := &SMTPMailer{
+		host:     u.Hostname(),
+		addr:     net.JoinHostPort(u.Hostname(), port),
+		tls:      u.Scheme == "smtps",
+		from:     from,
+		fromAddr: fromAddr.Address,
+	}
+
+	if u.User != nil {
+		password, _ := u.User.Password()
+		m.auth = smtp.PlainAuth("", u.User.Username(), password, m.host)
+	}
+
+	return m, nil
+}
+
+// Send implements Mailer.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("parse recipient address: %s", err)
+	}
+
+	err = m.send(ctx, to.Address, msg.Bytes(m.from))
+	if err != nil {
+		slog.Error("SMTPMailer: Send: smtp error", "error", err)
+	}
+	return err
+}
+
+func (m *SMTPMailer) send(ctx context.Context, to string, msg []byte) error {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if m.tls {
+		dialer := tls.Dialer{Config: &tls.Config{ServerName: m.host}}
+		conn, err = dialer.DialContext(ctx, "tcp", m.addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", m.addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if !m.tls {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			err = c.StartTLS(&tls.Config{ServerName: m.host})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if m.auth != nil {
+		if err = c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+
+	if err = c.Mail(m.fromAddr); err != nil {
+		return err
+	}
+	if err = c.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
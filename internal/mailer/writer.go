@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+var _ Mailer = &WriterMailer{}
+
+// Writes the messages to w instead of sending them,
+// for local development and tests.
+type WriterMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewWriterMailer(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{w: w, from: from}
+}
+
+// Send implements Mailer.
+func (m *WriterMailer) Send(ctx context.Context, msg Message) error {
+	b := msg.Bytes(m.from)
+	b = append(b, "\r\n\r\n"...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.w.Write(b)
+	return err
+}
@@ -12,6 +12,41 @@ import (
 	"github.com/zanz1n/blog/internal/utils"
 )
 
+// The result of running a markdown document through the whole pipeline.
+type Article struct {
+	Indexing   dto.ArticleIndexing
+	Content    dto.ArticleContent
+	RawContent dto.ArticleRawContent
+	// Declared in the front matter, nil if not
+	Tags []string
+
+	// Number of headings that could not be indexed.
+	Warnings int
+}
+
+// Parses, indexes and renders a markdown document.
+func ParseArticle(r io.Reader) (Article, error) {
+	doc, err := ParseDocument(r)
+	if err != nil {
+		return Article{}, err
+	}
+
+	idx, warnings := doc.Index()
+
+	content, err := doc.Render()
+	if err != nil {
+		return Article{}, err
+	}
+
+	return Article{
+		Indexing:   idx,
+		Content:    content,
+		RawContent: doc.Source(),
+		Tags:       doc.FrontMatter().Tags,
+		Warnings:   warnings,
+	}, nil
+}
+
 func ParseDocument(r io.Reader) (*Document, error) {
 	src := bytes.NewBuffer([]byte{})
 
@@ -20,29 +55,48 @@ func ParseDocument(r io.Reader) (*Document, error) {
 		return nil, err
 	}
 
-	rd := text.NewReader(src.Bytes())
+	fm, offset, err := parseFrontMatter(src.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	rd := text.NewReader(src.Bytes()[offset:])
 	tree := md.Parser().Parse(rd)
 
 	return &Document{
-		src:  src,
-		tree: tree,
+		src:    src,
+		offset: offset,
+		fm:     fm,
+		tree:   tree,
 	}, nil
 }
 
 type Document struct {
-	src  *bytes.Buffer
-	dst  *bytes.Buffer
-	tree ast.Node
+	src *bytes.Buffer
+	// Where the body starts, after the front matter
+	offset int
+	fm     FrontMatter
+	dst    *bytes.Buffer
+	tree   ast.Node
 }
 
 func (d *Document) Tree() ast.Node {
 	return d.tree
 }
 
+// Returns the whole source, front matter included.
 func (d *Document) Source() []byte {
 	return d.src.Bytes()
 }
 
+func (d *Document) FrontMatter() FrontMatter {
+	return d.fm
+}
+
+func (d *Document) body() []byte {
+	return d.src.Bytes()[d.offset:]
+}
+
 func (d *Document) Index() (idx dto.ArticleIndexing, warnings int) {
 	idx = dto.ArticleIndexing{}
 	warnings = 0
@@ -70,7 +124,7 @@ func (d *Document) Index() (idx dto.ArticleIndexing, warnings int) {
 		headingType := dto.HeadingType(nodeh.Level)
 
 		lines := node.Lines()
-		name := utils.UnsafeString(lines.Value(d.Source()))
+		name := utils.UnsafeString(lines.Value(d.body()))
 
 		idaatr := fmt.Sprintf("idx-%d-%d", headingType, headingC)
 		node.SetAttributeString("id", idaatr)
@@ -91,7 +145,7 @@ func (d *Document) Render() (dto.ArticleContent, error) {
 	}
 
 	d.dst = bytes.NewBuffer([]byte{})
-	err := md.Renderer().Render(d.dst, d.Source(), d.tree)
+	err := md.Renderer().Render(d.dst, d.body(), d.tree)
 	if err != nil {
 		d.dst = nil
 		return nil, err
@@ -0,0 +1,83 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zanz1n/blog/internal/utils/errutils"
+	"gopkg.in/yaml.v3"
+)
+
+const frontMatterDelim = "---"
+
+// The yaml metadata that may open a markdown document,
+// delimited by `---` lines.
+type FrontMatter struct {
+	// Either a list or a comma separated string, nil if not declared
+	Tags TagList `yaml:"tags"`
+}
+
+type TagList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *TagList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = strings.Split(value.Value, ",")
+		return nil
+	}
+
+	var tags []string
+	if err := value.Decode(&tags); err != nil {
+		return err
+	}
+	*l = tags
+	return nil
+}
+
+func invalidFrontMatter(err error) error {
+	return errutils.NewHttp(
+		fmt.Errorf("invalid front matter: %s", err),
+		http.StatusUnprocessableEntity,
+		http.StatusUnprocessableEntity,
+		true,
+	)
+}
+
+// Splits the front matter from the body of src, returning the offset
+// of the body in it. Blocks that are not a yaml mapping are left in the
+// body, being markdown like a thematic break followed by a heading.
+func parseFrontMatter(src []byte) (FrontMatter, int, error) {
+	var fm FrontMatter
+
+	line, rest, ok := bytes.Cut(src, []byte{'\n'})
+	if !ok || string(bytes.TrimRight(line, " \r")) != frontMatterDelim {
+		return fm, 0, nil
+	}
+
+	start := len(line) + 1
+	offset := start
+	for len(rest) != 0 {
+		line, rest, _ = bytes.Cut(rest, []byte{'\n'})
+
+		if string(bytes.TrimRight(line, " \r")) == frontMatterDelim {
+			var doc yaml.Node
+			err := yaml.Unmarshal(src[start:offset], &doc)
+			if err != nil || len(doc.Content) == 0 ||
+				doc.Content[0].Kind != yaml.MappingNode {
+				return FrontMatter{}, 0, nil
+			}
+
+			if err = doc.Decode(&fm); err != nil {
+				return fm, 0, invalidFrontMatter(err)
+			}
+
+			return fm, min(offset+len(line)+1, len(src)), nil
+		}
+		offset += len(line) + 1
+	}
+
+	// Not closed, so it is just a thematic break
+	return FrontMatter{}, 0, nil
+}
@@ -94,6 +94,23 @@ func TestMarkdownDoc(t *testing.T) {
 	}
 }
 
+func TestMarkdownArticle(t *testing.T) {
+	const source = "# Title\n\nSome text.\n\n## Section\n\n```go\nfmt.Println()\n```\n"
+
+	article, err := markdown.ParseArticle(bytes.NewReader([]byte(source)))
+	require.NoError(t, err)
+
+	require.Equal(t, 0, article.Warnings)
+	require.Equal(t, dto.ArticleRawContent(source), article.RawContent)
+	require.Equal(t, dto.ArticleIndexing{
+		{Head: dto.HeadingTypeH1, Name: "Title", ID: "idx-1-1"},
+		{Head: dto.HeadingTypeH2, Name: "Section", ID: "idx-2-2"},
+	}, article.Indexing)
+
+	require.Contains(t, string(article.Content), `id="idx-1-1"`)
+	require.Contains(t, string(article.Content), `id="idx-2-2"`)
+}
+
 func BenchmarkMarkdown(b *testing.B) {
 	res, err := http.Get(testCases[0].path)
 	require.NoError(b, err)
@@ -132,3 +149,72 @@ func BenchmarkMarkdown(b *testing.B) {
 		}
 	})
 }
+
+func TestFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		tags    []string
+		heading string
+	}{
+		{
+			name:    "List",
+			src:     "---\ntitle: Hello\ntags: [go, Web Dev]\n---\n# Heading\n",
+			tags:    []string{"go", "Web Dev"},
+			heading: "Heading",
+		},
+		{
+			name:    "String",
+			src:     "---\r\ntags: go, sql\r\n---\r\n# Heading\r\n",
+			tags:    []string{"go", " sql"},
+			heading: "Heading",
+		},
+		{
+			name:    "NoTags",
+			src:     "---\ntitle: Hello\n---\n# Heading",
+			heading: "Heading",
+		},
+		{
+			name:    "NotClosed",
+			src:     "---\n# Heading\n",
+			heading: "Heading",
+		},
+		{
+			name:    "None",
+			src:     "# Heading\n\n---\n\nSome tags: go, sql\n",
+			heading: "Heading",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			article, err := markdown.ParseArticle(bytes.NewReader([]byte(test.src)))
+			require.NoError(t, err)
+
+			require.Equal(t, test.tags, article.Tags)
+			require.Equal(t, test.src, string(article.RawContent))
+
+			require.Len(t, article.Indexing, 1)
+			require.Equal(t, test.heading, article.Indexing[0].Name)
+			if test.tags != nil {
+				require.NotContains(t, string(article.Content), "tags")
+			}
+		})
+	}
+
+	t.Run("ThematicBreak", func(t *testing.T) {
+		src := "---\nIntro\n---\n"
+		article, err := markdown.ParseArticle(bytes.NewReader([]byte(src)))
+		require.NoError(t, err)
+
+		require.Nil(t, article.Tags)
+		require.Len(t, article.Indexing, 1)
+		require.Equal(t, "Intro", article.Indexing[0].Name)
+		require.Contains(t, string(article.Content), "<hr")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := markdown.ParseArticle(bytes.NewReader([]byte("---\ntags: {go: sql}\n---\n")))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,210 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/zanz1n/blog/internal/kv"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+	"golang.org/x/oauth2"
+)
+
+const (
+	_ = 9000 + iota
+
+	CodeStateInvalid
+	CodeLoginFailed
+)
+
+var (
+	ErrInvalidState = errutils.NewHttpS(
+		"The login request expired, try again",
+		http.StatusBadRequest,
+		CodeStateInvalid,
+		true,
+	)
+	ErrLoginFailed = errutils.NewHttpS(
+		"Failed to log in with the identity provider",
+		http.StatusUnauthorized,
+		CodeLoginFailed,
+		true,
+	)
+)
+
+// Time users have to log in on the identity provider
+const StateExpiry = 10 * time.Minute
+
+type Config struct {
+	// The url the provider configuration is discovered from
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// The url of the callback the provider redirects users to
+	RedirectURL string
+	Scopes      []string
+}
+
+// The claims of the id token of an authenticated user.
+type Claims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// The state of a pending login, kept until the provider redirects
+// the user back.
+type loginState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// Logs users in through an OpenID Connect provider, using the
+// authorization code flow with PKCE.
+type Provider struct {
+	issuer   string
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+
+	kv kv.KVStorer
+}
+
+// Discovers the configuration of the provider.
+func New(ctx context.Context, cfg Config, kv kv.KVStorer) (*Provider, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discover provider: %w", err)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	return &Provider{
+		issuer: cfg.Issuer,
+		config: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		kv:       kv,
+	}, nil
+}
+
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+// Starts a login, returning the url of the provider users
+// must be redirected to and the state of the login, that must be
+// bound to the browser of the user.
+func (p *Provider) AuthURL(ctx context.Context) (url, state string, err error) {
+	state = randomString()
+	ls := loginState{
+		Nonce:    randomString(),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	err = p.kv.SetValueEx(ctx, stateKey(state), ls, StateExpiry)
+	if err != nil {
+		return "", "", err
+	}
+
+	url = p.config.AuthCodeURL(
+		state,
+		oauth2.S256ChallengeOption(ls.Verifier),
+		oidc.Nonce(ls.Nonce),
+	)
+	return url, state, nil
+}
+
+// Finishes the login the state belongs to, exchanging the code for
+// the verified claims of the user.
+func (p *Provider) Exchange(ctx context.Context, state, code string) (Claims, error) {
+	var claims Claims
+
+	ls, err := p.takeState(ctx, state)
+	if err != nil {
+		return claims, err
+	}
+
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(ls.Verifier))
+	if err != nil {
+		slog.Debug("oidc: Exchange: failed to exchange code", "error", err)
+		return claims, ErrLoginFailed
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		slog.Debug("oidc: Exchange: missing id token")
+		return claims, ErrLoginFailed
+	}
+
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		slog.Debug("oidc: Exchange: invalid id token", "error", err)
+		return claims, ErrLoginFailed
+	}
+
+	if idToken.Nonce != ls.Nonce {
+		slog.Debug("oidc: Exchange: nonce mismatch")
+		return claims, ErrLoginFailed
+	}
+
+	if err = idToken.Claims(&claims); err != nil {
+		slog.Debug("oidc: Exchange: invalid claims", "error", err)
+		return claims, ErrLoginFailed
+	}
+
+	return claims, nil
+}
+
+// Fetches the state of a pending login, making sure it can not be
+// completed again.
+func (p *Provider) takeState(ctx context.Context, state string) (loginState, error) {
+	var ls loginState
+
+	if state == "" {
+		return ls, ErrInvalidState
+	}
+
+	err := p.kv.GetValue(ctx, stateKey(state), &ls)
+	if err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = ErrInvalidState
+		}
+		return ls, err
+	}
+
+	// Only one of concurrent requests succeeds deleting it
+	if err = p.kv.Delete(ctx, stateKey(state)); err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = ErrInvalidState
+		}
+		return ls, err
+	}
+
+	return ls, nil
+}
+
+func stateKey(state string) string {
+	return fmt.Sprintf("oidc_state/%s", state)
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,282 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/coreos/go-oidc/v3/oidc/oidctest"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/kv"
+	"github.com/zanz1n/blog/internal/oidc"
+	"github.com/zanz1n/blog/internal/utils"
+)
+
+const (
+	clientId     = "blog"
+	clientSecret = "secret"
+	redirectUrl  = "http://localhost:8080/auth/oidc/callback"
+	keyId        = "test"
+)
+
+func kvRepo(t *testing.T) kv.KVStorer {
+	db, err := sqlx.Open("sqlite3", "file::memory:")
+	assert.NoError(t, err)
+
+	err = utils.MigrateUp(db)
+	assert.NoError(t, err)
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return kv.NewSqlKV(db)
+}
+
+// The authorization request the stub provider received.
+type authRequest struct {
+	challenge string
+	nonce     string
+}
+
+// A stub identity provider that logs in a single account without
+// asking anything, issuing id tokens signed with a rsa key.
+type stubIdP struct {
+	t   *testing.T
+	key *rsa.PrivateKey
+	srv *httptest.Server
+
+	// Overrides the nonce of the issued id tokens, if set
+	nonce string
+
+	mu    sync.Mutex
+	codes map[string]authRequest
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	idp := &stubIdP{t: t, key: key, codes: map[string]authRequest{}}
+
+	discovery := &oidctest.Server{
+		PublicKeys: []oidctest.PublicKey{{
+			PublicKey: key.Public(),
+			KeyID:     keyId,
+			Algorithm: gooidc.RS256,
+		}},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/openid-configuration", discovery)
+	mux.Handle("/keys", discovery)
+	mux.HandleFunc("/auth", idp.auth)
+	mux.HandleFunc("/token", idp.token)
+
+	idp.srv = httptest.NewServer(mux)
+	discovery.SetIssuer(idp.srv.URL)
+	t.Cleanup(idp.srv.Close)
+
+	return idp
+}
+
+// Redirects back to the client with a code, as if the user
+// had logged in.
+func (idp *stubIdP) auth(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != clientId ||
+		q.Get("redirect_uri") != redirectUrl ||
+		q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code := base64.RawURLEncoding.EncodeToString([]byte(q.Get("state")))
+
+	idp.mu.Lock()
+	idp.codes[code] = authRequest{
+		challenge: q.Get("code_challenge"),
+		nonce:     q.Get("nonce"),
+	}
+	idp.mu.Unlock()
+
+	callback, _ := url.Parse(redirectUrl)
+	callback.RawQuery = url.Values{
+		"code":  {code},
+		"state": {q.Get("state")},
+	}.Encode()
+
+	http.Redirect(w, r, callback.String(), http.StatusFound)
+}
+
+func (idp *stubIdP) token(w http.ResponseWriter, r *http.Request) {
+	user, pass, _ := r.BasicAuth()
+	if user != clientId || pass != clientSecret {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	idp.mu.Lock()
+	req, ok := idp.codes[r.FormValue("code")]
+	delete(idp.codes, r.FormValue("code"))
+	idp.mu.Unlock()
+
+	hash := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	if !ok || challenge != req.challenge {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	nonce := req.nonce
+	if idp.nonce != "" {
+		nonce = idp.nonce
+	}
+
+	claims, _ := json.Marshal(map[string]any{
+		"iss":                idp.srv.URL,
+		"aud":                clientId,
+		"sub":                "1234",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"email":              "user@example.com",
+		"email_verified":     true,
+		"name":               "Test User",
+		"preferred_username": "test",
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     oidctest.SignIDToken(idp.key, keyId, gooidc.RS256, string(claims)),
+	})
+}
+
+// Follows the redirect to the provider, returning the callback
+// query parameters.
+func (idp *stubIdP) login(t *testing.T, authUrl string) url.Values {
+	client := http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(authUrl)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+
+	loc, err := url.Parse(res.Header.Get("Location"))
+	assert.NoError(t, err)
+	return loc.Query()
+}
+
+func provider(t *testing.T, idp *stubIdP) *oidc.Provider {
+	p, err := oidc.New(context.Background(), oidc.Config{
+		Issuer:       idp.srv.URL,
+		ClientID:     clientId,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectUrl,
+	}, kvRepo(t))
+	assert.NoError(t, err)
+	assert.Equal(t, idp.srv.URL, p.Issuer())
+
+	return p
+}
+
+func TestExchange(t *testing.T) {
+	t.Parallel()
+	idp := newStubIdP(t)
+	p := provider(t, idp)
+
+	authUrl, _, err := p.AuthURL(context.Background())
+	assert.NoError(t, err)
+
+	q := idp.login(t, authUrl)
+
+	claims, err := p.Exchange(context.Background(), q.Get("state"), q.Get("code"))
+	assert.NoError(t, err)
+	assert.Equal(t, oidc.Claims{
+		Subject:           "1234",
+		Email:             "user@example.com",
+		EmailVerified:     true,
+		Name:              "Test User",
+		PreferredUsername: "test",
+	}, claims)
+
+	t.Run("Replay", func(t *testing.T) {
+		_, err := p.Exchange(context.Background(), q.Get("state"), q.Get("code"))
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+
+	t.Run("UnknownState", func(t *testing.T) {
+		authUrl, _, err := p.AuthURL(context.Background())
+		assert.NoError(t, err)
+
+		q := idp.login(t, authUrl)
+
+		_, err = p.Exchange(context.Background(), "unknown", q.Get("code"))
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+
+	t.Run("WrongCode", func(t *testing.T) {
+		authUrl, _, err := p.AuthURL(context.Background())
+		assert.NoError(t, err)
+
+		q := idp.login(t, authUrl)
+
+		_, err = p.Exchange(context.Background(), q.Get("state"), "wrong")
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, oidc.ErrLoginFailed)
+	})
+
+	t.Run("WrongVerifier", func(t *testing.T) {
+		authUrl, _, err := p.AuthURL(context.Background())
+		assert.NoError(t, err)
+		other, _, err := p.AuthURL(context.Background())
+		assert.NoError(t, err)
+
+		q := idp.login(t, authUrl)
+		otherState := idp.login(t, other).Get("state")
+
+		// The code was issued for the challenge of another login
+		_, err = p.Exchange(context.Background(), otherState, q.Get("code"))
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, oidc.ErrLoginFailed)
+	})
+}
+
+func TestExchangeWrongNonce(t *testing.T) {
+	t.Parallel()
+	idp := newStubIdP(t)
+	idp.nonce = "replayed"
+	p := provider(t, idp)
+
+	authUrl, _, err := p.AuthURL(context.Background())
+	assert.NoError(t, err)
+
+	q := idp.login(t, authUrl)
+
+	_, err = p.Exchange(context.Background(), q.Get("state"), q.Get("code"))
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, oidc.ErrLoginFailed)
+}
@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/zanz1n/blog/internal/kv"
+)
+
+// Locks keys temporarily after repeated failures, each further failure
+// doubling the lock duration.
+//
+// A nil Backoff never locks anything.
+type Backoff struct {
+	kv   kv.KVStorer
+	name string
+
+	// Failures allowed before the key gets locked
+	free int64
+	base time.Duration
+	max  time.Duration
+	// How long failures are remembered for
+	memory time.Duration
+}
+
+func NewBackoff(
+	kv kv.KVStorer,
+	name string,
+	free int64,
+	base time.Duration,
+	max time.Duration,
+	memory time.Duration,
+) *Backoff {
+	return &Backoff{
+		kv:     kv,
+		name:   name,
+		free:   free,
+		base:   base,
+		max:    max,
+		memory: memory,
+	}
+}
+
+// Fails with a 429 error if the key is locked.
+func (b *Backoff) Check(ctx context.Context, key string) error {
+	if b == nil {
+		return nil
+	}
+
+	value, err := b.kv.Get(ctx, b.lockKey(key))
+	if err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = nil
+		}
+		return err
+	}
+
+	until, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	retryAfter := time.Until(time.UnixMilli(until))
+	if retryAfter <= 0 {
+		return nil
+	}
+
+	return rateLimited(retryAfter)
+}
+
+// Counts a failure of the key, locking it if there were too many.
+func (b *Backoff) Fail(ctx context.Context, key string) error {
+	if b == nil {
+		return nil
+	}
+
+	failures, err := b.kv.Incr(ctx, b.failuresKey(key), b.memory)
+	if err != nil {
+		return err
+	}
+
+	if failures < b.free {
+		return nil
+	}
+
+	lock := b.max
+	if n := failures - b.free; n < 32 {
+		lock = min(b.base<<n, b.max)
+	}
+
+	until := time.Now().Add(lock).UnixMilli()
+	return b.kv.SetEx(
+		ctx,
+		b.lockKey(key),
+		strconv.FormatInt(until, 10),
+		lock,
+	)
+}
+
+// Forgets the failures of the key, usually after a success.
+func (b *Backoff) Reset(ctx context.Context, key string) error {
+	if b == nil {
+		return nil
+	}
+
+	err := b.kv.Delete(ctx, b.failuresKey(key))
+	if errors.Is(err, kv.ErrValueNotFound) {
+		err = nil
+	}
+	return err
+}
+
+func (b *Backoff) failuresKey(key string) string {
+	return fmt.Sprintf("backoff/%s/failures/%s", b.name, key)
+}
+
+func (b *Backoff) lockKey(key string) string {
+	return fmt.Sprintf("backoff/%s/lock/%s", b.name, key)
+}
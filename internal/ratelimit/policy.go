@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/zanz1n/blog/internal/kv"
+)
+
+// Limits the number of hits on each key within fixed windows of time.
+//
+// A nil FixedWindow allows everything.
+type FixedWindow struct {
+	kv    kv.KVStorer
+	name  string
+	limit Limit
+}
+
+func NewFixedWindow(kv kv.KVStorer, name string, limit Limit) *FixedWindow {
+	return &FixedWindow{kv: kv, name: name, limit: limit}
+}
+
+// Take implements Policy.
+func (l *FixedWindow) Take(ctx context.Context, key string) (Status, error) {
+	if l == nil {
+		return Status{}, nil
+	}
+
+	now := time.Now()
+	window := now.UnixMilli() / l.limit.Window.Milliseconds()
+	end := time.UnixMilli((window + 1) * l.limit.Window.Milliseconds())
+
+	key = fmt.Sprintf("ratelimit/%s/%s/%d", l.name, key, window)
+	count, err := l.kv.Incr(ctx, key, l.limit.Window)
+	if err != nil {
+		return Status{}, err
+	}
+
+	status := Status{
+		Limit:     l.limit.Max,
+		Remaining: max(l.limit.Max-count, 0),
+		Reset:     end.Sub(now),
+	}
+
+	if count <= l.limit.Max {
+		return status, nil
+	}
+	return status, rateLimited(status.Reset)
+}
+
+// Allows bursts of up to Capacity hits on each key, one more hit being
+// allowed every Refill. Implemented as the generic cell rate algorithm,
+// which only stores the time the bucket will be full again.
+//
+// The state is read and written back without any locking, so concurrent
+// hits on the same key may be let through over the limit.
+//
+// A nil TokenBucket allows everything.
+type TokenBucket struct {
+	kv       kv.KVStorer
+	name     string
+	capacity int64
+	refill   time.Duration
+}
+
+func NewTokenBucket(
+	kv kv.KVStorer,
+	name string,
+	capacity int64,
+	refill time.Duration,
+) *TokenBucket {
+	return &TokenBucket{
+		kv:       kv,
+		name:     name,
+		capacity: capacity,
+		refill:   refill,
+	}
+}
+
+// Take implements Policy.
+func (l *TokenBucket) Take(ctx context.Context, key string) (Status, error) {
+	if l == nil {
+		return Status{}, nil
+	}
+
+	key = fmt.Sprintf("ratelimit/%s/%s", l.name, key)
+	now := time.Now()
+
+	// The time the bucket is full again
+	full := now
+	value, err := l.kv.Get(ctx, key)
+	if err == nil {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Status{}, err
+		}
+		full = time.UnixMilli(ms)
+	} else if !errors.Is(err, kv.ErrValueNotFound) {
+		return Status{}, err
+	}
+	if full.Before(now) {
+		full = now
+	}
+
+	burst := time.Duration(l.capacity) * l.refill
+
+	next := full.Add(l.refill)
+	if allowAt := next.Add(-burst); now.Before(allowAt) {
+		status := Status{
+			Limit:     l.capacity,
+			Remaining: 0,
+			Reset:     full.Sub(now),
+		}
+		return status, rateLimited(allowAt.Sub(now))
+	}
+
+	err = l.kv.SetEx(
+		ctx,
+		key,
+		strconv.FormatInt(next.UnixMilli(), 10),
+		next.Sub(now)+time.Second,
+	)
+	if err != nil {
+		return Status{}, err
+	}
+
+	return Status{
+		Limit:     l.capacity,
+		Remaining: int64((burst - next.Sub(now)) / l.refill),
+		Reset:     next.Sub(now),
+	}, nil
+}
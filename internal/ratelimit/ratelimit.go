@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zanz1n/blog/internal/kv"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+)
+
+const (
+	_ = 6000 + iota
+
+	CodeRateLimited
+)
+
+// Returns a 429 error telling the client when to retry.
+func rateLimited(retryAfter time.Duration) error {
+	secs := int64(math.Ceil(retryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+
+	err := errutils.NewHttpS(
+		fmt.Sprintf("Too many requests, try again in %s", time.Duration(secs)*time.Second),
+		http.StatusTooManyRequests,
+		CodeRateLimited,
+		true,
+	)
+	return errutils.WithHeader(err, "Retry-After", strconv.FormatInt(secs, 10))
+}
+
+// The state of a rate limited key after a hit.
+type Status struct {
+	Limit     int64
+	Remaining int64
+	// Time until the limit is replenished
+	Reset time.Duration
+}
+
+// A rate limiting algorithm whose state is stored in a kv.KVStorer.
+type Policy interface {
+	// Counts a hit on the key, failing with a 429 error carrying a
+	// Retry-After header if the limit was exceeded.
+	Take(ctx context.Context, key string) (Status, error)
+}
+
+var (
+	_ Policy = &Limiter{}
+	_ Policy = &FixedWindow{}
+	_ Policy = &TokenBucket{}
+)
+
+type Limit struct {
+	Max    int64
+	Window time.Duration
+}
+
+// Limits the rate of hits on each key using a sliding window, which is
+// approximated by weighting the count of the previous fixed window by
+// how much of it still overlaps the sliding one.
+//
+// A nil Limiter allows everything.
+type Limiter struct {
+	kv    kv.KVStorer
+	name  string
+	limit Limit
+}
+
+func NewLimiter(kv kv.KVStorer, name string, limit Limit) *Limiter {
+	return &Limiter{kv: kv, name: name, limit: limit}
+}
+
+// Counts a hit on the key, failing with a 429 error if the limit
+// was exceeded. Rejected hits are also counted.
+func (l *Limiter) Hit(ctx context.Context, key string) error {
+	_, err := l.Take(ctx, key)
+	return err
+}
+
+// Take implements Policy.
+func (l *Limiter) Take(ctx context.Context, key string) (Status, error) {
+	if l == nil {
+		return Status{}, nil
+	}
+
+	now := time.Now()
+	window := now.UnixMilli() / l.limit.Window.Milliseconds()
+	elapsed := now.Sub(time.UnixMilli(window * l.limit.Window.Milliseconds()))
+
+	curr, err := l.kv.Incr(ctx, l.windowKey(key, window), 2*l.limit.Window)
+	if err != nil {
+		return Status{}, err
+	}
+
+	prev, err := l.count(ctx, l.windowKey(key, window-1))
+	if err != nil {
+		return Status{}, err
+	}
+
+	overlap := 1 - float64(elapsed)/float64(l.limit.Window)
+	count := float64(prev)*overlap + float64(curr)
+
+	status := Status{
+		Limit:     l.limit.Max,
+		Remaining: max(l.limit.Max-int64(math.Ceil(count)), 0),
+		Reset:     l.limit.Window - elapsed,
+	}
+
+	if count <= float64(l.limit.Max) {
+		return status, nil
+	}
+
+	return status, rateLimited(l.retryAfter(prev, curr, elapsed))
+}
+
+// Returns how long it takes for the next hit to be allowed,
+// if no other hits are counted meanwhile.
+func (l *Limiter) retryAfter(prev, curr int64, elapsed time.Duration) time.Duration {
+	window := float64(l.limit.Window)
+	max := float64(l.limit.Max - 1)
+
+	// Within the current window, as the previous one slides out of it
+	if prev > 0 && float64(curr) <= max {
+		t := window * (1 - (max-float64(curr))/float64(prev))
+		return time.Duration(t) - elapsed
+	}
+
+	// Within the next window, as the current one slides out of it
+	t := window * (1 - max/float64(curr))
+	return time.Duration(window) - elapsed + time.Duration(t)
+}
+
+func (l *Limiter) count(ctx context.Context, key string) (int64, error) {
+	value, err := l.kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = nil
+		}
+		return 0, err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (l *Limiter) windowKey(key string, window int64) string {
+	return fmt.Sprintf("ratelimit/%s/%s/%d", l.name, key, window)
+}
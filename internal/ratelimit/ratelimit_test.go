@@ -0,0 +1,165 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/kv"
+	"github.com/zanz1n/blog/internal/ratelimit"
+	"github.com/zanz1n/blog/internal/utils"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+)
+
+func kvRepo(t *testing.T) kv.KVStorer {
+	db, err := sqlx.Open("sqlite3", "file::memory:")
+	assert.NoError(t, err)
+
+	err = utils.MigrateUp(db)
+	assert.NoError(t, err)
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return kv.NewSqlKV(db)
+}
+
+func assertRateLimited(t *testing.T, err error, maxRetry time.Duration) {
+	assert.Error(t, err)
+
+	herr, ok := err.(errutils.HttpHeaderError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, herr.HttpStatus())
+	assert.Equal(t, int32(ratelimit.CodeRateLimited), herr.ErrorCode())
+
+	secs, err := strconv.Atoi(herr.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, secs, 1)
+	assert.LessOrEqual(t, time.Duration(secs)*time.Second, maxRetry)
+}
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+	store := kvRepo(t)
+
+	limit := ratelimit.Limit{Max: 5, Window: time.Hour}
+	limiter := ratelimit.NewLimiter(store, "test", limit)
+
+	for range limit.Max {
+		err := limiter.Hit(context.Background(), "key1")
+		assert.NoError(t, err)
+	}
+
+	err := limiter.Hit(context.Background(), "key1")
+	assertRateLimited(t, err, 2*limit.Window)
+
+	t.Run("OtherKey", func(t *testing.T) {
+		err := limiter.Hit(context.Background(), "key2")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var limiter *ratelimit.Limiter
+		err := limiter.Hit(context.Background(), "key1")
+		assert.NoError(t, err)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	store := kvRepo(t)
+
+	const (
+		Free = 3
+		Base = time.Minute
+		Max  = 4 * time.Minute
+	)
+
+	backoff := ratelimit.NewBackoff(store, "test", Free, Base, Max, time.Hour)
+
+	for range Free - 1 {
+		assert.NoError(t, backoff.Check(context.Background(), "key"))
+		assert.NoError(t, backoff.Fail(context.Background(), "key"))
+	}
+	assert.NoError(t, backoff.Check(context.Background(), "key"))
+
+	retries := []time.Duration{Base, 2 * Base, Max, Max}
+	for _, retry := range retries {
+		assert.NoError(t, backoff.Fail(context.Background(), "key"))
+
+		err := backoff.Check(context.Background(), "key")
+		assertRateLimited(t, err, retry)
+
+		retryAfter := err.(errutils.HttpHeaderError).Header().Get("Retry-After")
+		assert.Equal(t, strconv.Itoa(int(retry.Seconds())), retryAfter)
+	}
+
+	t.Run("Reset", func(t *testing.T) {
+		err := backoff.Reset(context.Background(), "key2")
+		assert.NoError(t, err)
+
+		for range Free - 1 {
+			assert.NoError(t, backoff.Fail(context.Background(), "key2"))
+		}
+		assert.NoError(t, backoff.Reset(context.Background(), "key2"))
+
+		assert.NoError(t, backoff.Fail(context.Background(), "key2"))
+		assert.NoError(t, backoff.Check(context.Background(), "key2"))
+	})
+}
+
+func TestFixedWindow(t *testing.T) {
+	t.Parallel()
+	store := kvRepo(t)
+
+	limit := ratelimit.Limit{Max: 3, Window: time.Hour}
+	limiter := ratelimit.NewFixedWindow(store, "test", limit)
+
+	for i := range limit.Max {
+		status, err := limiter.Take(context.Background(), "key")
+		assert.NoError(t, err)
+		assert.Equal(t, limit.Max, status.Limit)
+		assert.Equal(t, limit.Max-i-1, status.Remaining)
+		assert.LessOrEqual(t, status.Reset, limit.Window)
+	}
+
+	status, err := limiter.Take(context.Background(), "key")
+	assertRateLimited(t, err, limit.Window)
+	assert.Equal(t, int64(0), status.Remaining)
+}
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+	store := kvRepo(t)
+
+	const (
+		Capacity = 3
+		Refill   = time.Second
+	)
+
+	limiter := ratelimit.NewTokenBucket(store, "test", Capacity, Refill)
+
+	for i := range int64(Capacity) {
+		status, err := limiter.Take(context.Background(), "key")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(Capacity), status.Limit)
+		assert.Equal(t, Capacity-i-1, status.Remaining)
+	}
+
+	status, err := limiter.Take(context.Background(), "key")
+	assertRateLimited(t, err, Refill)
+	assert.Equal(t, int64(0), status.Remaining)
+
+	time.Sleep(Refill)
+
+	status, err = limiter.Take(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), status.Remaining)
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+)
+
+const (
+	_ = 4000 + iota
+
+	CodeAccessTokenNotFound
+	CodeAccessTokenAlreadyExists
+	CodeAccessTokenInvalid
+	CodeAccessTokenExpired
+)
+
+var (
+	ErrAccessTokenNotFound = errutils.NewHttpS(
+		"Access token not found",
+		http.StatusNotFound,
+		CodeAccessTokenNotFound,
+		true,
+	)
+	ErrAccessTokenAlreadyExists = errutils.NewHttpS(
+		"Access token already exists",
+		http.StatusConflict,
+		CodeAccessTokenAlreadyExists,
+		true,
+	)
+	ErrInvalidAccessToken = errutils.NewHttpS(
+		"Access token invalid",
+		http.StatusUnauthorized,
+		CodeAccessTokenInvalid,
+		true,
+	)
+	ErrExpiredAccessToken = errutils.NewHttpS(
+		"Access token expired",
+		http.StatusUnauthorized,
+		CodeAccessTokenExpired,
+		true,
+	)
+)
+
+type AccessTokenRepository struct {
+	q accessTokenQueries
+}
+
+func NewAccessTokenRepository(db *sqlx.DB) *AccessTokenRepository {
+	return &AccessTokenRepository{
+		q: newAccessTokenQueries(db),
+	}
+}
+
+func (r *AccessTokenRepository) Create(ctx context.Context, token dto.AccessToken) error {
+	sttm, err := r.q.Create()
+	if err != nil {
+		return err
+	}
+
+	_, err = sttm.ExecContext(ctx,
+		token.ID,
+		token.CreatedAt,
+		token.UserID,
+		token.Name,
+		token.Permission,
+		token.ExpiresAt,
+		token.TokenHash,
+	)
+	if err != nil {
+		if isUniqueConstraintViolation(err) {
+			err = ErrAccessTokenAlreadyExists
+		} else {
+			slog.Error("AccessTokenRepository: Create: sql error", "error", err)
+		}
+	}
+	return err
+}
+
+func (r *AccessTokenRepository) Get(ctx context.Context, id dto.Snowflake) (dto.AccessToken, error) {
+	var token dto.AccessToken
+
+	sttm, err := r.q.GetQ()
+	if err != nil {
+		return token, err
+	}
+
+	if err = sttm.GetContext(ctx, &token, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrAccessTokenNotFound
+		} else {
+			slog.Error("AccessTokenRepository: Get: sql error", "error", err)
+		}
+	}
+	return token, err
+}
+
+// Fetches all the tokens of the user, newest first.
+func (r *AccessTokenRepository) GetByUser(
+	ctx context.Context,
+	userId dto.Snowflake,
+) ([]dto.AccessToken, error) {
+	sttm, err := r.q.GetByUser()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []dto.AccessToken{}
+	if err = sttm.SelectContext(ctx, &tokens, userId); err != nil {
+		slog.Error("AccessTokenRepository: GetByUser: sql error", "error", err)
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Looks up the token by the id encoded in it, failing if its hash
+// does not match the stored one or it is expired.
+func (r *AccessTokenRepository) Authenticate(
+	ctx context.Context,
+	token string,
+) (dto.AccessToken, error) {
+	id, ok := dto.ParseAccessToken(token)
+	if !ok {
+		return dto.AccessToken{}, ErrInvalidAccessToken
+	}
+
+	accessToken, err := r.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrAccessTokenNotFound) {
+			err = ErrInvalidAccessToken
+		}
+		return dto.AccessToken{}, err
+	}
+
+	if !accessToken.TokenMatches(token) {
+		return dto.AccessToken{}, ErrInvalidAccessToken
+	}
+	if accessToken.Expired() {
+		return dto.AccessToken{}, ErrExpiredAccessToken
+	}
+
+	return accessToken, nil
+}
+
+// Deletes the token, failing with ErrAccessTokenNotFound
+// if it does not belong to the user.
+func (r *AccessTokenRepository) Delete(
+	ctx context.Context,
+	id dto.Snowflake,
+	userId dto.Snowflake,
+) (dto.AccessToken, error) {
+	var token dto.AccessToken
+
+	sttm, err := r.q.Delete()
+	if err != nil {
+		return token, err
+	}
+
+	err = sttm.GetContext(ctx, &token, id, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrAccessTokenNotFound
+		} else {
+			slog.Error("AccessTokenRepository: Delete: sql error", "error", err)
+		}
+	}
+	return token, err
+}
+
+func (r *AccessTokenRepository) Close() error {
+	return r.q.Close()
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/utils"
+)
+
+const accessTokenCreateQuery = `INSERT INTO access_tokens
+VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+const accessTokenGetQuery = `SELECT * FROM access_tokens WHERE id = $1`
+
+const accessTokenGetByUserQuery = `SELECT * FROM access_tokens
+WHERE user_id = $1 ORDER BY id DESC`
+
+const accessTokenDeleteQuery = `DELETE FROM access_tokens
+WHERE id = $1 AND user_id = $2 RETURNING *`
+
+type accessTokenQueries struct {
+	*utils.Queries
+}
+
+func newAccessTokenQueries(db *sqlx.DB) accessTokenQueries {
+	q := utils.NewQueries(db, "AccessTokenQueries")
+
+	q.Add(accessTokenCreateQuery, "Create")
+	q.Add(accessTokenGetQuery, "Get")
+	q.Add(accessTokenGetByUserQuery, "GetByUser")
+	q.Add(accessTokenDeleteQuery, "Delete")
+
+	return accessTokenQueries{q}
+}
+
+func (q *accessTokenQueries) Create() (*sqlx.Stmt, error) {
+	return q.Get("Create")
+}
+
+func (q *accessTokenQueries) GetQ() (*sqlx.Stmt, error) {
+	return q.Get("Get")
+}
+
+func (q *accessTokenQueries) GetByUser() (*sqlx.Stmt, error) {
+	return q.Get("GetByUser")
+}
+
+func (q *accessTokenQueries) Delete() (*sqlx.Stmt, error) {
+	return q.Get("Delete")
+}
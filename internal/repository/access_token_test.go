@@ -0,0 +1,174 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+)
+
+func accessTokenRepo(t *testing.T) (
+	*repository.AccessTokenRepository,
+	*repository.UserRepository,
+) {
+	db := GetDb(t)
+	repo := repository.NewAccessTokenRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	return repo, userRepo
+}
+
+func accessTokenData(expiresIn int) dto.AccessTokenCreateData {
+	return dto.AccessTokenCreateData{
+		Name: randString(32),
+		Scopes: []dto.Permission{
+			dto.PermissionReadPosts,
+			dto.PermissionWritePosts,
+		},
+		ExpiresIn: expiresIn,
+	}
+}
+
+func TestAccessTokenCreate(t *testing.T) {
+	t.Parallel()
+	tokens, users := accessTokenRepo(t)
+
+	t.Run("Inexistent", func(t *testing.T) {
+		t.Parallel()
+		_, err := tokens.Get(context.Background(), dto.NewSnowflake())
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrAccessTokenNotFound)
+	})
+
+	token, _ := createAccessToken(t, tokens, users, 30)
+
+	t.Run("Duplicate", func(t *testing.T) {
+		err := tokens.Create(context.Background(), token)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrAccessTokenAlreadyExists)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		token2, err := tokens.Get(context.Background(), token.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, token, token2)
+		assert.Equal(
+			t,
+			dto.PermissionReadPosts|dto.PermissionWritePosts,
+			token2.Permission,
+		)
+	})
+
+	t.Run("NoExpiry", func(t *testing.T) {
+		token, _ := createAccessToken(t, tokens, users, 0)
+
+		token2, err := tokens.Get(context.Background(), token.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, token2.ExpiresAt)
+		assert.Equal(t, token, token2)
+	})
+}
+
+func TestAccessTokenAuthenticate(t *testing.T) {
+	t.Parallel()
+	tokens, users := accessTokenRepo(t)
+
+	token, raw := createAccessToken(t, tokens, users, 1)
+
+	t.Run("Valid", func(t *testing.T) {
+		token2, err := tokens.Authenticate(context.Background(), raw)
+		assert.NoError(t, err)
+		assert.Equal(t, token, token2)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := tokens.Authenticate(context.Background(), randString(64))
+		assert.ErrorIs(t, err, repository.ErrInvalidAccessToken)
+
+		_, err = tokens.Authenticate(context.Background(), raw[:len(raw)-1])
+		assert.ErrorIs(t, err, repository.ErrInvalidAccessToken)
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		b := []byte(raw)
+		if b[len(b)-1] == 'A' {
+			b[len(b)-1] = 'B'
+		} else {
+			b[len(b)-1] = 'A'
+		}
+
+		_, err := tokens.Authenticate(context.Background(), string(b))
+		assert.ErrorIs(t, err, repository.ErrInvalidAccessToken)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token, raw := dto.NewAccessToken(token.UserID, accessTokenData(0))
+		expiresAt := dto.Timestamp{Time: time.Now().Add(-time.Minute).Round(time.Millisecond)}
+		token.ExpiresAt = &expiresAt
+
+		err := tokens.Create(context.Background(), token)
+		assert.NoError(t, err)
+
+		_, err = tokens.Authenticate(context.Background(), raw)
+		assert.ErrorIs(t, err, repository.ErrExpiredAccessToken)
+	})
+}
+
+func TestAccessTokenDelete(t *testing.T) {
+	t.Parallel()
+	tokens, users := accessTokenRepo(t)
+
+	token, raw := createAccessToken(t, tokens, users, 0)
+	token2, _ := createAccessToken(t, tokens, users, 0)
+
+	t.Run("GetByUser", func(t *testing.T) {
+		list, err := tokens.GetByUser(context.Background(), token.UserID)
+		assert.NoError(t, err)
+		assert.Equal(t, []dto.AccessToken{token}, list)
+	})
+
+	t.Run("OtherUser", func(t *testing.T) {
+		_, err := tokens.Delete(context.Background(), token.ID, token2.UserID)
+		assert.ErrorIs(t, err, repository.ErrAccessTokenNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		token3, err := tokens.Delete(context.Background(), token.ID, token.UserID)
+		assert.NoError(t, err)
+		assert.Equal(t, token, token3)
+
+		_, err = tokens.Authenticate(context.Background(), raw)
+		assert.ErrorIs(t, err, repository.ErrInvalidAccessToken)
+
+		list, err := tokens.GetByUser(context.Background(), token.UserID)
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+	})
+}
+
+func createAccessToken(
+	t *testing.T,
+	tokens *repository.AccessTokenRepository,
+	users *repository.UserRepository,
+	expiresIn int,
+) (dto.AccessToken, string) {
+	user, err := dto.NewUser(userData(), dto.PermisisonPublisher, 4)
+	assert.NoError(t, err)
+
+	assert.True(t, t.Run("CreateUser", func(t *testing.T) {
+		err := users.Create(context.Background(), user)
+		assert.NoError(t, err)
+	}))
+
+	token, raw := dto.NewAccessToken(user.ID, accessTokenData(expiresIn))
+	assert.True(t, dto.IsAccessToken(raw))
+
+	assert.True(t, t.Run("CreateAccessToken", func(t *testing.T) {
+		err := tokens.Create(context.Background(), token)
+		assert.NoError(t, err)
+	}))
+
+	return token, raw
+}
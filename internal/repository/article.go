@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -23,6 +24,12 @@ const (
 	CodeArticleAlreadyExists
 )
 
+const (
+	_ = 11000 + iota
+
+	CodeArticleRevisionNotFound
+)
+
 var (
 	ErrArticleNotFound = errutils.NewHttpS(
 		"Article not found",
@@ -36,30 +43,55 @@ var (
 		CodeArticleAlreadyExists,
 		true,
 	)
+	ErrArticleRevisionNotFound = errutils.NewHttpS(
+		"Article revision not found",
+		http.StatusNotFound,
+		CodeArticleRevisionNotFound,
+		true,
+	)
 )
 
+// Max numeric suffix appended to a slug to make it unique, before
+// falling back to the article id.
+const maxSlugSuffix = 64
+
 type ArticleRepository struct {
 	q articleQueries
+	// The search query syntax depends on the database
+	sqlite bool
 }
 
 func NewArticleRepository(db *sqlx.DB) *ArticleRepository {
 	return &ArticleRepository{
-		q: newArticleQueries(db),
+		q:      newArticleQueries(db),
+		sqlite: strings.Contains(db.DriverName(), "sqlite"),
 	}
 }
 
+// Creates the article together with its first revision.
 func (r *ArticleRepository) Create(ctx context.Context, article dto.Article) error {
 	sttm, err := r.q.Create()
 	if err != nil {
 		return err
 	}
+	revisionSttm, err := r.q.CreateRevision()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.q.Begin(ctx)
+	if err != nil {
+		slog.Error("ArticleRepository: Create: sql error", "error", err)
+		return err
+	}
+	defer tx.Rollback()
 
 	description2 := sql.NullString{String: article.Description}
 	if article.Description != "" {
 		description2.Valid = true
 	}
 
-	_, err = sttm.ExecContext(ctx,
+	_, err = tx.StmtxContext(ctx, sttm).ExecContext(ctx,
 		article.ID,
 		article.CreatedAt,
 		article.UpdatedAt,
@@ -69,6 +101,9 @@ func (r *ArticleRepository) Create(ctx context.Context, article dto.Article) err
 		article.Indexing,
 		article.Content,
 		utils.UnsafeString(article.RawContent),
+		article.Status,
+		article.PublishAt,
+		article.Slug,
 	)
 	if err != nil {
 		if isUniqueConstraintViolation(err) {
@@ -76,6 +111,24 @@ func (r *ArticleRepository) Create(ctx context.Context, article dto.Article) err
 		} else {
 			slog.Error("ArticleRepository: Create: sql error", "error", err)
 		}
+		return err
+	}
+
+	// The first revision shares the id of the article
+	revision := dto.ArticleRevision{
+		ID:         article.ID,
+		CreatedAt:  article.CreatedAt,
+		ArticleID:  article.ID,
+		UserID:     &article.UserID,
+		RawContent: article.RawContent,
+	}
+	err = createRevision(ctx, tx.StmtxContext(ctx, revisionSttm), revision)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("ArticleRepository: Create: sql error", "error", err)
 	}
 	return err
 }
@@ -109,48 +162,99 @@ func (r *ArticleRepository) GetFull(ctx context.Context, id dto.Snowflake) (dto.
 	return r.getAnyWithUser(ctx, id, "GetFull")
 }
 
-func (r *ArticleRepository) GetMany(
+// Fetches the article with the given current slug, with its user
+// and content.
+func (r *ArticleRepository) GetBySlug(ctx context.Context, slug string) (dto.Article, error) {
+	return r.getAnyWithUser(ctx, slug, "GetBySlug")
+}
+
+// Fetches the article that was previously addressed by the given slug.
+func (r *ArticleRepository) GetByOldSlug(
 	ctx context.Context,
-	pag dto.Pagination,
-) ([]dto.Article, error) {
-	if pag.LastSeen == 0 {
-		// math.MaxUint64 results int integer overflow
-		pag.LastSeen = math.MaxInt64
-	}
+	slug string,
+) (dto.Article, error) {
+	var article dto.Article
 
-	sttm, err := r.q.GetMany()
+	sttm, err := r.q.GetByOldSlug()
 	if err != nil {
-		return nil, err
+		return article, err
 	}
 
-	rows, err := sttm.QueryxContext(ctx, pag.LastSeen, pag.Limit)
-	if err != nil {
-		slog.Error("ArticleRepository: GetMany: sql error", "error", err)
+	if err = sttm.GetContext(ctx, &article, slug); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrArticleNotFound
+		} else {
+			slog.Error("ArticleRepository: GetByOldSlug: sql error", "error", err)
+		}
 	}
-	defer rows.Close()
+	return article, err
+}
 
-	articles := []dto.Article{}
+// Returns base if no other article is or was addressed by it, appending
+// the first free numeric suffix to it otherwise.
+func (r *ArticleRepository) UniqueSlug(
+	ctx context.Context,
+	base string,
+	id dto.Snowflake,
+) (string, error) {
+	sttm, err := r.q.SlugTaken()
+	if err != nil {
+		return "", err
+	}
 
-	for rows.Next() {
-		var res struct {
-			Article dto.Article `db:"articles"`
-			User    dto.User    `db:"users"`
+	slug := base
+	for i := 2; i <= maxSlugSuffix; i++ {
+		var taken bool
+		if err = sttm.GetContext(ctx, &taken, slug, id); err != nil {
+			slog.Error("ArticleRepository: SlugTaken: sql error", "error", err)
+			return "", err
 		}
-
-		if err = rows.StructScan(&res); err != nil {
-			return nil, err
+		if !taken {
+			return slug, nil
 		}
 
-		res.Article.User = &res.User
-		articles = append(articles, res.Article)
+		slug = fmt.Sprintf("%s-%d", base, i)
 	}
 
-	return articles, err
+	// The id is unique by itself
+	return fmt.Sprintf("%s-%s", base, id), nil
 }
 
+// Fetches the published articles whose publish time has passed, the most
+// recently published first.
+func (r *ArticleRepository) GetMany(
+	ctx context.Context,
+	pag dto.Pagination,
+) ([]dto.Article, error) {
+	now := time.Now().UnixMilli()
+
+	return r.getManyWithUser(ctx, "GetMany", now, pag.LastSeen, pag.Limit)
+}
+
+// Like GetMany, but only fetches the articles with the tag.
+func (r *ArticleRepository) GetManyByTag(
+	ctx context.Context,
+	tagId dto.Snowflake,
+	pag dto.Pagination,
+) ([]dto.Article, error) {
+	now := time.Now().UnixMilli()
+
+	return r.getManyWithUser(
+		ctx,
+		"GetManyByTag",
+		tagId,
+		now,
+		pag.LastSeen,
+		pag.Limit,
+	)
+}
+
+// Fetches the articles of a user with the given status, as reported by
+// dto.Article.EffectiveStatus.
 func (r *ArticleRepository) GetManyByUser(
 	ctx context.Context,
 	userId dto.Snowflake,
+	status dto.ArticleStatus,
 	pag dto.Pagination,
 ) ([]dto.Article, error) {
 	if pag.LastSeen == 0 {
@@ -158,54 +262,183 @@ func (r *ArticleRepository) GetManyByUser(
 		pag.LastSeen = math.MaxInt64
 	}
 
-	sttm, err := r.q.GetManyByUser()
+	var (
+		name  string
+		param any
+	)
+	switch status {
+	case dto.ArticleStatusPublished:
+		name, param = "GetManyByUser", time.Now().UnixMilli()
+	case dto.ArticleStatusScheduled:
+		name, param = "GetManyByUserScheduled", time.Now().UnixMilli()
+	default:
+		name, param = "GetManyByUserStatus", status
+	}
+
+	sttm, err := r.q.Get(name)
 	if err != nil {
 		return nil, err
 	}
 
-	var articles []dto.Article
+	articles := []dto.Article{}
 
-	err = sttm.SelectContext(ctx, &articles, userId, pag.LastSeen, pag.Limit)
+	err = sttm.SelectContext(
+		ctx,
+		&articles,
+		userId,
+		param,
+		pag.LastSeen,
+		pag.Limit,
+	)
 	if err != nil {
-		slog.Error("ArticleRepository: GetManyByUser: sql error", "error", err)
+		slog.Error(
+			fmt.Sprintf("ArticleRepository: %s: sql error", name),
+			"error", err,
+		)
 	}
 
 	return articles, err
 }
 
+// Searches the published articles by title, description and content,
+// the most relevant first. The last seen id of the pagination must be
+// the one of a result of the same query.
+func (r *ArticleRepository) Search(
+	ctx context.Context,
+	query string,
+	pag dto.Pagination,
+) ([]dto.ArticleSearchResult, error) {
+	results := []dto.ArticleSearchResult{}
+
+	if r.sqlite {
+		query = ftsQuery(query)
+	}
+	if strings.TrimSpace(query) == "" {
+		return results, nil
+	}
+
+	sttm, err := r.q.Search()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sttm.QueryxContext(
+		ctx,
+		query,
+		time.Now().UnixMilli(),
+		pag.LastSeen,
+		pag.Limit,
+	)
+	if err != nil {
+		slog.Error("ArticleRepository: Search: sql error", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res struct {
+			Article dto.Article `db:"articles"`
+			User    dto.User    `db:"users"`
+			Rank    float64     `db:"rank"`
+			Snippet string      `db:"snippet"`
+		}
+
+		if err = rows.StructScan(&res); err != nil {
+			return nil, err
+		}
+
+		res.Article.User = &res.User
+		results = append(results, dto.ArticleSearchResult{
+			Article: res.Article,
+			Rank:    res.Rank,
+			Snippet: dto.HighlightSnippet(res.Snippet),
+		})
+	}
+
+	return results, rows.Err()
+}
+
+// Updates the data of the article, the previous slug being kept to
+// address it if it changes.
 func (r *ArticleRepository) UpdateData(
 	ctx context.Context,
 	id dto.Snowflake,
-	title, description string,
+	title, description, slug string,
 ) (dto.Article, error) {
 	now := time.Now().UnixMilli()
 
-	var article dto.Article
+	article, err := r.Get(ctx, id)
+	if err != nil {
+		return article, err
+	}
+	oldSlug := article.Slug
 
 	sttm, err := r.q.UpdateData()
 	if err != nil {
 		return article, err
 	}
+	createSttm, err := r.q.CreateOldSlug()
+	if err != nil {
+		return article, err
+	}
+	deleteSttm, err := r.q.DeleteOldSlug()
+	if err != nil {
+		return article, err
+	}
+
+	tx, err := r.q.Begin(ctx)
+	if err != nil {
+		slog.Error("ArticleRepository: UpdateData: sql error", "error", err)
+		return article, err
+	}
+	defer tx.Rollback()
 
-	err = sttm.GetContext(ctx, &article, title, description, now, id)
+	err = tx.StmtxContext(ctx, sttm).
+		GetContext(ctx, &article, title, description, slug, now, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			err = ErrArticleNotFound
+		} else if isUniqueConstraintViolation(err) {
+			err = ErrArticleAlreadyExists
 		} else {
 			slog.Error("ArticleRepository: UpdateData: sql error", "error", err)
 		}
+		return article, err
+	}
+
+	if oldSlug != slug {
+		_, err = tx.StmtxContext(ctx, createSttm).
+			ExecContext(ctx, oldSlug, now, id)
+		if err != nil {
+			slog.Error("ArticleRepository: CreateOldSlug: sql error", "error", err)
+			return article, err
+		}
+
+		// The slug may be taken back from the previous ones
+		_, err = tx.StmtxContext(ctx, deleteSttm).ExecContext(ctx, slug)
+		if err != nil {
+			slog.Error("ArticleRepository: DeleteOldSlug: sql error", "error", err)
+			return article, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("ArticleRepository: UpdateData: sql error", "error", err)
 	}
 	return article, err
 }
 
+// Updates the content of the article, recording the raw content as a
+// new revision written by the user.
 func (r *ArticleRepository) UpdateContent(
 	ctx context.Context,
 	id dto.Snowflake,
+	userId dto.Snowflake,
 	idx dto.ArticleIndexing,
 	content dto.ArticleContent,
 	rawContent dto.ArticleRawContent,
 ) (dto.Article, error) {
-	now := time.Now().UnixMilli()
+	revision := dto.NewArticleRevision(id, userId, rawContent)
 
 	var article dto.Article
 
@@ -213,14 +446,71 @@ func (r *ArticleRepository) UpdateContent(
 	if err != nil {
 		return article, err
 	}
+	revisionSttm, err := r.q.CreateRevision()
+	if err != nil {
+		return article, err
+	}
 
-	err = sttm.GetContext(ctx, &article, idx, content, rawContent, now, id)
+	tx, err := r.q.Begin(ctx)
+	if err != nil {
+		slog.Error("ArticleRepository: UpdateContent: sql error", "error", err)
+		return article, err
+	}
+	defer tx.Rollback()
+
+	err = tx.StmtxContext(ctx, sttm).GetContext(
+		ctx,
+		&article,
+		idx,
+		content,
+		rawContent,
+		revision.CreatedAt,
+		id,
+	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			err = ErrArticleNotFound
 		} else {
 			slog.Error("ArticleRepository: UpdateContent: sql error", "error", err)
 		}
+		return article, err
+	}
+
+	err = createRevision(ctx, tx.StmtxContext(ctx, revisionSttm), revision)
+	if err != nil {
+		return article, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("ArticleRepository: UpdateContent: sql error", "error", err)
+	}
+	return article, err
+}
+
+// Scheduled articles must be stored as published, with a publish
+// time in the future.
+func (r *ArticleRepository) UpdateStatus(
+	ctx context.Context,
+	id dto.Snowflake,
+	status dto.ArticleStatus,
+	publishAt *dto.Timestamp,
+) (dto.Article, error) {
+	now := time.Now().UnixMilli()
+
+	var article dto.Article
+
+	sttm, err := r.q.UpdateStatus()
+	if err != nil {
+		return article, err
+	}
+
+	err = sttm.GetContext(ctx, &article, status, publishAt, now, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrArticleNotFound
+		} else {
+			slog.Error("ArticleRepository: UpdateStatus: sql error", "error", err)
+		}
 	}
 	return article, err
 }
@@ -244,9 +534,126 @@ func (r *ArticleRepository) Delete(ctx context.Context, id dto.Snowflake) (dto.A
 	return article, err
 }
 
-func (r *ArticleRepository) getAnyWithUser(
+// Fetches a revision of the article, with its raw content.
+func (r *ArticleRepository) GetRevision(
 	ctx context.Context,
+	articleId dto.Snowflake,
 	id dto.Snowflake,
+) (dto.ArticleRevision, error) {
+	var revision dto.ArticleRevision
+
+	sttm, err := r.q.GetRevision()
+	if err != nil {
+		return revision, err
+	}
+
+	if err = sttm.GetContext(ctx, &revision, id, articleId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrArticleRevisionNotFound
+		} else {
+			slog.Error("ArticleRepository: GetRevision: sql error", "error", err)
+		}
+	}
+	return revision, err
+}
+
+// Fetches the revisions of the article, without their raw content,
+// the most recent first.
+func (r *ArticleRepository) GetRevisions(
+	ctx context.Context,
+	articleId dto.Snowflake,
+	pag dto.Pagination,
+) ([]dto.ArticleRevision, error) {
+	if pag.LastSeen == 0 {
+		// math.MaxUint64 results int integer overflow
+		pag.LastSeen = math.MaxInt64
+	}
+
+	sttm, err := r.q.GetRevisions()
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []dto.ArticleRevision{}
+
+	err = sttm.SelectContext(ctx, &revisions, articleId, pag.LastSeen, pag.Limit)
+	if err != nil {
+		slog.Error("ArticleRepository: GetRevisions: sql error", "error", err)
+	}
+
+	return revisions, err
+}
+
+func createRevision(
+	ctx context.Context,
+	sttm *sqlx.Stmt,
+	revision dto.ArticleRevision,
+) error {
+	_, err := sttm.ExecContext(ctx,
+		revision.ID,
+		revision.CreatedAt,
+		revision.ArticleID,
+		revision.UserID,
+		utils.UnsafeString(revision.RawContent),
+	)
+	if err != nil {
+		slog.Error("ArticleRepository: CreateRevision: sql error", "error", err)
+	}
+	return err
+}
+
+// Turns the user input into a fts5 query matching all of its words,
+// so that its syntax can't be abused.
+func ftsQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+func (r *ArticleRepository) getManyWithUser(
+	ctx context.Context,
+	name string,
+	args ...any,
+) ([]dto.Article, error) {
+	sttm, err := r.q.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sttm.QueryxContext(ctx, args...)
+	if err != nil {
+		slog.Error(
+			fmt.Sprintf("ArticleRepository: %s: sql error", name),
+			"error", err,
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	articles := []dto.Article{}
+
+	for rows.Next() {
+		var res struct {
+			Article dto.Article `db:"articles"`
+			User    dto.User    `db:"users"`
+		}
+
+		if err = rows.StructScan(&res); err != nil {
+			return nil, err
+		}
+
+		res.Article.User = &res.User
+		articles = append(articles, res.Article)
+	}
+
+	return articles, rows.Err()
+}
+
+func (r *ArticleRepository) getAnyWithUser(
+	ctx context.Context,
+	key any,
 	name string,
 ) (dto.Article, error) {
 	var res struct {
@@ -259,7 +666,7 @@ func (r *ArticleRepository) getAnyWithUser(
 		return dto.Article{}, err
 	}
 
-	if err = sttm.GetContext(ctx, &res, id); err != nil {
+	if err = sttm.GetContext(ctx, &res, key); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			err = ErrArticleNotFound
 		} else {
@@ -1,23 +1,25 @@
 package repository
 
 import (
+	"strings"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/zanz1n/blog/internal/utils"
 )
 
 const articleCreateQuery = `INSERT INTO articles
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 
 const articleGetQuery = `SELECT
-id, created_at, updated_at, user_id, title, description
+id, created_at, updated_at, user_id, title, description, status, publish_at, slug
 FROM articles WHERE id = $1`
 
 const articleGetWithContentQuery = `SELECT
-id, created_at, updated_at, user_id, title, description, indexing, content
+id, created_at, updated_at, user_id, title, description, status, publish_at, slug, indexing, content
 FROM articles WHERE id = $1`
 
 const articleGetWithRawContentQuery = `SELECT
-id, created_at, updated_at, user_id, title, description, raw_content
+id, created_at, updated_at, user_id, title, description, status, publish_at, slug, raw_content
 FROM articles WHERE id = $1`
 
 const articleGetWithUserQuery = `SELECT
@@ -27,6 +29,9 @@ articles.updated_at "articles.updated_at",
 articles.user_id "articles.user_id",
 articles.title "articles.title",
 articles.description "articles.description",
+articles.status "articles.status",
+articles.publish_at "articles.publish_at",
+articles.slug "articles.slug",
 users.id "users.id",
 users.created_at "users.created_at",
 users.updated_at "users.updated_at",
@@ -46,6 +51,9 @@ articles.updated_at "articles.updated_at",
 articles.user_id "articles.user_id",
 articles.title "articles.title",
 articles.description "articles.description",
+articles.status "articles.status",
+articles.publish_at "articles.publish_at",
+articles.slug "articles.slug",
 articles.indexing "articles.indexing",
 articles.content "articles.content",
 users.id "users.id",
@@ -59,6 +67,42 @@ FROM articles
 INNER JOIN users ON articles.user_id = users.id
 WHERE articles.id = $1`
 
+const articleGetBySlugQuery = `
+SELECT
+articles.id "articles.id",
+articles.created_at "articles.created_at",
+articles.updated_at "articles.updated_at",
+articles.user_id "articles.user_id",
+articles.title "articles.title",
+articles.description "articles.description",
+articles.status "articles.status",
+articles.publish_at "articles.publish_at",
+articles.slug "articles.slug",
+articles.indexing "articles.indexing",
+articles.content "articles.content",
+users.id "users.id",
+users.created_at "users.created_at",
+users.updated_at "users.updated_at",
+users.permission "users.permission",
+users.email "users.email",
+users.nickname "users.nickname",
+users.name "users.name"
+FROM articles
+INNER JOIN users ON articles.user_id = users.id
+WHERE articles.slug = $1`
+
+const articleGetByOldSlugQuery = `SELECT
+articles.id, articles.created_at, articles.updated_at, articles.user_id,
+articles.title, articles.description, articles.status, articles.publish_at,
+articles.slug
+FROM article_slugs
+INNER JOIN articles ON article_slugs.article_id = articles.id
+WHERE article_slugs.slug = $1`
+
+const articleSlugTakenQuery = `SELECT
+EXISTS (SELECT 1 FROM articles WHERE slug = $1 AND id <> $2) OR
+EXISTS (SELECT 1 FROM article_slugs WHERE slug = $1 AND article_id <> $2)`
+
 const articleGetMany = `SELECT
 articles.id "articles.id",
 articles.created_at "articles.created_at",
@@ -66,6 +110,34 @@ articles.updated_at "articles.updated_at",
 articles.user_id "articles.user_id",
 articles.title "articles.title",
 articles.description "articles.description",
+articles.status "articles.status",
+articles.publish_at "articles.publish_at",
+articles.slug "articles.slug",
+users.id "users.id",
+users.created_at "users.created_at",
+users.updated_at "users.updated_at",
+users.permission "users.permission",
+users.email "users.email",
+users.nickname "users.nickname",
+users.name "users.name"
+FROM articles
+INNER JOIN users ON articles.user_id = users.id
+WHERE articles.status = 'published' AND articles.publish_at <= $1
+AND (CAST($2 AS bigint) = 0 OR (articles.publish_at, articles.id) < (
+	SELECT publish_at, id FROM articles WHERE id = $2
+))
+ORDER BY articles.publish_at DESC, articles.id DESC LIMIT $3`
+
+const articleGetManyByTag = `SELECT
+articles.id "articles.id",
+articles.created_at "articles.created_at",
+articles.updated_at "articles.updated_at",
+articles.user_id "articles.user_id",
+articles.title "articles.title",
+articles.description "articles.description",
+articles.status "articles.status",
+articles.publish_at "articles.publish_at",
+articles.slug "articles.slug",
 users.id "users.id",
 users.created_at "users.created_at",
 users.updated_at "users.updated_at",
@@ -75,28 +147,149 @@ users.nickname "users.nickname",
 users.name "users.name"
 FROM articles
 INNER JOIN users ON articles.user_id = users.id
-WHERE articles.id < $1
-ORDER BY articles.id DESC LIMIT $2`
+INNER JOIN article_tags ON article_tags.article_id = articles.id
+WHERE article_tags.tag_id = $1
+AND articles.status = 'published' AND articles.publish_at <= $2
+AND (CAST($3 AS bigint) = 0 OR (articles.publish_at, articles.id) < (
+	SELECT publish_at, id FROM articles WHERE id = $3
+))
+ORDER BY articles.publish_at DESC, articles.id DESC LIMIT $4`
+
+// Ordered by relevance, the rank of the last seen article being
+// recomputed to resume from it.
+const articleSearchQueryPG = `WITH matches AS (
+	SELECT articles.id, ts_rank(articles.search, query) "rank"
+	FROM articles, websearch_to_tsquery('english', $1) query
+	WHERE articles.search @@ query
+	AND articles.status = 'published' AND articles.publish_at <= $2
+)
+SELECT
+articles.id "articles.id",
+articles.created_at "articles.created_at",
+articles.updated_at "articles.updated_at",
+articles.user_id "articles.user_id",
+articles.title "articles.title",
+articles.description "articles.description",
+articles.status "articles.status",
+articles.publish_at "articles.publish_at",
+articles.slug "articles.slug",
+users.id "users.id",
+users.created_at "users.created_at",
+users.updated_at "users.updated_at",
+users.permission "users.permission",
+users.email "users.email",
+users.nickname "users.nickname",
+users.name "users.name",
+matches.rank "rank",
+ts_headline(
+	'english',
+	articles.raw_content,
+	websearch_to_tsquery('english', $1),
+	'StartSel="' || chr(1) || '", StopSel="' || chr(2) || '", ' ||
+	'MaxWords=32, MinWords=12, MaxFragments=2, FragmentDelimiter=" … "'
+) "snippet"
+FROM matches
+INNER JOIN articles ON articles.id = matches.id
+INNER JOIN users ON articles.user_id = users.id
+WHERE CAST($3 AS bigint) = 0 OR (matches.rank, matches.id) < (
+	SELECT "rank", id FROM matches WHERE id = $3
+)
+ORDER BY matches.rank DESC, matches.id DESC LIMIT $4`
+
+// Same as articleSearchQueryPG, bm25 being negated as lower scores are
+// more relevant.
+const articleSearchQuerySQLITE = `WITH matches AS (
+	SELECT
+	rowid id,
+	-bm25(articles_fts, 10.0, 5.0, 1.0) "rank",
+	snippet(articles_fts, 2, char(1), char(2), ' … ', 24) "snippet"
+	FROM articles_fts WHERE articles_fts MATCH $1
+)
+SELECT
+articles.id "articles.id",
+articles.created_at "articles.created_at",
+articles.updated_at "articles.updated_at",
+articles.user_id "articles.user_id",
+articles.title "articles.title",
+articles.description "articles.description",
+articles.status "articles.status",
+articles.publish_at "articles.publish_at",
+articles.slug "articles.slug",
+users.id "users.id",
+users.created_at "users.created_at",
+users.updated_at "users.updated_at",
+users.permission "users.permission",
+users.email "users.email",
+users.nickname "users.nickname",
+users.name "users.name",
+matches.rank "rank",
+matches.snippet "snippet"
+FROM matches
+INNER JOIN articles ON articles.id = matches.id
+INNER JOIN users ON articles.user_id = users.id
+WHERE articles.status = 'published' AND articles.publish_at <= $2
+AND (CAST($3 AS bigint) = 0 OR (matches.rank, matches.id) < (
+	SELECT "rank", id FROM matches WHERE id = $3
+))
+ORDER BY matches.rank DESC, matches.id DESC LIMIT $4`
 
 const articleGetManyByUser = `SELECT
-id, created_at, updated_at, user_id, title, description
+id, created_at, updated_at, user_id, title, description, status, publish_at, slug
 FROM articles
-WHERE user_id = $1 AND id < $2
-ORDER BY id DESC LIMIT $3`
+WHERE user_id = $1 AND status = 'published' AND publish_at <= $2 AND id < $3
+ORDER BY id DESC LIMIT $4`
+
+const articleGetManyByUserScheduled = `SELECT
+id, created_at, updated_at, user_id, title, description, status, publish_at, slug
+FROM articles
+WHERE user_id = $1 AND status = 'published' AND publish_at > $2 AND id < $3
+ORDER BY id DESC LIMIT $4`
+
+const articleGetManyByUserStatus = `SELECT
+id, created_at, updated_at, user_id, title, description, status, publish_at, slug
+FROM articles
+WHERE user_id = $1 AND status = $2 AND id < $3
+ORDER BY id DESC LIMIT $4`
 
 const articleUpdateDataQuery = `UPDATE articles
-SET title = $1, description = $2, updated_at = $3
-WHERE id = $4
-RETURNING id, created_at, updated_at, user_id, title, description`
+SET title = $1, description = $2, slug = $3, updated_at = $4
+WHERE id = $5
+RETURNING id, created_at, updated_at, user_id, title, description, status, publish_at, slug`
 
 const articleUpdateContentQuery = `UPDATE articles
 SET indexing = $1, content = $2, raw_content = $3, updated_at = $4
 WHERE id = $5
-RETURNING id, created_at, updated_at, user_id, title, description`
+RETURNING id, created_at, updated_at, user_id, title, description, status, publish_at, slug`
+
+const articleUpdateStatusQuery = `UPDATE articles
+SET status = $1, publish_at = $2, updated_at = $3
+WHERE id = $4
+RETURNING id, created_at, updated_at, user_id, title, description, status, publish_at, slug`
+
+const articleOldSlugCreateQuery = `INSERT INTO article_slugs
+(slug, created_at, article_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (slug) DO NOTHING`
+
+const articleOldSlugDeleteQuery = `DELETE FROM article_slugs WHERE slug = $1`
 
 const articleDeleteQuery = `DELETE FROM articles
 WHERE id = $1
-RETURNING id, created_at, updated_at, user_id, title, description`
+RETURNING id, created_at, updated_at, user_id, title, description, status, publish_at, slug`
+
+const articleRevisionCreateQuery = `INSERT INTO article_revisions
+(id, created_at, article_id, user_id, raw_content)
+VALUES ($1, $2, $3, $4, $5)`
+
+const articleRevisionGetQuery = `SELECT
+id, created_at, article_id, user_id, raw_content
+FROM article_revisions WHERE id = $1 AND article_id = $2`
+
+const articleRevisionGetManyQuery = `SELECT
+id, created_at, article_id, user_id
+FROM article_revisions
+WHERE article_id = $1 AND id < $2
+ORDER BY id DESC LIMIT $3`
 
 type articleQueries struct {
 	*utils.Queries
@@ -112,15 +305,35 @@ func newArticleQueries(db *sqlx.DB) articleQueries {
 	q.Add(articleGetWithContentQuery, "GetWithContent")
 	q.Add(articleGetWithRawContentQuery, "GetWithRawContent")
 	q.Add(articleGetFullQuery, "GetFull")
+	q.Add(articleGetBySlugQuery, "GetBySlug")
+	q.Add(articleGetByOldSlugQuery, "GetByOldSlug")
+	q.Add(articleSlugTakenQuery, "SlugTaken")
 
 	q.Add(articleGetMany, "GetMany")
+	q.Add(articleGetManyByTag, "GetManyByTag")
 	q.Add(articleGetManyByUser, "GetManyByUser")
+	q.Add(articleGetManyByUserScheduled, "GetManyByUserScheduled")
+	q.Add(articleGetManyByUserStatus, "GetManyByUserStatus")
+
+	if strings.Contains(db.DriverName(), "sqlite") {
+		q.Add(articleSearchQuerySQLITE, "Search")
+	} else {
+		q.Add(articleSearchQueryPG, "Search")
+	}
 
 	q.Add(articleUpdateDataQuery, "UpdateData")
 	q.Add(articleUpdateContentQuery, "UpdateContent")
+	q.Add(articleUpdateStatusQuery, "UpdateStatus")
 
 	q.Add(articleDeleteQuery, "Delete")
 
+	q.Add(articleOldSlugCreateQuery, "CreateOldSlug")
+	q.Add(articleOldSlugDeleteQuery, "DeleteOldSlug")
+
+	q.Add(articleRevisionCreateQuery, "CreateRevision")
+	q.Add(articleRevisionGetQuery, "GetRevision")
+	q.Add(articleRevisionGetManyQuery, "GetRevisions")
+
 	return articleQueries{q}
 }
 
@@ -128,6 +341,14 @@ func (q *articleQueries) Create() (*sqlx.Stmt, error) {
 	return q.Get("Create")
 }
 
+func (q *articleQueries) GetByOldSlug() (*sqlx.Stmt, error) {
+	return q.Get("GetByOldSlug")
+}
+
+func (q *articleQueries) SlugTaken() (*sqlx.Stmt, error) {
+	return q.Get("SlugTaken")
+}
+
 func (q *articleQueries) GetMany() (*sqlx.Stmt, error) {
 	return q.Get("GetMany")
 }
@@ -136,6 +357,10 @@ func (q *articleQueries) GetManyByUser() (*sqlx.Stmt, error) {
 	return q.Get("GetManyByUser")
 }
 
+func (q *articleQueries) Search() (*sqlx.Stmt, error) {
+	return q.Get("Search")
+}
+
 func (q *articleQueries) UpdateData() (*sqlx.Stmt, error) {
 	return q.Get("UpdateData")
 }
@@ -144,6 +369,30 @@ func (q *articleQueries) UpdateContent() (*sqlx.Stmt, error) {
 	return q.Get("UpdateContent")
 }
 
+func (q *articleQueries) UpdateStatus() (*sqlx.Stmt, error) {
+	return q.Get("UpdateStatus")
+}
+
 func (q *articleQueries) Delete() (*sqlx.Stmt, error) {
 	return q.Get("Delete")
 }
+
+func (q *articleQueries) CreateOldSlug() (*sqlx.Stmt, error) {
+	return q.Get("CreateOldSlug")
+}
+
+func (q *articleQueries) DeleteOldSlug() (*sqlx.Stmt, error) {
+	return q.Get("DeleteOldSlug")
+}
+
+func (q *articleQueries) CreateRevision() (*sqlx.Stmt, error) {
+	return q.Get("CreateRevision")
+}
+
+func (q *articleQueries) GetRevision() (*sqlx.Stmt, error) {
+	return q.Get("GetRevision")
+}
+
+func (q *articleQueries) GetRevisions() (*sqlx.Stmt, error) {
+	return q.Get("GetRevisions")
+}
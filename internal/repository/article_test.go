@@ -4,12 +4,14 @@ import (
 	"context"
 	"math/rand/v2"
 	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	assert "github.com/stretchr/testify/require"
 	"github.com/zanz1n/blog/internal/dto"
 	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/slug"
 )
 
 func articleRepo(t *testing.T) (*repository.ArticleRepository, *repository.UserRepository) {
@@ -157,6 +159,7 @@ func TestArticleUpdate(t *testing.T) {
 			dto.NewSnowflake(),
 			data.Title,
 			data.Description,
+			slug.Make(data.Title),
 		)
 
 		assert.Error(t, err)
@@ -177,11 +180,13 @@ func TestArticleUpdate(t *testing.T) {
 			article.ID,
 			newData.Title,
 			newData.Description,
+			slug.Make(newData.Title),
 		)
 		assert.NoError(t, err)
 
 		assert.Equal(t, newData.Title, article2.Title)
 		assert.Equal(t, newData.Description, article2.Description)
+		assert.Equal(t, slug.Make(newData.Title), article2.Slug)
 
 		assert.Greater(t,
 			article2.UpdatedAt.UnixMilli(),
@@ -191,6 +196,7 @@ func TestArticleUpdate(t *testing.T) {
 		article.UpdatedAt = article2.UpdatedAt
 		article.Title = newData.Title
 		article.Description = newData.Description
+		article.Slug = article2.Slug
 
 		assert.Nil(t, article2.Indexing)
 		article2.Indexing = article.Indexing
@@ -222,6 +228,7 @@ func TestArticleUpdate(t *testing.T) {
 		article2, err := articles.UpdateContent(
 			context.Background(),
 			article.ID,
+			article.UserID,
 			articleIdx,
 			articleContent,
 			rawContent,
@@ -299,6 +306,63 @@ func TestArticleDelete(t *testing.T) {
 	})
 }
 
+func TestArticleDeleteContent(t *testing.T) {
+	t.Parallel()
+	db := GetDb(t)
+	articles := repository.NewArticleRepository(db)
+	users := repository.NewUserRepository(db)
+	comments := repository.NewCommentRepository(db)
+	tags := repository.NewTagRepository(db)
+
+	article, user := createArticle(t, articles, users)
+
+	comment := dto.NewComment(article.ID, user.ID, commentData())
+	assert.NoError(t, comments.Create(context.Background(), comment))
+	reply := dto.NewReply(comment, user.ID, commentData())
+	assert.NoError(t, comments.Create(context.Background(), reply))
+
+	_, err := tags.SetArticleTags(
+		context.Background(),
+		article.ID,
+		[]string{randString(8)},
+	)
+	assert.NoError(t, err)
+
+	title := randString(32)
+	_, err = articles.UpdateData(
+		context.Background(),
+		article.ID,
+		title,
+		article.Description,
+		slug.Make(title),
+	)
+	assert.NoError(t, err)
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := articles.Delete(context.Background(), article.ID)
+		assert.NoError(t, err)
+	})
+
+	for _, table := range []string{
+		"comments",
+		"article_revisions",
+		"article_slugs",
+		"article_tags",
+	} {
+		t.Run("Fetch("+table+")", func(t *testing.T) {
+			var count int
+			err := db.GetContext(
+				context.Background(),
+				&count,
+				"SELECT count(*) FROM "+table+" WHERE article_id = $1",
+				article.ID,
+			)
+			assert.NoError(t, err)
+			assert.Zero(t, count)
+		})
+	}
+}
+
 func TestArticleGetMany(t *testing.T) {
 	const (
 		UserCount = 3
@@ -393,6 +457,7 @@ func TestArticleGetMany(t *testing.T) {
 				articles, err := articleRepo.GetManyByUser(
 					context.Background(),
 					users[u].ID,
+					dto.ArticleStatusPublished,
 					dto.Pagination{
 						Limit:    PageSize,
 						LastSeen: lastSeen,
@@ -412,6 +477,454 @@ func TestArticleGetMany(t *testing.T) {
 	})
 }
 
+func TestArticleStatus(t *testing.T) {
+	t.Parallel()
+
+	db, err := InitDb(t)
+	assert.NoError(t, err)
+
+	users := repository.NewUserRepository(db)
+	articles := repository.NewArticleRepository(db)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, 4)
+	assert.NoError(t, err)
+	assert.NoError(t, users.Create(context.Background(), user))
+
+	future := dto.Timestamp{Time: time.Now().Add(time.Hour).Round(time.Millisecond)}
+
+	byStatus := map[dto.ArticleStatus]dto.Article{}
+	for _, status := range []dto.ArticleStatus{
+		dto.ArticleStatusPublished,
+		dto.ArticleStatusDraft,
+		dto.ArticleStatusScheduled,
+		dto.ArticleStatusArchived,
+	} {
+		data := articleData()
+		if status == dto.ArticleStatusDraft {
+			data.Status = dto.ArticleStatusDraft
+		}
+
+		article := dto.NewArticle(user.ID, nil, nil, nil, data)
+		switch status {
+		case dto.ArticleStatusScheduled:
+			article.PublishAt = &future
+		case dto.ArticleStatusArchived:
+			article.Status = dto.ArticleStatusArchived
+		}
+
+		assert.Equal(t, status, article.EffectiveStatus())
+		assert.NoError(t, articles.Create(context.Background(), article))
+
+		byStatus[status] = article
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	pag := dto.Pagination{Limit: 10}
+
+	t.Run("GetMany", func(t *testing.T) {
+		result, err := articles.GetMany(context.Background(), pag)
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, byStatus[dto.ArticleStatusPublished].ID, result[0].ID)
+	})
+
+	t.Run("GetManyByUser", func(t *testing.T) {
+		for status, article := range byStatus {
+			result, err := articles.GetManyByUser(
+				context.Background(),
+				user.ID,
+				status,
+				pag,
+			)
+			assert.NoError(t, err)
+			assert.Equal(t, []dto.Article{article}, result)
+		}
+	})
+
+	t.Run("UpdateStatusInexistent", func(t *testing.T) {
+		_, err := articles.UpdateStatus(
+			context.Background(),
+			dto.NewSnowflake(),
+			dto.ArticleStatusDraft,
+			nil,
+		)
+		assert.ErrorIs(t, err, repository.ErrArticleNotFound)
+	})
+
+	t.Run("Publish", func(t *testing.T) {
+		draft := byStatus[dto.ArticleStatusDraft]
+		assert.Nil(t, draft.PublishAt)
+
+		now := dto.Timestamp{Time: time.Now().Truncate(time.Millisecond)}
+		article, err := articles.UpdateStatus(
+			context.Background(),
+			draft.ID,
+			dto.ArticleStatusPublished,
+			&now,
+		)
+		assert.NoError(t, err)
+		assert.True(t, article.Published())
+		assert.Equal(t, &now, article.PublishAt)
+
+		result, err := articles.GetMany(context.Background(), pag)
+		assert.NoError(t, err)
+		assert.Len(t, result, 2)
+		// Ordered by publish time rather than by creation
+		assert.Equal(t, draft.ID, result[0].ID)
+		assert.Equal(t, byStatus[dto.ArticleStatusPublished].ID, result[1].ID)
+
+		result, err = articles.GetMany(context.Background(), dto.Pagination{
+			Limit:    10,
+			LastSeen: draft.ID,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, byStatus[dto.ArticleStatusPublished].ID, result[0].ID)
+	})
+
+	t.Run("Unpublish", func(t *testing.T) {
+		published := byStatus[dto.ArticleStatusPublished]
+
+		article, err := articles.UpdateStatus(
+			context.Background(),
+			published.ID,
+			dto.ArticleStatusDraft,
+			nil,
+		)
+		assert.NoError(t, err)
+		assert.False(t, article.Published())
+		assert.Nil(t, article.PublishAt)
+
+		result, err := articles.GetManyByUser(
+			context.Background(),
+			user.ID,
+			dto.ArticleStatusPublished,
+			pag,
+		)
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.NotEqual(t, published.ID, result[0].ID)
+	})
+}
+
+func TestArticleRevisions(t *testing.T) {
+	t.Parallel()
+	articles, users := articleRepo(t)
+
+	article, user := createArticle(t, articles, users)
+
+	contents := []dto.ArticleRawContent{article.RawContent}
+	for range 2 {
+		time.Sleep(2 * time.Millisecond)
+
+		idx, content, rawContent, _ := articleData2()
+		_, err := articles.UpdateContent(
+			context.Background(),
+			article.ID,
+			user.ID,
+			idx,
+			content,
+			rawContent,
+		)
+		assert.NoError(t, err)
+
+		contents = append(contents, rawContent)
+	}
+
+	t.Run("UpdateInexistent", func(t *testing.T) {
+		id := dto.NewSnowflake()
+
+		idx, content, rawContent, _ := articleData2()
+		_, err := articles.UpdateContent(
+			context.Background(),
+			id,
+			user.ID,
+			idx,
+			content,
+			rawContent,
+		)
+		assert.ErrorIs(t, err, repository.ErrArticleNotFound)
+
+		revisions, err := articles.GetRevisions(
+			context.Background(),
+			id,
+			dto.Pagination{Limit: 10},
+		)
+		assert.NoError(t, err)
+		assert.Empty(t, revisions)
+	})
+
+	var revisions []dto.ArticleRevision
+
+	t.Run("GetRevisions", func(t *testing.T) {
+		for {
+			lastSeen := dto.Snowflake(0)
+			if len(revisions) != 0 {
+				lastSeen = revisions[len(revisions)-1].ID
+			}
+
+			page, err := articles.GetRevisions(
+				context.Background(),
+				article.ID,
+				dto.Pagination{Limit: 2, LastSeen: lastSeen},
+			)
+			assert.NoError(t, err)
+
+			revisions = append(revisions, page...)
+			if len(page) < 2 {
+				break
+			}
+		}
+
+		assert.Len(t, revisions, len(contents))
+		// The first revision is recorded on creation
+		assert.Equal(t, article.ID, revisions[len(revisions)-1].ID)
+
+		for _, revision := range revisions {
+			assert.Equal(t, article.ID, revision.ArticleID)
+			assert.Equal(t, &user.ID, revision.UserID)
+			assert.Nil(t, revision.RawContent)
+		}
+	})
+
+	t.Run("GetRevision", func(t *testing.T) {
+		for i, revision := range revisions {
+			revision2, err := articles.GetRevision(
+				context.Background(),
+				article.ID,
+				revision.ID,
+			)
+			assert.NoError(t, err)
+
+			revision.RawContent = contents[len(contents)-1-i]
+			assert.Equal(t, revision, revision2)
+		}
+	})
+
+	t.Run("GetRevisionOtherArticle", func(t *testing.T) {
+		_, err := articles.GetRevision(
+			context.Background(),
+			dto.NewSnowflake(),
+			revisions[0].ID,
+		)
+		assert.ErrorIs(t, err, repository.ErrArticleRevisionNotFound)
+	})
+}
+
+func TestArticleSlug(t *testing.T) {
+	t.Parallel()
+	articles, users := articleRepo(t)
+
+	article, user := createArticle(t, articles, users)
+
+	t.Run("GetBySlug", func(t *testing.T) {
+		article2, err := articles.GetBySlug(context.Background(), article.Slug)
+		assert.NoError(t, err)
+		assert.Equal(t, article.ID, article2.ID)
+		assert.Equal(t, user.ID, article2.User.ID)
+		assert.Equal(t, article.Content, article2.Content)
+	})
+
+	t.Run("GetBySlugInexistent", func(t *testing.T) {
+		_, err := articles.GetBySlug(context.Background(), randString(16))
+		assert.ErrorIs(t, err, repository.ErrArticleNotFound)
+	})
+
+	t.Run("UniqueSlug", func(t *testing.T) {
+		s, err := articles.UniqueSlug(context.Background(), article.Slug, article.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, article.Slug, s)
+
+		id := dto.NewSnowflake()
+		s, err = articles.UniqueSlug(context.Background(), article.Slug, id)
+		assert.NoError(t, err)
+		assert.Equal(t, article.Slug+"-2", s)
+
+		data := articleData()
+		data.Title = article.Title
+		article2 := dto.NewArticle(user.ID, nil, nil, nil, data)
+		article2.Slug = s
+		assert.NoError(t, articles.Create(context.Background(), article2))
+
+		s, err = articles.UniqueSlug(context.Background(), article.Slug, id)
+		assert.NoError(t, err)
+		assert.Equal(t, article.Slug+"-3", s)
+	})
+
+	oldSlug := article.Slug
+
+	t.Run("Rename", func(t *testing.T) {
+		title := randString(32)
+		article2, err := articles.UpdateData(
+			context.Background(),
+			article.ID,
+			title,
+			article.Description,
+			slug.Make(title),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, slug.Make(title), article2.Slug)
+
+		old, err := articles.GetByOldSlug(context.Background(), oldSlug)
+		assert.NoError(t, err)
+		assert.Equal(t, article2, old)
+
+		_, err = articles.GetBySlug(context.Background(), oldSlug)
+		assert.ErrorIs(t, err, repository.ErrArticleNotFound)
+
+		// Old slugs stay reserved to the article
+		s, err := articles.UniqueSlug(
+			context.Background(),
+			oldSlug,
+			dto.NewSnowflake(),
+		)
+		assert.NoError(t, err)
+		assert.NotEqual(t, oldSlug, s)
+
+		article = article2
+	})
+
+	t.Run("RenameBack", func(t *testing.T) {
+		s, err := articles.UniqueSlug(context.Background(), oldSlug, article.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, oldSlug, s)
+
+		article2, err := articles.UpdateData(
+			context.Background(),
+			article.ID,
+			article.Title,
+			article.Description,
+			s,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, oldSlug, article2.Slug)
+
+		_, err = articles.GetByOldSlug(context.Background(), oldSlug)
+		assert.ErrorIs(t, err, repository.ErrArticleNotFound)
+
+		old, err := articles.GetByOldSlug(context.Background(), article.Slug)
+		assert.NoError(t, err)
+		assert.Equal(t, article.ID, old.ID)
+	})
+}
+
+func TestArticleSearch(t *testing.T) {
+	t.Parallel()
+	articles, users := articleRepo(t)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, 4)
+	assert.NoError(t, err)
+	assert.NoError(t, users.Create(context.Background(), user))
+
+	keyword := strings.ToLower(randString(16))
+
+	create := func(title, description, content string, status dto.ArticleStatus) dto.Article {
+		article := dto.NewArticle(
+			user.ID,
+			articleIndexing(1),
+			dto.ArticleContent(content),
+			dto.ArticleRawContent(content),
+			dto.ArticleCreateData{
+				Title:       title,
+				Description: description,
+				Status:      status,
+			},
+		)
+		assert.NoError(t, articles.Create(context.Background(), article))
+		time.Sleep(2 * time.Millisecond)
+		return article
+	}
+
+	inContent := create(
+		"Some title",
+		"Some description",
+		"An article about <b>"+keyword+"</b> and other things",
+		dto.ArticleStatusPublished,
+	)
+	inTitle := create(
+		"About "+keyword,
+		"Some description",
+		"Nothing interesting here",
+		dto.ArticleStatusPublished,
+	)
+	create(
+		"Draft about "+keyword,
+		"",
+		keyword,
+		dto.ArticleStatusDraft,
+	)
+
+	var results []dto.ArticleSearchResult
+
+	t.Run("Search", func(t *testing.T) {
+		results, err = articles.Search(context.Background(), keyword, dto.Pagination{
+			Limit: 10,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+
+		assert.Equal(t, inTitle.ID, results[0].ID)
+		assert.Equal(t, inContent.ID, results[1].ID)
+		assert.Greater(t, results[0].Rank, results[1].Rank)
+		assert.Equal(t, user.ID, results[0].User.ID)
+
+		assert.Contains(t, results[1].Snippet, "<mark>"+keyword+"</mark>")
+		assert.Contains(t, results[1].Snippet, "&lt;b&gt;")
+		assert.NotContains(t, results[1].Snippet, "<b>")
+	})
+
+	t.Run("Paginate", func(t *testing.T) {
+		page, err := articles.Search(context.Background(), keyword, dto.Pagination{
+			Limit: 1,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.Equal(t, results[0].ID, page[0].ID)
+
+		page, err = articles.Search(context.Background(), keyword, dto.Pagination{
+			Limit:    1,
+			LastSeen: page[0].ID,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.Equal(t, results[1].ID, page[0].ID)
+
+		page, err = articles.Search(context.Background(), keyword, dto.Pagination{
+			Limit:    1,
+			LastSeen: page[0].ID,
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, page)
+	})
+
+	t.Run("NoMatches", func(t *testing.T) {
+		res, err := articles.Search(context.Background(), randString(16), dto.Pagination{
+			Limit: 10,
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, res)
+	})
+
+	t.Run("Syntax", func(t *testing.T) {
+		// Operators of the query syntaxes are matched as plain text
+		res, err := articles.Search(
+			context.Background(),
+			`"(`+keyword+`*)"`,
+			dto.Pagination{Limit: 10},
+		)
+		assert.NoError(t, err)
+		assert.Len(t, res, 2)
+		assert.Equal(t, results[0].ID, res[0].ID)
+		assert.Equal(t, results[1].ID, res[1].ID)
+
+		res, err = articles.Search(context.Background(), "  ", dto.Pagination{
+			Limit: 10,
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, res)
+	})
+}
+
 func sortByIdReverse(s []dto.Article) {
 	slices.SortFunc(s, func(a, b dto.Article) int {
 		if b.ID > a.ID {
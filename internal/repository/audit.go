@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"math"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/dto"
+)
+
+// Stores the log of the changes made by administrators.
+type AuditRepository struct {
+	q auditQueries
+}
+
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{q: newAuditQueries(db)}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, entry dto.AuditEntry) error {
+	sttm, err := r.q.Create()
+	if err != nil {
+		return err
+	}
+
+	_, err = sttm.ExecContext(ctx,
+		entry.ID,
+		entry.CreatedAt,
+		entry.ActorID,
+		entry.Action,
+		entry.TargetID,
+		entry.Data,
+	)
+	if err != nil {
+		slog.Error("AuditRepository: Create: sql error", "error", err)
+	}
+	return err
+}
+
+// Fetches a page of the log, newest first.
+func (r *AuditRepository) GetMany(
+	ctx context.Context,
+	pag dto.Pagination,
+) ([]dto.AuditEntry, error) {
+	if pag.LastSeen == 0 {
+		// math.MaxUint64 results int integer overflow
+		pag.LastSeen = math.MaxInt64
+	}
+
+	sttm, err := r.q.GetMany()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []dto.AuditEntry{}
+	if err = sttm.SelectContext(ctx, &entries, pag.LastSeen, pag.Limit); err != nil {
+		slog.Error("AuditRepository: GetMany: sql error", "error", err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Fetches a page of the changes made to the target, newest first.
+func (r *AuditRepository) GetManyByTarget(
+	ctx context.Context,
+	targetId dto.Snowflake,
+	pag dto.Pagination,
+) ([]dto.AuditEntry, error) {
+	if pag.LastSeen == 0 {
+		// math.MaxUint64 results int integer overflow
+		pag.LastSeen = math.MaxInt64
+	}
+
+	sttm, err := r.q.GetManyByTarget()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []dto.AuditEntry{}
+	err = sttm.SelectContext(ctx, &entries, targetId, pag.LastSeen, pag.Limit)
+	if err != nil {
+		slog.Error("AuditRepository: GetManyByTarget: sql error", "error", err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *AuditRepository) Close() error {
+	return r.q.Close()
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/utils"
+)
+
+const auditCreateQuery = `INSERT INTO audit_log
+VALUES ($1, $2, $3, $4, $5, $6)`
+
+const auditGetManyQuery = `SELECT * FROM audit_log
+WHERE id < $1
+ORDER BY id DESC LIMIT $2`
+
+const auditGetManyByTargetQuery = `SELECT * FROM audit_log
+WHERE target_id = $1 AND id < $2
+ORDER BY id DESC LIMIT $3`
+
+type auditQueries struct {
+	*utils.Queries
+}
+
+func newAuditQueries(db *sqlx.DB) auditQueries {
+	q := utils.NewQueries(db, "AuditQueries")
+
+	q.Add(auditCreateQuery, "Create")
+	q.Add(auditGetManyQuery, "GetMany")
+	q.Add(auditGetManyByTargetQuery, "GetManyByTarget")
+
+	return auditQueries{q}
+}
+
+func (q *auditQueries) Create() (*sqlx.Stmt, error) {
+	return q.Get("Create")
+}
+
+func (q *auditQueries) GetMany() (*sqlx.Stmt, error) {
+	return q.Get("GetMany")
+}
+
+func (q *auditQueries) GetManyByTarget() (*sqlx.Stmt, error) {
+	return q.Get("GetManyByTarget")
+}
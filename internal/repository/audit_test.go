@@ -0,0 +1,72 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func auditRepo(t *testing.T) (*repository.AuditRepository, *repository.UserRepository) {
+	db := GetDb(t)
+	return repository.NewAuditRepository(db), repository.NewUserRepository(db)
+}
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+	repo, users := auditRepo(t)
+
+	admin, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.NoError(t, users.Create(context.Background(), admin))
+
+	target := dto.NewSnowflake()
+
+	entries := make([]dto.AuditEntry, 3)
+	for i := range entries {
+		entry, err := dto.NewAuditEntry(
+			&admin.ID,
+			dto.AuditUserPermission,
+			target,
+			dto.AuditPermissionData{
+				Old: dto.PermissionDefault,
+				New: dto.PermisisonPublisher,
+			},
+		)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Create(context.Background(), entry))
+
+		entries[len(entries)-1-i] = entry
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	t.Run("GetManyByTarget", func(t *testing.T) {
+		res, err := repo.GetManyByTarget(
+			context.Background(),
+			target,
+			dto.Pagination{Limit: 100},
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, entries, res)
+		assert.JSONEq(t,
+			fmt.Sprintf(`{"old":%d,"new":%d}`, dto.PermissionDefault, dto.PermisisonPublisher),
+			string(res[0].Data),
+		)
+	})
+
+	t.Run("GetMany", func(t *testing.T) {
+		res, err := repo.GetMany(
+			context.Background(),
+			dto.Pagination{Limit: 2, LastSeen: entries[0].ID},
+		)
+		assert.NoError(t, err)
+		assert.Len(t, res, 2)
+		assert.Equal(t, entries[1:], res)
+	})
+
+}
@@ -2,17 +2,23 @@ package repository
 
 import (
 	"context"
-	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/keyring"
 	"github.com/zanz1n/blog/internal/kv"
 	"github.com/zanz1n/blog/internal/utils/errutils"
 )
@@ -23,6 +29,11 @@ const (
 	CodeAuthTokenExpired
 	CodeAuthTokenInvalid
 	CodeInvalidRefreshToken
+	CodeSessionNotFound
+	CodeAuthTokenRevoked
+	CodeActionTokenInvalid
+	CodeActionTokenExpired
+	CodeTwoFactorTokenInvalid
 )
 
 var (
@@ -46,13 +57,64 @@ var (
 		CodeInvalidRefreshToken,
 		true,
 	)
+
+	ErrRevokedAuthToken = errutils.NewHttpS(
+		"Authentication token revoked",
+		http.StatusUnauthorized,
+		CodeAuthTokenRevoked,
+		true,
+	)
+
+	ErrInvalidActionToken = errutils.NewHttpS(
+		"The link is invalid or was already used",
+		http.StatusBadRequest,
+		CodeActionTokenInvalid,
+		true,
+	)
+
+	ErrExpiredActionToken = errutils.NewHttpS(
+		"The link expired, request a new one",
+		http.StatusBadRequest,
+		CodeActionTokenExpired,
+		true,
+	)
+
+	ErrInvalidTwoFactorToken = errutils.NewHttpS(
+		"The login expired or had too many failed attempts, log in again",
+		http.StatusUnauthorized,
+		CodeTwoFactorTokenInvalid,
+		true,
+	)
+
+	ErrSessionNotFound = errutils.NewHttpS(
+		"Session not found",
+		http.StatusNotFound,
+		CodeSessionNotFound,
+		true,
+	)
 )
 
 const (
+	// The `typ` header of the jwts of each kind, so action
+	// tokens can not be used as auth tokens
+	authTokenType   = "JWT"
+	actionTokenType = "action+jwt"
+
 	refreshTokenExpiry = 7 * 24 * time.Hour
+	// Time window in which the last rotated refresh token is still
+	// accepted, so concurrent requests do not trigger reuse detection
+	refreshTokenGrace = 30 * time.Second
+	// Number of rotated refresh token hashes kept for reuse detection
+	refreshTokenHistory = 16
 	// Number of bytes.
 	// Base64 encoded size may vary
 	refreshTokenLen = 64
+
+	// Time users have to enter the second factor after the password
+	TwoFactorTokenExpiry = 5 * time.Minute
+	// Wrong codes accepted before the login must be started again
+	twoFactorTokenAttempts = 5
+	twoFactorTokenLen      = 32
 )
 
 var (
@@ -61,11 +123,21 @@ var (
 	RefreshTokenLen = base64d.EncodedLen(refreshTokenLen)
 )
 
+// Stored under `session/{userId}/{sessionId}`, the sessions of each
+// user being listed by the `session/{userId}/` prefix.
+type storedSession struct {
+	dto.Session
+	// SHA-256 hash of the refresh token
+	TokenHash []byte `json:"token_hash"`
+	// SHA-256 hashes of the rotated refresh tokens, oldest first
+	PreviousHashes [][]byte  `json:"previous_hashes,omitempty"`
+	RotatedAt      time.Time `json:"rotated_at"`
+}
+
 type AuthRepository struct {
 	parser *jwt.Parser
 
-	priv ed25519.PrivateKey
-	pub  ed25519.PublicKey
+	keys *keyring.Keyring
 
 	issuer string
 
@@ -73,87 +145,274 @@ type AuthRepository struct {
 }
 
 func NewAuthRepository(
-	priv ed25519.PrivateKey,
-	pub ed25519.PublicKey,
+	keys *keyring.Keyring,
 	issuer string,
 	kv kv.KVStorer,
 ) *AuthRepository {
 	return &AuthRepository{
-		parser: jwt.NewParser(),
-		priv:   priv,
-		pub:    pub,
+		parser: jwt.NewParser(jwt.WithValidMethods([]string{
+			jwt.SigningMethodEdDSA.Alg(),
+		})),
+		keys:   keys,
 		issuer: issuer,
 		kv:     kv,
 	}
 }
 
-func (r *AuthRepository) ValidateRefreshToken(
+func (r *AuthRepository) Issuer() string {
+	return r.issuer
+}
+
+// Returns the public keys tokens can be verified with.
+func (r *AuthRepository) JWKS() keyring.JWKSet {
+	return r.keys.JWKS()
+}
+
+// Validates the refresh token of a session and rotates it, updating the
+// session last use and metadata.
+//
+// The returned token is empty if the presented one was rotated within the
+// grace window, in which case the client should keep the newest one.
+func (r *AuthRepository) RotateRefreshToken(
 	ctx context.Context,
 	token string,
-) (dto.Snowflake, error) {
-	tokenb, err := decodeRefreshToken(token)
+	meta dto.SessionMetadata,
+) (string, dto.Session, error) {
+	for {
+		sess, raw, current, err := r.refreshTokenSession(ctx, token)
+		if err != nil {
+			return "", dto.Session{}, err
+		} else if !current {
+			return "", sess.Session, nil
+		}
+
+		now := time.Now()
+
+		tokenb := generateRefreshToken(sess.UserID, sess.ID)
+
+		sess.PreviousHashes = append(sess.PreviousHashes, sess.TokenHash)
+		if len(sess.PreviousHashes) > refreshTokenHistory {
+			sess.PreviousHashes = sess.PreviousHashes[1:]
+		}
+		sess.TokenHash = hashRefreshToken(tokenb)
+		sess.RotatedAt = now
+
+		sess.LastUsedAt = dto.Timestamp{Time: now.Round(time.Millisecond)}
+		sess.SessionMetadata = meta
+
+		value, err := json.Marshal(sess)
+		if err != nil {
+			return "", dto.Session{}, err
+		}
+
+		ok, err := r.kv.CompareAndSwap(
+			ctx,
+			sessionKey(sess.UserID, sess.ID),
+			raw,
+			string(value),
+			refreshTokenExpiry,
+		)
+		if err != nil {
+			return "", dto.Session{}, err
+		} else if ok {
+			return base64d.EncodeToString(tokenb), sess.Session, nil
+		}
+
+		// Rotated by a concurrent request, so the token is no longer the
+		// current one and is checked against the new session again
+	}
+}
+
+// Creates a new session for the user, returning its refresh token.
+func (r *AuthRepository) GenRefreshToken(
+	ctx context.Context,
+	userId dto.Snowflake,
+	meta dto.SessionMetadata,
+) (string, dto.Session, error) {
+	sess := storedSession{Session: dto.NewSession(userId, meta)}
+
+	tokenb := generateRefreshToken(userId, sess.ID)
+	sess.TokenHash = hashRefreshToken(tokenb)
+
+	err := r.kv.SetValueEx(
+		ctx,
+		sessionKey(userId, sess.ID),
+		sess,
+		refreshTokenExpiry,
+	)
 	if err != nil {
-		return 0, err
+		return "", dto.Session{}, err
 	}
 
-	userId := getRefreshTokenUser(tokenb)
-	key := fmt.Sprintf("refresh_token/%s", userId)
+	return base64d.EncodeToString(tokenb), sess.Session, nil
+}
 
-	refreshToken, err := r.kv.GetEx(ctx, key, refreshTokenExpiry)
+// Returns the active sessions of the user, most recently used first.
+func (r *AuthRepository) GetSessions(
+	ctx context.Context,
+	userId dto.Snowflake,
+) ([]dto.Session, error) {
+	keys, err := r.kv.Keys(ctx, sessionKeyPrefix(userId))
 	if err != nil {
-		if errors.Is(err, kv.ErrValueNotFound) {
-			err = ErrInvalidRefreshToken
-		}
-		return 0, err
+		return nil, err
 	}
 
-	if refreshToken != token {
-		return 0, ErrInvalidRefreshToken
+	sessions := make([]dto.Session, 0, len(keys))
+
+	for _, key := range keys {
+		var sess storedSession
+		err = r.kv.GetValue(ctx, key, &sess)
+		if err != nil {
+			// Expired in the meantime
+			if errors.Is(err, kv.ErrValueNotFound) {
+				continue
+			}
+			return nil, err
+		}
+
+		sessions = append(sessions, sess.Session)
 	}
 
-	return userId, nil
+	slices.SortFunc(sessions, func(a, b dto.Session) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt.Time)
+	})
+
+	return sessions, nil
 }
 
-func (r *AuthRepository) GenRefreshToken(
+func (r *AuthRepository) DeleteSession(
 	ctx context.Context,
 	userId dto.Snowflake,
-) (string, error) {
-	key := fmt.Sprintf("refresh_token/%s", userId)
+	sessionId dto.Snowflake,
+) error {
+	err := r.kv.Delete(ctx, sessionKey(userId, sessionId))
+	if errors.Is(err, kv.ErrValueNotFound) {
+		err = ErrSessionNotFound
+	}
+	return err
+}
 
-	refreshToken, err := r.kv.GetEx(ctx, key, refreshTokenExpiry)
-	if err == nil {
-		return refreshToken, nil
+// Deletes the session the refresh token belongs to.
+func (r *AuthRepository) DeleteRefreshToken(ctx context.Context, token string) error {
+	sess, _, _, err := r.refreshTokenSession(ctx, token)
+	if err != nil {
+		return err
 	}
 
-	if !errors.Is(err, kv.ErrValueNotFound) {
-		return "", err
+	return r.DeleteSession(ctx, sess.UserID, sess.ID)
+}
+
+// Fetches the session the refresh token belongs to, together with its
+// raw stored value, which it can be compared and swapped with.
+//
+// Current is false if the token was the last rotated one and it is still
+// within the grace window. If an older rotated token is presented, the
+// session is revoked, since either the legitimate client or an attacker
+// holds a stolen copy of it.
+func (r *AuthRepository) refreshTokenSession(
+	ctx context.Context,
+	token string,
+) (sess storedSession, raw string, current bool, err error) {
+	tokenb, err := decodeRefreshToken(token)
+	if err != nil {
+		return sess, "", false, err
 	}
 
-	refreshToken = generateRefreshToken(userId)
+	userId, sessionId := getRefreshTokenSession(tokenb)
 
-	err = r.kv.SetEx(ctx, key, refreshToken, refreshTokenExpiry)
+	// The ttl is only refreshed once the token is rotated
+	raw, err = r.kv.Get(ctx, sessionKey(userId, sessionId))
 	if err != nil {
-		return "", err
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = ErrInvalidRefreshToken
+		}
+		return sess, "", false, err
+	}
+
+	if err = json.Unmarshal([]byte(raw), &sess); err != nil {
+		return sess, "", false, err
+	}
+
+	hash := hashRefreshToken(tokenb)
+	if subtle.ConstantTimeCompare(hash, sess.TokenHash) == 1 {
+		return sess, raw, true, nil
+	}
+
+	for i, prev := range sess.PreviousHashes {
+		if subtle.ConstantTimeCompare(hash, prev) != 1 {
+			continue
+		}
+
+		last := i == len(sess.PreviousHashes)-1
+		if last && time.Since(sess.RotatedAt) < refreshTokenGrace {
+			return sess, raw, false, nil
+		}
+
+		slog.Warn(
+			"AuthRepository: Rotated refresh token reused, revoking session",
+			"user_id", userId,
+			"session_id", sessionId,
+		)
+
+		err = r.DeleteSession(ctx, userId, sessionId)
+		if err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return sess, "", false, err
+		}
+		return sess, "", false, ErrInvalidRefreshToken
 	}
 
-	return refreshToken, nil
+	return sess, "", false, ErrInvalidRefreshToken
 }
 
+// Deletes all the sessions of the user.
 func (r *AuthRepository) DeleteRefreshTokens(
 	ctx context.Context,
 	userId dto.Snowflake,
 ) error {
-	key := fmt.Sprintf("refresh_token/%s", userId)
+	return r.deleteSessions(ctx, userId, 0)
+}
 
-	err := r.kv.Delete(ctx, key)
-	if errors.Is(err, kv.ErrValueNotFound) {
-		err = nil
+// Deletes all the sessions of the user but the one to keep.
+func (r *AuthRepository) DeleteOtherSessions(
+	ctx context.Context,
+	userId dto.Snowflake,
+	keep dto.Snowflake,
+) error {
+	return r.deleteSessions(ctx, userId, keep)
+}
+
+// The sessions are listed by their key prefix, so that the ones created
+// concurrently are all found without an index to keep in sync.
+func (r *AuthRepository) deleteSessions(
+	ctx context.Context,
+	userId dto.Snowflake,
+	keep dto.Snowflake,
+) error {
+	keys, err := r.kv.Keys(ctx, sessionKeyPrefix(userId))
+	if err != nil {
+		return err
 	}
 
-	return err
+	keepKey := sessionKey(userId, keep)
+	for _, key := range keys {
+		if key == keepKey {
+			continue
+		}
+
+		err = r.kv.Delete(ctx, key)
+		if err != nil && !errors.Is(err, kv.ErrValueNotFound) {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (r *AuthRepository) DecodeToken(token string) (dto.AuthToken, error) {
+// Decodes and validates the token, failing if it was revoked.
+func (r *AuthRepository) DecodeToken(
+	ctx context.Context,
+	token string,
+) (dto.AuthToken, error) {
 	var claims dto.AuthToken
 
 	t, err := r.parser.ParseWithClaims(token, &claims, r.keyFunc)
@@ -165,20 +424,225 @@ func (r *AuthRepository) DecodeToken(token string) (dto.AuthToken, error) {
 		}
 	}
 
-	if !t.Valid {
+	if !t.Valid || t.Header["typ"] != authTokenType {
 		return claims, ErrInvalidAuthToken
 	}
 
+	revoked, err := r.isTokenRevoked(ctx, claims)
+	if err != nil {
+		return claims, err
+	}
+	if revoked {
+		return claims, ErrRevokedAuthToken
+	}
+
 	return claims, nil
 }
 
+// Revokes the token until it expires.
+func (r *AuthRepository) RevokeToken(ctx context.Context, token dto.AuthToken) error {
+	ttl := time.Until(token.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return r.kv.SetEx(ctx, revokedTokenKey(token.TokenID), "1", ttl)
+}
+
+// Revokes all the tokens of the user issued until now.
+func (r *AuthRepository) RevokeUserTokens(ctx context.Context, userId dto.Snowflake) error {
+	watermark := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	return r.kv.Set(ctx, tokenWatermarkKey(userId), watermark)
+}
+
+func (r *AuthRepository) isTokenRevoked(
+	ctx context.Context,
+	token dto.AuthToken,
+) (bool, error) {
+	revoked, err := r.kv.Exists(ctx, revokedTokenKey(token.TokenID))
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	watermark, err := r.kv.Get(ctx, tokenWatermarkKey(token.ID))
+	if err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = nil
+		}
+		return false, err
+	}
+
+	ms, err := strconv.ParseInt(watermark, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("parse token watermark: %s", err)
+	}
+
+	return token.TokenID.TimestampUnix() < ms, nil
+}
+
 func (r *AuthRepository) EncodeToken(data dto.AuthToken) (string, error) {
 	if data.Issuer == "" {
 		data.Issuer = r.issuer
 	}
 
-	t := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &data)
-	return t.SignedString(r.priv)
+	return r.sign(&data, authTokenType)
+}
+
+// Issues an action token, invalidating the ones previously issued
+// to the user for the same purpose.
+func (r *AuthRepository) GenActionToken(
+	ctx context.Context,
+	user *dto.User,
+	purpose dto.ActionPurpose,
+	exp time.Duration,
+) (string, error) {
+	data := dto.NewActionToken(user, purpose, exp)
+	data.Issuer = r.issuer
+
+	token, err := r.sign(&data, actionTokenType)
+	if err != nil {
+		return "", err
+	}
+
+	err = r.kv.SetEx(
+		ctx,
+		actionTokenKey(purpose, user.ID),
+		data.TokenID.String(),
+		exp,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Validates the action token, making sure it can not be used again.
+func (r *AuthRepository) ConsumeActionToken(
+	ctx context.Context,
+	token string,
+	purpose dto.ActionPurpose,
+) (dto.ActionToken, error) {
+	var claims dto.ActionToken
+
+	t, err := r.parser.ParseWithClaims(token, &claims, r.keyFunc)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return claims, ErrExpiredActionToken
+		}
+		return claims, ErrInvalidActionToken
+	}
+
+	if !t.Valid || t.Header["typ"] != actionTokenType || claims.Purpose != purpose {
+		return claims, ErrInvalidActionToken
+	}
+
+	key := actionTokenKey(purpose, claims.ID)
+
+	id, err := r.kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = ErrInvalidActionToken
+		}
+		return claims, err
+	}
+	if id != claims.TokenID.String() {
+		return claims, ErrInvalidActionToken
+	}
+
+	// Only one of concurrent requests succeeds deleting it
+	if err = r.kv.Delete(ctx, key); err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = ErrInvalidActionToken
+		}
+		return claims, err
+	}
+
+	return claims, nil
+}
+
+// Issues a token standing for a login whose password was checked, but
+// still needs the second factor before a session is created.
+func (r *AuthRepository) GenTwoFactorToken(
+	ctx context.Context,
+	userId dto.Snowflake,
+) (string, error) {
+	tokenb := make([]byte, twoFactorTokenLen)
+	rand.Read(tokenb)
+	token := base64.RawURLEncoding.EncodeToString(tokenb)
+
+	err := r.kv.SetEx(
+		ctx,
+		twoFactorTokenKey(token),
+		userId.String(),
+		TwoFactorTokenExpiry,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Returns the user the pending login belongs to.
+func (r *AuthRepository) GetTwoFactorToken(
+	ctx context.Context,
+	token string,
+) (dto.Snowflake, error) {
+	var userId dto.Snowflake
+
+	value, err := r.kv.Get(ctx, twoFactorTokenKey(token))
+	if err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = ErrInvalidTwoFactorToken
+		}
+		return userId, err
+	}
+
+	if err = userId.UnmarshalText([]byte(value)); err != nil {
+		return userId, ErrInvalidTwoFactorToken
+	}
+	return userId, nil
+}
+
+// Counts a wrong code entered for the pending login, deleting
+// it after too many of them.
+func (r *AuthRepository) FailTwoFactorToken(ctx context.Context, token string) error {
+	key := twoFactorTokenKey(token)
+
+	attempts, err := r.kv.Incr(ctx, key+"/attempts", TwoFactorTokenExpiry)
+	if err != nil {
+		return err
+	}
+	if attempts < twoFactorTokenAttempts {
+		return nil
+	}
+
+	err = r.kv.Delete(ctx, key)
+	if errors.Is(err, kv.ErrValueNotFound) {
+		err = nil
+	}
+	return err
+}
+
+// Deletes the pending login, so the token can not be used again.
+func (r *AuthRepository) ConsumeTwoFactorToken(ctx context.Context, token string) error {
+	// Only one of concurrent requests succeeds deleting it
+	err := r.kv.Delete(ctx, twoFactorTokenKey(token))
+	if errors.Is(err, kv.ErrValueNotFound) {
+		err = ErrInvalidTwoFactorToken
+	}
+	return err
+}
+
+func (r *AuthRepository) sign(claims jwt.Claims, typ string) (string, error) {
+	key := r.keys.Current()
+
+	t := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	t.Header["kid"] = key.ID
+	t.Header["typ"] = typ
+
+	return t.SignedString(key.Private)
 }
 
 func (r *AuthRepository) keyFunc(t *jwt.Token) (any, error) {
@@ -186,7 +650,18 @@ func (r *AuthRepository) keyFunc(t *jwt.Token) (any, error) {
 		return nil, jwt.ErrEd25519Verification
 	}
 
-	return r.pub, nil
+	kid, ok := t.Header["kid"].(string)
+	if !ok {
+		// Tokens signed before key ids were introduced
+		return r.keys.Current().Public, nil
+	}
+
+	key, ok := r.keys.Get(kid)
+	if !ok {
+		return nil, jwt.ErrTokenUnverifiable
+	}
+
+	return key.Public, nil
 }
 
 func decodeRefreshToken(ts string) ([]byte, error) {
@@ -198,15 +673,53 @@ func decodeRefreshToken(ts string) ([]byte, error) {
 	return token, err
 }
 
-func generateRefreshToken(userId dto.Snowflake) string {
+// Refresh tokens are composed of the user id, the session id and
+// random bytes, in this order.
+func generateRefreshToken(userId, sessionId dto.Snowflake) []byte {
 	token := make([]byte, refreshTokenLen)
 	rand.Read(token)
 
-	binary.LittleEndian.PutUint64(token, uint64(userId))
+	binary.LittleEndian.PutUint64(token[0:8], uint64(userId))
+	binary.LittleEndian.PutUint64(token[8:16], uint64(sessionId))
+
+	return token
+}
+
+func getRefreshTokenSession(token []byte) (userId, sessionId dto.Snowflake) {
+	userId = dto.Snowflake(binary.LittleEndian.Uint64(token[0:8]))
+	sessionId = dto.Snowflake(binary.LittleEndian.Uint64(token[8:16]))
+	return
+}
+
+func hashRefreshToken(token []byte) []byte {
+	hash := sha256.Sum256(token)
+	return hash[:]
+}
+
+func sessionKey(userId, sessionId dto.Snowflake) string {
+	return sessionKeyPrefix(userId) + sessionId.String()
+}
+
+func sessionKeyPrefix(userId dto.Snowflake) string {
+	return fmt.Sprintf("session/%s/", userId)
+}
+
+func revokedTokenKey(tokenId dto.Snowflake) string {
+	return fmt.Sprintf("revoked_token/%s", tokenId)
+}
+
+// Tokens of the user issued before the stored unix milli timestamp
+// are revoked.
+func tokenWatermarkKey(userId dto.Snowflake) string {
+	return fmt.Sprintf("token_watermark/%s", userId)
+}
 
-	return base64d.EncodeToString(token)
+func actionTokenKey(purpose dto.ActionPurpose, userId dto.Snowflake) string {
+	return fmt.Sprintf("action_token/%s/%s", purpose, userId)
 }
 
-func getRefreshTokenUser(token []byte) dto.Snowflake {
-	return dto.Snowflake(binary.LittleEndian.Uint64(token[0:8]))
+// The token is hashed, so the keys do not leak it.
+func twoFactorTokenKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("two_factor/%s", base64.RawURLEncoding.EncodeToString(hash[:]))
 }
@@ -2,15 +2,15 @@ package repository_test
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/rand"
 	"encoding/base64"
 	mrand "math/rand/v2"
+	"sync"
 	"testing"
 	"time"
 
 	assert "github.com/stretchr/testify/require"
 	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/keyring"
 	"github.com/zanz1n/blog/internal/kv"
 	"github.com/zanz1n/blog/internal/repository"
 	"github.com/zanz1n/blog/internal/utils"
@@ -24,10 +24,13 @@ func kvRepo(t *testing.T) kv.KVStorer {
 }
 
 func authRepository(t *testing.T, kv kv.KVStorer) *repository.AuthRepository {
-	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	key, err := keyring.GenerateKey()
 	assert.NoError(t, err)
 
-	return repository.NewAuthRepository(priv, pub, issuer, kv)
+	keys, err := keyring.New([]keyring.Key{key}, key.ID)
+	assert.NoError(t, err)
+
+	return repository.NewAuthRepository(keys, issuer, kv)
 }
 
 func newUser(t *testing.T) dto.User {
@@ -37,6 +40,13 @@ func newUser(t *testing.T) dto.User {
 	return user
 }
 
+func sessionMetadata() dto.SessionMetadata {
+	return dto.SessionMetadata{
+		UserAgent: randString(64),
+		IP:        "127.0.0.1",
+	}
+}
+
 func TestAuthRefreshToken(t *testing.T) {
 	t.Parallel()
 	repo := authRepository(t, kvRepo(t))
@@ -54,7 +64,11 @@ func TestAuthRefreshToken(t *testing.T) {
 				)
 			}
 
-			_, err := repo.ValidateRefreshToken(context.Background(), token)
+			_, _, err := repo.RotateRefreshToken(
+				context.Background(),
+				token,
+				sessionMetadata(),
+			)
 			assert.Error(t, err)
 			assert.ErrorIs(t, err, repository.ErrInvalidRefreshToken)
 		}
@@ -62,23 +76,295 @@ func TestAuthRefreshToken(t *testing.T) {
 
 	userId := dto.NewSnowflake()
 
-	token, err := repo.GenRefreshToken(context.Background(), userId)
+	token, session, err := repo.GenRefreshToken(
+		context.Background(),
+		userId,
+		sessionMetadata(),
+	)
 	assert.NoError(t, err)
 
-	_, err = repo.ValidateRefreshToken(context.Background(), token)
+	meta := sessionMetadata()
+	token2, session2, err := repo.RotateRefreshToken(context.Background(), token, meta)
 	assert.NoError(t, err)
+	assert.NotEqual(t, token, token2)
+
+	assert.Equal(t, session.ID, session2.ID)
+	assert.Equal(t, userId, session2.UserID)
+	assert.Equal(t, meta, session2.SessionMetadata)
 
 	err = repo.DeleteRefreshTokens(context.Background(), userId)
 	assert.NoError(t, err)
 
-	_, err = repo.ValidateRefreshToken(context.Background(), token)
+	_, _, err = repo.RotateRefreshToken(context.Background(), token2, meta)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, repository.ErrInvalidRefreshToken)
 }
 
+func TestAuthSessions(t *testing.T) {
+	const Count = 4
+
+	t.Parallel()
+	repo := authRepository(t, kvRepo(t))
+
+	userId := dto.NewSnowflake()
+
+	tokens := make([]string, Count)
+	sessions := make([]dto.Session, Count)
+	for i := range Count {
+		var err error
+		tokens[i], sessions[i], err = repo.GenRefreshToken(
+			context.Background(),
+			userId,
+			sessionMetadata(),
+		)
+		assert.NoError(t, err)
+	}
+
+	t.Run("Distinct", func(t *testing.T) {
+		for i := range Count {
+			for j := range i {
+				assert.NotEqual(t, tokens[i], tokens[j])
+				assert.NotEqual(t, sessions[i].ID, sessions[j].ID)
+			}
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		time.Sleep(5 * time.Millisecond)
+
+		_, _, err := repo.RotateRefreshToken(
+			context.Background(),
+			tokens[0],
+			sessions[0].SessionMetadata,
+		)
+		assert.NoError(t, err)
+
+		result, err := repo.GetSessions(context.Background(), userId)
+		assert.NoError(t, err)
+		assert.Len(t, result, Count)
+
+		// Most recently used first
+		assert.Equal(t, sessions[0].ID, result[0].ID)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := repo.DeleteSession(context.Background(), userId, sessions[1].ID)
+		assert.NoError(t, err)
+
+		err = repo.DeleteSession(context.Background(), userId, sessions[1].ID)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrSessionNotFound)
+
+		_, _, err = repo.RotateRefreshToken(
+			context.Background(),
+			tokens[1],
+			sessionMetadata(),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidRefreshToken)
+
+		err = repo.DeleteRefreshToken(context.Background(), tokens[2])
+		assert.NoError(t, err)
+
+		result, err := repo.GetSessions(context.Background(), userId)
+		assert.NoError(t, err)
+		assert.Len(t, result, Count-2)
+
+		_, _, err = repo.RotateRefreshToken(
+			context.Background(),
+			tokens[3],
+			sessionMetadata(),
+		)
+		assert.NoError(t, err)
+	})
+
+	t.Run("DeleteAll", func(t *testing.T) {
+		err := repo.DeleteRefreshTokens(context.Background(), userId)
+		assert.NoError(t, err)
+
+		result, err := repo.GetSessions(context.Background(), userId)
+		assert.NoError(t, err)
+		assert.Len(t, result, 0)
+
+		for _, token := range tokens {
+			_, _, err = repo.RotateRefreshToken(
+				context.Background(),
+				token,
+				sessionMetadata(),
+			)
+			assert.Error(t, err)
+			assert.ErrorIs(t, err, repository.ErrInvalidRefreshToken)
+		}
+	})
+}
+
+// Delays the writes, so that concurrent calls interleave.
+type slowKV struct {
+	kv.KVStorer
+}
+
+func (k slowKV) SetValueEx(
+	ctx context.Context,
+	key string,
+	v any,
+	ttl time.Duration,
+) error {
+	time.Sleep(5 * time.Millisecond)
+	return k.KVStorer.SetValueEx(ctx, key, v, ttl)
+}
+
+func (k slowKV) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	old string,
+	value string,
+	ttl time.Duration,
+) (bool, error) {
+	time.Sleep(5 * time.Millisecond)
+	return k.KVStorer.CompareAndSwap(ctx, key, old, value, ttl)
+}
+
+func TestAuthConcurrentSessions(t *testing.T) {
+	const Count = 8
+
+	t.Parallel()
+	repo := authRepository(t, slowKV{kvRepo(t)})
+
+	userId := dto.NewSnowflake()
+
+	tokens := make([]string, Count)
+	errs := make([]error, Count)
+
+	var wg sync.WaitGroup
+	for i := range Count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], _, errs[i] = repo.GenRefreshToken(
+				context.Background(),
+				userId,
+				sessionMetadata(),
+			)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	result, err := repo.GetSessions(context.Background(), userId)
+	assert.NoError(t, err)
+	assert.Len(t, result, Count)
+
+	err = repo.DeleteRefreshTokens(context.Background(), userId)
+	assert.NoError(t, err)
+
+	for _, token := range tokens {
+		_, _, err = repo.RotateRefreshToken(
+			context.Background(),
+			token,
+			sessionMetadata(),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidRefreshToken)
+	}
+}
+
+func TestAuthConcurrentRotation(t *testing.T) {
+	const Count = 8
+
+	t.Parallel()
+	repo := authRepository(t, slowKV{kvRepo(t)})
+
+	token, _, err := repo.GenRefreshToken(
+		context.Background(),
+		dto.NewSnowflake(),
+		sessionMetadata(),
+	)
+	assert.NoError(t, err)
+
+	tokens := make([]string, Count)
+	errs := make([]error, Count)
+
+	var wg sync.WaitGroup
+	for i := range Count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], _, errs[i] = repo.RotateRefreshToken(
+				context.Background(),
+				token,
+				sessionMetadata(),
+			)
+		}()
+	}
+	wg.Wait()
+
+	// Only one of the requests rotates it, the others are in the grace window
+	var rotated []string
+	for i := range Count {
+		assert.NoError(t, errs[i])
+		if tokens[i] != "" {
+			rotated = append(rotated, tokens[i])
+		}
+	}
+	assert.Len(t, rotated, 1)
+
+	_, _, err = repo.RotateRefreshToken(
+		context.Background(),
+		rotated[0],
+		sessionMetadata(),
+	)
+	assert.NoError(t, err)
+}
+
+func TestAuthDeleteOtherSessions(t *testing.T) {
+	const Count = 3
+
+	t.Parallel()
+	repo := authRepository(t, kvRepo(t))
+
+	userId := dto.NewSnowflake()
+
+	tokens := make([]string, Count)
+	sessions := make([]dto.Session, Count)
+	for i := range Count {
+		var err error
+		tokens[i], sessions[i], err = repo.GenRefreshToken(
+			context.Background(),
+			userId,
+			sessionMetadata(),
+		)
+		assert.NoError(t, err)
+	}
+
+	err := repo.DeleteOtherSessions(context.Background(), userId, sessions[1].ID)
+	assert.NoError(t, err)
+
+	result, err := repo.GetSessions(context.Background(), userId)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, sessions[1].ID, result[0].ID)
+
+	for i, token := range tokens {
+		_, _, err = repo.RotateRefreshToken(
+			context.Background(),
+			token,
+			sessionMetadata(),
+		)
+		if i == 1 {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+			assert.ErrorIs(t, err, repository.ErrInvalidRefreshToken)
+		}
+	}
+}
+
 func TestAuthJwt(t *testing.T) {
 	t.Parallel()
-	repo := authRepository(t, nil)
+	repo := authRepository(t, kvRepo(t))
 
 	user := newUser(t)
 	data := dto.NewAuthToken(&user, issuer, time.Second)
@@ -88,7 +374,7 @@ func TestAuthJwt(t *testing.T) {
 
 	t.Run("Decode", func(t *testing.T) {
 		// t.Parallel()
-		data2, err := repo.DecodeToken(token)
+		data2, err := repo.DecodeToken(context.Background(), token)
 		assert.NoError(t, err)
 
 		assert.Equal(t, data, data2)
@@ -98,22 +384,22 @@ func TestAuthJwt(t *testing.T) {
 		// t.Parallel()
 		time.Sleep(2 * time.Second)
 
-		_, err = repo.DecodeToken(token)
+		_, err = repo.DecodeToken(context.Background(), token)
 		assert.Error(t, err)
 		assert.ErrorIs(t, err, repository.ErrExpiredAuthToken)
 	})
 
 	t.Run("DecodeWrongKey", func(t *testing.T) {
 		// t.Parallel()
-		repo2 := authRepository(t, nil)
-		_, err = repo2.DecodeToken(token)
+		repo2 := authRepository(t, kvRepo(t))
+		_, err = repo2.DecodeToken(context.Background(), token)
 		assert.Error(t, err)
 		assert.ErrorIs(t, err, repository.ErrInvalidAuthToken)
 	})
 
 	t.Run("DecodeRandom", func(t *testing.T) {
 		// t.Parallel()
-		_, err = repo.DecodeToken("BLABLABLA")
+		_, err = repo.DecodeToken(context.Background(), "BLABLABLA")
 		assert.Error(t, err)
 		assert.ErrorIs(t, err, repository.ErrInvalidAuthToken)
 	})
@@ -121,7 +407,7 @@ func TestAuthJwt(t *testing.T) {
 
 func TestAuthJwtIssuer(t *testing.T) {
 	t.Parallel()
-	repo := authRepository(t, nil)
+	repo := authRepository(t, kvRepo(t))
 
 	user := newUser(t)
 	data := dto.NewAuthToken(&user, "", time.Hour)
@@ -129,9 +415,283 @@ func TestAuthJwtIssuer(t *testing.T) {
 	token, err := repo.EncodeToken(data)
 	assert.NoError(t, err)
 
-	data2, err := repo.DecodeToken(token)
+	data2, err := repo.DecodeToken(context.Background(), token)
 	assert.NoError(t, err)
 
 	data.Issuer = issuer
 	assert.Equal(t, data, data2)
 }
+
+func TestAuthRefreshTokenRotation(t *testing.T) {
+	t.Parallel()
+	repo := authRepository(t, kvRepo(t))
+
+	userId := dto.NewSnowflake()
+
+	token1, session, err := repo.GenRefreshToken(
+		context.Background(),
+		userId,
+		sessionMetadata(),
+	)
+	assert.NoError(t, err)
+
+	token2, session2, err := repo.RotateRefreshToken(
+		context.Background(),
+		token1,
+		sessionMetadata(),
+	)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token1, token2)
+	assert.Equal(t, session.ID, session2.ID)
+
+	t.Run("Grace", func(t *testing.T) {
+		token, session2, err := repo.RotateRefreshToken(
+			context.Background(),
+			token1,
+			sessionMetadata(),
+		)
+		assert.NoError(t, err)
+		assert.Empty(t, token)
+		assert.Equal(t, session.ID, session2.ID)
+	})
+
+	token3, _, err := repo.RotateRefreshToken(
+		context.Background(),
+		token2,
+		sessionMetadata(),
+	)
+	assert.NoError(t, err)
+
+	t.Run("Reuse", func(t *testing.T) {
+		_, _, err := repo.RotateRefreshToken(
+			context.Background(),
+			token1,
+			sessionMetadata(),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidRefreshToken)
+
+		// The whole session must be revoked
+		_, _, err = repo.RotateRefreshToken(
+			context.Background(),
+			token3,
+			sessionMetadata(),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidRefreshToken)
+
+		sessions, err := repo.GetSessions(context.Background(), userId)
+		assert.NoError(t, err)
+		assert.Len(t, sessions, 0)
+	})
+}
+
+func TestAuthJwtRevocation(t *testing.T) {
+	t.Parallel()
+	repo := authRepository(t, kvRepo(t))
+
+	user := newUser(t)
+
+	encode := func() (dto.AuthToken, string) {
+		data := dto.NewAuthToken(&user, issuer, time.Hour)
+
+		token, err := repo.EncodeToken(data)
+		assert.NoError(t, err)
+
+		return data, token
+	}
+
+	t.Run("Token", func(t *testing.T) {
+		data, token := encode()
+		_, token2 := encode()
+
+		err := repo.RevokeToken(context.Background(), data)
+		assert.NoError(t, err)
+
+		_, err = repo.DecodeToken(context.Background(), token)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrRevokedAuthToken)
+
+		_, err = repo.DecodeToken(context.Background(), token2)
+		assert.NoError(t, err)
+	})
+
+	t.Run("User", func(t *testing.T) {
+		_, token := encode()
+
+		time.Sleep(2 * time.Millisecond)
+
+		err := repo.RevokeUserTokens(context.Background(), user.ID)
+		assert.NoError(t, err)
+
+		_, err = repo.DecodeToken(context.Background(), token)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrRevokedAuthToken)
+
+		time.Sleep(2 * time.Millisecond)
+
+		_, token2 := encode()
+		_, err = repo.DecodeToken(context.Background(), token2)
+		assert.NoError(t, err)
+	})
+}
+
+func TestAuthJwtKeyRotation(t *testing.T) {
+	t.Parallel()
+	kv := kvRepo(t)
+
+	key1, err := keyring.GenerateKey()
+	assert.NoError(t, err)
+	key2, err := keyring.GenerateKey()
+	assert.NoError(t, err)
+
+	keys1, err := keyring.New([]keyring.Key{key1}, key1.ID)
+	assert.NoError(t, err)
+	keys2, err := keyring.New([]keyring.Key{key1, key2}, key2.ID)
+	assert.NoError(t, err)
+	keys3, err := keyring.New([]keyring.Key{key2}, key2.ID)
+	assert.NoError(t, err)
+
+	repo1 := repository.NewAuthRepository(keys1, issuer, kv)
+	repo2 := repository.NewAuthRepository(keys2, issuer, kv)
+	repo3 := repository.NewAuthRepository(keys3, issuer, kv)
+
+	user := newUser(t)
+
+	token1, err := repo1.EncodeToken(dto.NewAuthToken(&user, "", time.Hour))
+	assert.NoError(t, err)
+	token2, err := repo2.EncodeToken(dto.NewAuthToken(&user, "", time.Hour))
+	assert.NoError(t, err)
+
+	// Tokens signed with the previous key are still valid
+	_, err = repo2.DecodeToken(context.Background(), token1)
+	assert.NoError(t, err)
+	_, err = repo2.DecodeToken(context.Background(), token2)
+	assert.NoError(t, err)
+
+	// Until it is retired
+	_, err = repo3.DecodeToken(context.Background(), token1)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, repository.ErrInvalidAuthToken)
+	_, err = repo3.DecodeToken(context.Background(), token2)
+	assert.NoError(t, err)
+}
+
+func TestAuthActionToken(t *testing.T) {
+	t.Parallel()
+	repo := authRepository(t, kvRepo(t))
+
+	user := newUser(t)
+	const purpose = dto.ActionVerifyEmail
+
+	token, err := repo.GenActionToken(context.Background(), &user, purpose, time.Hour)
+	assert.NoError(t, err)
+
+	t.Run("NotAuthToken", func(t *testing.T) {
+		_, err := repo.DecodeToken(context.Background(), token)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidAuthToken)
+	})
+
+	t.Run("AuthTokenNotAction", func(t *testing.T) {
+		authToken, err := repo.EncodeToken(dto.NewAuthToken(&user, "", time.Hour))
+		assert.NoError(t, err)
+
+		_, err = repo.ConsumeActionToken(context.Background(), authToken, purpose)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidActionToken)
+	})
+
+	t.Run("WrongPurpose", func(t *testing.T) {
+		_, err := repo.ConsumeActionToken(context.Background(), token, "other")
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidActionToken)
+	})
+
+	t.Run("Consume", func(t *testing.T) {
+		data, err := repo.ConsumeActionToken(context.Background(), token, purpose)
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, data.ID)
+		assert.Equal(t, user.Email, data.Email)
+		assert.Equal(t, issuer, data.Issuer)
+
+		_, err = repo.ConsumeActionToken(context.Background(), token, purpose)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidActionToken)
+	})
+
+	t.Run("Superseded", func(t *testing.T) {
+		token, err := repo.GenActionToken(context.Background(), &user, purpose, time.Hour)
+		assert.NoError(t, err)
+
+		token2, err := repo.GenActionToken(context.Background(), &user, purpose, time.Hour)
+		assert.NoError(t, err)
+
+		_, err = repo.ConsumeActionToken(context.Background(), token, purpose)
+		assert.ErrorIs(t, err, repository.ErrInvalidActionToken)
+
+		_, err = repo.ConsumeActionToken(context.Background(), token2, purpose)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token, err := repo.GenActionToken(context.Background(), &user, purpose, time.Second)
+		assert.NoError(t, err)
+
+		time.Sleep(2 * time.Second)
+
+		_, err = repo.ConsumeActionToken(context.Background(), token, purpose)
+		assert.ErrorIs(t, err, repository.ErrExpiredActionToken)
+	})
+}
+
+func TestAuthTwoFactorToken(t *testing.T) {
+	t.Parallel()
+	repo := authRepository(t, kvRepo(t))
+
+	userId := dto.NewSnowflake()
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := repo.GetTwoFactorToken(context.Background(), "invalid")
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidTwoFactorToken)
+	})
+
+	t.Run("Consume", func(t *testing.T) {
+		token, err := repo.GenTwoFactorToken(context.Background(), userId)
+		assert.NoError(t, err)
+
+		userId2, err := repo.GetTwoFactorToken(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, userId, userId2)
+
+		err = repo.ConsumeTwoFactorToken(context.Background(), token)
+		assert.NoError(t, err)
+
+		err = repo.ConsumeTwoFactorToken(context.Background(), token)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidTwoFactorToken)
+
+		_, err = repo.GetTwoFactorToken(context.Background(), token)
+		assert.ErrorIs(t, err, repository.ErrInvalidTwoFactorToken)
+	})
+
+	t.Run("TooManyAttempts", func(t *testing.T) {
+		token, err := repo.GenTwoFactorToken(context.Background(), userId)
+		assert.NoError(t, err)
+
+		for range 4 {
+			err = repo.FailTwoFactorToken(context.Background(), token)
+			assert.NoError(t, err)
+		}
+
+		_, err = repo.GetTwoFactorToken(context.Background(), token)
+		assert.NoError(t, err)
+
+		err = repo.FailTwoFactorToken(context.Background(), token)
+		assert.NoError(t, err)
+
+		_, err = repo.GetTwoFactorToken(context.Background(), token)
+		assert.ErrorIs(t, err, repository.ErrInvalidTwoFactorToken)
+	})
+}
@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+)
+
+const (
+	_ = 3000 + iota
+
+	CodeCommentNotFound
+	CodeCommentAlreadyExists
+)
+
+var (
+	ErrCommentNotFound = errutils.NewHttpS(
+		"Comment not found",
+		http.StatusNotFound,
+		CodeCommentNotFound,
+		true,
+	)
+	ErrCommentAlreadyExists = errutils.NewHttpS(
+		"Comment already exists",
+		http.StatusConflict,
+		CodeCommentAlreadyExists,
+		true,
+	)
+)
+
+type CommentRepository struct {
+	q commentQueries
+}
+
+func NewCommentRepository(db *sqlx.DB) *CommentRepository {
+	return &CommentRepository{
+		q: newCommentQueries(db),
+	}
+}
+
+func (r *CommentRepository) Create(ctx context.Context, comment dto.Comment) error {
+	sttm, err := r.q.Create()
+	if err != nil {
+		return err
+	}
+
+	parentId := sql.NullInt64{Int64: int64(comment.ParentID)}
+	if comment.ParentID != 0 {
+		parentId.Valid = true
+	}
+
+	_, err = sttm.ExecContext(ctx,
+		comment.ID,
+		comment.CreatedAt,
+		comment.UpdatedAt,
+		comment.ArticleID,
+		comment.UserID,
+		comment.Content,
+		comment.Hidden,
+		parentId,
+		comment.Depth,
+	)
+	if err != nil {
+		if isUniqueConstraintViolation(err) {
+			err = ErrCommentAlreadyExists
+		} else {
+			slog.Error("CommentRepository: Create: sql error", "error", err)
+		}
+	}
+	return err
+}
+
+func (r *CommentRepository) Get(ctx context.Context, id dto.Snowflake) (dto.Comment, error) {
+	var comment dto.Comment
+
+	sttm, err := r.q.GetQ()
+	if err != nil {
+		return comment, err
+	}
+
+	if err = sttm.GetContext(ctx, &comment, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrCommentNotFound
+		} else {
+			slog.Error("CommentRepository: Get: sql error", "error", err)
+		}
+	}
+	return comment, err
+}
+
+func (r *CommentRepository) GetManyByArticle(
+	ctx context.Context,
+	articleId dto.Snowflake,
+	pag dto.Pagination,
+) ([]dto.Comment, error) {
+	if pag.LastSeen == 0 {
+		// math.MaxUint64 results int integer overflow
+		pag.LastSeen = math.MaxInt64
+	}
+
+	sttm, err := r.q.GetManyByArticle()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sttm.QueryxContext(ctx, articleId, pag.LastSeen, pag.Limit)
+	if err != nil {
+		slog.Error("CommentRepository: GetManyByArticle: sql error", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []dto.Comment{}
+
+	for rows.Next() {
+		var res struct {
+			Comment dto.Comment       `db:"comments"`
+			User    dto.CommentAuthor `db:"users"`
+		}
+
+		if err = rows.StructScan(&res); err != nil {
+			return nil, err
+		}
+
+		res.Comment.User = &res.User
+		comments = append(comments, res.Comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// Fetches a page of top-level comments of an article, newest first, each
+// one with its first `replies` replies, oldest first.
+func (r *CommentRepository) GetThreads(
+	ctx context.Context,
+	articleId dto.Snowflake,
+	pag dto.Pagination,
+	replies int,
+) ([]dto.Comment, error) {
+	if pag.LastSeen == 0 {
+		// math.MaxUint64 results int integer overflow
+		pag.LastSeen = math.MaxInt64
+	}
+
+	sttm, err := r.q.GetThreads()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sttm.QueryxContext(ctx, articleId, pag.LastSeen, pag.Limit, replies)
+	if err != nil {
+		slog.Error("CommentRepository: GetThreads: sql error", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments, err := scanThreads(rows, 0)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(comments)
+
+	return comments, nil
+}
+
+// Fetches a page of replies of a comment, oldest first, each one with its
+// first `replies` replies. Differently from the other paginated methods,
+// pag.LastSeen is the last reply of the previous page in ascending order.
+func (r *CommentRepository) GetReplies(
+	ctx context.Context,
+	parentId dto.Snowflake,
+	pag dto.Pagination,
+	replies int,
+) ([]dto.Comment, error) {
+	sttm, err := r.q.GetReplies()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sttm.QueryxContext(ctx, parentId, pag.LastSeen, pag.Limit, replies)
+	if err != nil {
+		slog.Error("CommentRepository: GetReplies: sql error", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanThreads(rows, parentId)
+}
+
+// Scans rows sorted by ascending id, attaching each reply to its parent
+// and returning the comments whose parent is `root`.
+func scanThreads(rows *sqlx.Rows, root dto.Snowflake) ([]dto.Comment, error) {
+	comments := []dto.Comment{}
+	replies := make(map[dto.Snowflake][]dto.Comment)
+
+	for rows.Next() {
+		var res struct {
+			Comment dto.Comment       `db:"comments"`
+			User    dto.CommentAuthor `db:"users"`
+		}
+
+		if err := rows.StructScan(&res); err != nil {
+			return nil, err
+		}
+
+		res.Comment.User = &res.User
+		if res.Comment.ParentID == root {
+			comments = append(comments, res.Comment)
+		} else {
+			parent := res.Comment.ParentID
+			replies[parent] = append(replies[parent], res.Comment)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range comments {
+		comments[i].Replies = replies[comments[i].ID]
+	}
+
+	return comments, nil
+}
+
+func (r *CommentRepository) UpdateContent(
+	ctx context.Context,
+	id dto.Snowflake,
+	content string,
+) (dto.Comment, error) {
+	now := time.Now().UnixMilli()
+
+	var comment dto.Comment
+
+	sttm, err := r.q.UpdateContent()
+	if err != nil {
+		return comment, err
+	}
+
+	err = sttm.GetContext(ctx, &comment, content, now, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrCommentNotFound
+		} else {
+			slog.Error("CommentRepository: UpdateContent: sql error", "error", err)
+		}
+	}
+	return comment, err
+}
+
+func (r *CommentRepository) UpdateHidden(
+	ctx context.Context,
+	id dto.Snowflake,
+	hidden bool,
+) (dto.Comment, error) {
+	var comment dto.Comment
+
+	sttm, err := r.q.UpdateHidden()
+	if err != nil {
+		return comment, err
+	}
+
+	err = sttm.GetContext(ctx, &comment, hidden, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrCommentNotFound
+		} else {
+			slog.Error("CommentRepository: UpdateHidden: sql error", "error", err)
+		}
+	}
+	return comment, err
+}
+
+func (r *CommentRepository) Delete(ctx context.Context, id dto.Snowflake) (dto.Comment, error) {
+	var comment dto.Comment
+
+	sttm, err := r.q.Delete()
+	if err != nil {
+		return comment, err
+	}
+
+	err = sttm.GetContext(ctx, &comment, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrCommentNotFound
+		} else {
+			slog.Error("CommentRepository: Delete: sql error", "error", err)
+		}
+	}
+	return comment, err
+}
+
+func (r *CommentRepository) Close() error {
+	return r.q.Close()
+}
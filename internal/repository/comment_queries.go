@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/utils"
+)
+
+const commentCreateQuery = `INSERT INTO comments
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+const commentGetQuery = `SELECT
+id, created_at, updated_at, article_id, user_id, content, hidden,
+parent_id, depth
+FROM comments WHERE id = $1`
+
+const commentGetManyByArticleQuery = `SELECT
+comments.id "comments.id",
+comments.created_at "comments.created_at",
+comments.updated_at "comments.updated_at",
+comments.article_id "comments.article_id",
+comments.user_id "comments.user_id",
+comments.content "comments.content",
+comments.hidden "comments.hidden",
+comments.parent_id "comments.parent_id",
+comments.depth "comments.depth",
+users.id "users.id",
+users.nickname "users.nickname",
+users.name "users.name"
+FROM comments
+INNER JOIN users ON comments.user_id = users.id
+WHERE comments.article_id = $1 AND comments.id < $2
+ORDER BY comments.id DESC LIMIT $3`
+
+// Selects a page of top-level comments of an article together with the
+// first $4 replies of each one of them.
+const commentGetThreadsQuery = `WITH page AS (
+SELECT id FROM comments
+WHERE article_id = $1 AND parent_id IS NULL AND id < $2
+ORDER BY id DESC LIMIT $3
+), replies AS (
+SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY id) AS n
+FROM comments WHERE parent_id IN (SELECT id FROM page)
+)
+SELECT ` + commentThreadColumns + `
+FROM comments
+INNER JOIN users ON comments.user_id = users.id
+WHERE comments.id IN (SELECT id FROM page)
+OR comments.id IN (SELECT id FROM replies WHERE n <= $4)
+ORDER BY comments.id`
+
+// Selects a page of replies of a comment together with the first $4
+// replies of each one of them.
+const commentGetRepliesQuery = `WITH page AS (
+SELECT id FROM comments
+WHERE parent_id = $1 AND id > $2
+ORDER BY id LIMIT $3
+), replies AS (
+SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY id) AS n
+FROM comments WHERE parent_id IN (SELECT id FROM page)
+)
+SELECT ` + commentThreadColumns + `
+FROM comments
+INNER JOIN users ON comments.user_id = users.id
+WHERE comments.id IN (SELECT id FROM page)
+OR comments.id IN (SELECT id FROM replies WHERE n <= $4)
+ORDER BY comments.id`
+
+const commentThreadColumns = `comments.id "comments.id",
+comments.created_at "comments.created_at",
+comments.updated_at "comments.updated_at",
+comments.article_id "comments.article_id",
+comments.user_id "comments.user_id",
+comments.content "comments.content",
+comments.hidden "comments.hidden",
+comments.parent_id "comments.parent_id",
+comments.depth "comments.depth",
+(SELECT COUNT(*) FROM comments r WHERE r.parent_id = comments.id)
+"comments.reply_count",
+users.id "users.id",
+users.nickname "users.nickname",
+users.name "users.name"`
+
+const commentUpdateContentQuery = `UPDATE comments
+SET content = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, article_id, user_id, content, hidden,
+parent_id, depth`
+
+const commentUpdateHiddenQuery = `UPDATE comments
+SET hidden = $1
+WHERE id = $2
+RETURNING id, created_at, updated_at, article_id, user_id, content, hidden,
+parent_id, depth`
+
+const commentDeleteQuery = `DELETE FROM comments
+WHERE id = $1
+RETURNING id, created_at, updated_at, article_id, user_id, content, hidden,
+parent_id, depth`
+
+type commentQueries struct {
+	*utils.Queries
+}
+
+func newCommentQueries(db *sqlx.DB) commentQueries {
+	q := utils.NewQueries(db, "CommentQueries")
+
+	q.Add(commentCreateQuery, "Create")
+	q.Add(commentGetQuery, "Get")
+	q.Add(commentGetManyByArticleQuery, "GetManyByArticle")
+	q.Add(commentGetThreadsQuery, "GetThreads")
+	q.Add(commentGetRepliesQuery, "GetReplies")
+
+	q.Add(commentUpdateContentQuery, "UpdateContent")
+	q.Add(commentUpdateHiddenQuery, "UpdateHidden")
+
+	q.Add(commentDeleteQuery, "Delete")
+
+	return commentQueries{q}
+}
+
+func (q *commentQueries) Create() (*sqlx.Stmt, error) {
+	return q.Get("Create")
+}
+
+func (q *commentQueries) GetQ() (*sqlx.Stmt, error) {
+	return q.Get("Get")
+}
+
+func (q *commentQueries) GetManyByArticle() (*sqlx.Stmt, error) {
+	return q.Get("GetManyByArticle")
+}
+
+func (q *commentQueries) GetThreads() (*sqlx.Stmt, error) {
+	return q.Get("GetThreads")
+}
+
+func (q *commentQueries) GetReplies() (*sqlx.Stmt, error) {
+	return q.Get("GetReplies")
+}
+
+func (q *commentQueries) UpdateContent() (*sqlx.Stmt, error) {
+	return q.Get("UpdateContent")
+}
+
+func (q *commentQueries) UpdateHidden() (*sqlx.Stmt, error) {
+	return q.Get("UpdateHidden")
+}
+
+func (q *commentQueries) Delete() (*sqlx.Stmt, error) {
+	return q.Get("Delete")
+}
@@ -0,0 +1,366 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+)
+
+func commentRepo(t *testing.T) (
+	*repository.CommentRepository,
+	*repository.ArticleRepository,
+	*repository.UserRepository,
+) {
+	db := GetDb(t)
+	repo := repository.NewCommentRepository(db)
+	articleRepo := repository.NewArticleRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	return repo, articleRepo, userRepo
+}
+
+func commentAuthor(user dto.User) *dto.CommentAuthor {
+	return &dto.CommentAuthor{
+		ID:       user.ID,
+		Nickname: user.Nickname,
+		Name:     user.Name,
+	}
+}
+
+func commentData() dto.CommentCreateData {
+	return dto.CommentCreateData{Content: randString(256)}
+}
+
+func TestCommentCreate(t *testing.T) {
+	t.Parallel()
+	comments, articles, users := commentRepo(t)
+
+	t.Run("Inexistent", func(t *testing.T) {
+		t.Parallel()
+		_, err := comments.Get(context.Background(), dto.NewSnowflake())
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrCommentNotFound)
+	})
+
+	comment := createComment(t, comments, articles, users)
+
+	t.Run("Duplicate", func(t *testing.T) {
+		err := comments.Create(context.Background(), comment)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrCommentAlreadyExists)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		comment2, err := comments.Get(context.Background(), comment.ID)
+		assert.NoError(t, err)
+
+		assert.Nil(t, comment2.User)
+		assert.Equal(t, comment, comment2)
+	})
+}
+
+func TestCommentUpdate(t *testing.T) {
+	t.Parallel()
+	comments, articles, users := commentRepo(t)
+
+	t.Run("Inexistent", func(t *testing.T) {
+		t.Parallel()
+		_, err := comments.UpdateContent(
+			context.Background(),
+			dto.NewSnowflake(),
+			randString(32),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrCommentNotFound)
+
+		_, err = comments.UpdateHidden(
+			context.Background(),
+			dto.NewSnowflake(),
+			true,
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrCommentNotFound)
+	})
+
+	comment := createComment(t, comments, articles, users)
+
+	t.Run("UpdateContent", func(t *testing.T) {
+		content := randString(128)
+
+		time.Sleep(5 * time.Millisecond)
+
+		comment2, err := comments.UpdateContent(
+			context.Background(),
+			comment.ID,
+			content,
+		)
+		assert.NoError(t, err)
+
+		assert.Equal(t, content, comment2.Content)
+		assert.Greater(t,
+			comment2.UpdatedAt.UnixMilli(),
+			comment.UpdatedAt.UnixMilli(),
+		)
+
+		comment.Content = content
+		comment.UpdatedAt = comment2.UpdatedAt
+		assert.Equal(t, comment, comment2)
+	})
+
+	t.Run("UpdateHidden", func(t *testing.T) {
+		comment2, err := comments.UpdateHidden(
+			context.Background(),
+			comment.ID,
+			true,
+		)
+		assert.NoError(t, err)
+		assert.True(t, comment2.Hidden)
+
+		comment.Hidden = true
+		assert.Equal(t, comment, comment2)
+	})
+
+	t.Run("Fetch", func(t *testing.T) {
+		comment2, err := comments.Get(context.Background(), comment.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, comment, comment2)
+	})
+}
+
+func TestCommentDelete(t *testing.T) {
+	t.Parallel()
+	comments, articles, users := commentRepo(t)
+
+	t.Run("Inexistent", func(t *testing.T) {
+		t.Parallel()
+		_, err := comments.Delete(context.Background(), dto.NewSnowflake())
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrCommentNotFound)
+	})
+
+	comment := createComment(t, comments, articles, users)
+
+	t.Run("Delete", func(t *testing.T) {
+		comment2, err := comments.Delete(context.Background(), comment.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, comment, comment2)
+	})
+
+	t.Run("Fetch", func(t *testing.T) {
+		_, err := comments.Get(context.Background(), comment.ID)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrCommentNotFound)
+	})
+}
+
+func TestCommentDeleteReplies(t *testing.T) {
+	t.Parallel()
+	comments, articles, users := commentRepo(t)
+
+	comment := createComment(t, comments, articles, users)
+
+	reply := dto.NewReply(comment, comment.UserID, commentData())
+	assert.NoError(t, comments.Create(context.Background(), reply))
+	nested := dto.NewReply(reply, comment.UserID, commentData())
+	assert.NoError(t, comments.Create(context.Background(), nested))
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := comments.Delete(context.Background(), comment.ID)
+		assert.NoError(t, err)
+	})
+
+	for _, id := range []dto.Snowflake{reply.ID, nested.ID} {
+		t.Run("FetchReply", func(t *testing.T) {
+			_, err := comments.Get(context.Background(), id)
+			assert.Error(t, err)
+			assert.ErrorIs(t, err, repository.ErrCommentNotFound)
+		})
+	}
+}
+
+func TestCommentGetMany(t *testing.T) {
+	const (
+		Count    = 17
+		PageSize = 5
+	)
+
+	t.Parallel()
+
+	db, err := InitDb(t)
+	assert.NoError(t, err)
+
+	comments := repository.NewCommentRepository(db)
+	articles := repository.NewArticleRepository(db)
+	users := repository.NewUserRepository(db)
+
+	article, user := createArticle(t, articles, users)
+	author := commentAuthor(user)
+
+	// Comments of other articles must not be returned
+	createComment(t, comments, articles, users)
+
+	expected := make([]dto.Comment, Count)
+	for i := range Count {
+		comment := dto.NewComment(article.ID, user.ID, commentData())
+		err := comments.Create(context.Background(), comment)
+		assert.NoError(t, err)
+
+		comment.User = author
+		expected[i] = comment
+	}
+	slices.SortFunc(expected, func(a, b dto.Comment) int {
+		if b.ID > a.ID {
+			return 1
+		} else if a.ID > b.ID {
+			return -1
+		}
+		return 0
+	})
+
+	result := []dto.Comment{}
+	for {
+		lastSeen := dto.Snowflake(0)
+		if len(result) != 0 {
+			lastSeen = result[len(result)-1].ID
+		}
+
+		page, err := comments.GetManyByArticle(
+			context.Background(),
+			article.ID,
+			dto.Pagination{Limit: PageSize, LastSeen: lastSeen},
+		)
+		assert.NoError(t, err)
+
+		result = append(result, page...)
+
+		if len(page) < PageSize {
+			break
+		}
+	}
+
+	assert.Equal(t, expected, result)
+}
+
+func createComment(
+	t *testing.T,
+	comments *repository.CommentRepository,
+	articles *repository.ArticleRepository,
+	users *repository.UserRepository,
+) dto.Comment {
+	article, user := createArticle(t, articles, users)
+
+	comment := dto.NewComment(article.ID, user.ID, commentData())
+
+	assert.True(t, t.Run("CreateComment", func(t *testing.T) {
+		err := comments.Create(context.Background(), comment)
+		assert.NoError(t, err)
+	}))
+
+	return comment
+}
+
+func TestCommentThreads(t *testing.T) {
+	const (
+		Count    = 4
+		Replies  = 5
+		Inline   = 2
+		PageSize = 3
+	)
+
+	t.Parallel()
+
+	db, err := InitDb(t)
+	assert.NoError(t, err)
+
+	comments := repository.NewCommentRepository(db)
+	articles := repository.NewArticleRepository(db)
+	users := repository.NewUserRepository(db)
+
+	article, user := createArticle(t, articles, users)
+	author := commentAuthor(user)
+
+	create := func(comment dto.Comment) dto.Comment {
+		err := comments.Create(context.Background(), comment)
+		assert.NoError(t, err)
+
+		comment.User = author
+		return comment
+	}
+
+	threads := make([]dto.Comment, Count)
+	replies := make([][]dto.Comment, Count)
+	for i := range Count {
+		threads[i] = create(dto.NewComment(article.ID, user.ID, commentData()))
+
+		replies[i] = make([]dto.Comment, Replies)
+		for j := range Replies {
+			time.Sleep(time.Millisecond)
+			replies[i][j] = create(dto.NewReply(threads[i], user.ID, commentData()))
+		}
+		threads[i].ReplyCount = Replies
+	}
+
+	nested := create(dto.NewReply(replies[0][0], user.ID, commentData()))
+	assert.Equal(t, 2, nested.Depth)
+	replies[0][0].ReplyCount = 1
+
+	t.Run("GetThreads", func(t *testing.T) {
+		result, err := comments.GetThreads(
+			context.Background(),
+			article.ID,
+			dto.Pagination{Limit: PageSize},
+			Inline,
+		)
+		assert.NoError(t, err)
+		assert.Len(t, result, PageSize)
+
+		for i, comment := range result {
+			expected := threads[Count-1-i]
+			expected.Replies = replies[Count-1-i][:Inline]
+			assert.Equal(t, expected, comment)
+		}
+
+		result, err = comments.GetThreads(
+			context.Background(),
+			article.ID,
+			dto.Pagination{Limit: PageSize, LastSeen: result[PageSize-1].ID},
+			Inline,
+		)
+		assert.NoError(t, err)
+		assert.Len(t, result, Count-PageSize)
+		assert.Equal(t, threads[0].ID, result[0].ID)
+	})
+
+	t.Run("GetReplies", func(t *testing.T) {
+		result := []dto.Comment{}
+		for {
+			lastSeen := dto.Snowflake(0)
+			if len(result) != 0 {
+				lastSeen = result[len(result)-1].ID
+			}
+
+			page, err := comments.GetReplies(
+				context.Background(),
+				threads[0].ID,
+				dto.Pagination{Limit: PageSize, LastSeen: lastSeen},
+				Inline,
+			)
+			assert.NoError(t, err)
+
+			result = append(result, page...)
+
+			if len(page) < PageSize {
+				break
+			}
+		}
+
+		expected := slices.Clone(replies[0])
+		expected[0].Replies = []dto.Comment{nested}
+		assert.Equal(t, expected, result)
+	})
+
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+)
+
+const (
+	_ = 9100 + iota
+
+	CodeIdentityNotFound
+	CodeIdentityAlreadyExists
+)
+
+var (
+	ErrIdentityNotFound = errutils.NewHttpS(
+		"Identity not found",
+		http.StatusNotFound,
+		CodeIdentityNotFound,
+		true,
+	)
+	ErrIdentityAlreadyExists = errutils.NewHttpS(
+		"Identity already linked to an user",
+		http.StatusConflict,
+		CodeIdentityAlreadyExists,
+		true,
+	)
+)
+
+// Stores the accounts users have on external identity providers.
+type IdentityRepository struct {
+	q identityQueries
+}
+
+func NewIdentityRepository(db *sqlx.DB) *IdentityRepository {
+	return &IdentityRepository{q: newIdentityQueries(db)}
+}
+
+func (r *IdentityRepository) Create(ctx context.Context, identity dto.Identity) error {
+	sttm, err := r.q.Create()
+	if err != nil {
+		return err
+	}
+
+	_, err = sttm.ExecContext(ctx,
+		identity.ID,
+		identity.CreatedAt,
+		identity.UserID,
+		identity.Issuer,
+		identity.Subject,
+		identity.Email,
+	)
+	if err != nil {
+		if isUniqueConstraintViolation(err) {
+			err = ErrIdentityAlreadyExists
+		} else {
+			slog.Error("IdentityRepository: Create: sql error", "error", err)
+		}
+	}
+	return err
+}
+
+// Fetches the identity of the account on the given provider.
+func (r *IdentityRepository) GetBySubject(
+	ctx context.Context,
+	issuer string,
+	subject string,
+) (dto.Identity, error) {
+	var identity dto.Identity
+
+	sttm, err := r.q.GetBySubject()
+	if err != nil {
+		return identity, err
+	}
+
+	if err = sttm.GetContext(ctx, &identity, issuer, subject); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrIdentityNotFound
+		} else {
+			slog.Error("IdentityRepository: GetBySubject: sql error", "error", err)
+		}
+	}
+	return identity, err
+}
+
+// Fetches all the identities of the user, newest first.
+func (r *IdentityRepository) GetByUser(
+	ctx context.Context,
+	userId dto.Snowflake,
+) ([]dto.Identity, error) {
+	sttm, err := r.q.GetByUser()
+	if err != nil {
+		return nil, err
+	}
+
+	identities := []dto.Identity{}
+	if err = sttm.SelectContext(ctx, &identities, userId); err != nil {
+		slog.Error("IdentityRepository: GetByUser: sql error", "error", err)
+		return nil, err
+	}
+	return identities, nil
+}
+
+// Unlinks the identity, making sure it belongs to the user.
+func (r *IdentityRepository) Delete(
+	ctx context.Context,
+	id dto.Snowflake,
+	userId dto.Snowflake,
+) (dto.Identity, error) {
+	var identity dto.Identity
+
+	sttm, err := r.q.Delete()
+	if err != nil {
+		return identity, err
+	}
+
+	if err = sttm.GetContext(ctx, &identity, id, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrIdentityNotFound
+		} else {
+			slog.Error("IdentityRepository: Delete: sql error", "error", err)
+		}
+	}
+	return identity, err
+}
+
+func (r *IdentityRepository) Close() error {
+	return r.q.Close()
+}
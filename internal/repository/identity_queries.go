@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/utils"
+)
+
+const identityCreateQuery = `INSERT INTO user_identities
+VALUES ($1, $2, $3, $4, $5, $6)`
+
+const identityGetBySubjectQuery = `SELECT * FROM user_identities
+WHERE issuer = $1 AND subject = $2`
+
+const identityGetByUserQuery = `SELECT * FROM user_identities
+WHERE user_id = $1 ORDER BY id DESC`
+
+const identityDeleteQuery = `DELETE FROM user_identities
+WHERE id = $1 AND user_id = $2 RETURNING *`
+
+type identityQueries struct {
+	*utils.Queries
+}
+
+func newIdentityQueries(db *sqlx.DB) identityQueries {
+	q := utils.NewQueries(db, "IdentityQueries")
+
+	q.Add(identityCreateQuery, "Create")
+	q.Add(identityGetBySubjectQuery, "GetBySubject")
+	q.Add(identityGetByUserQuery, "GetByUser")
+	q.Add(identityDeleteQuery, "Delete")
+
+	return identityQueries{q}
+}
+
+func (q *identityQueries) Create() (*sqlx.Stmt, error) {
+	return q.Get("Create")
+}
+
+func (q *identityQueries) GetBySubject() (*sqlx.Stmt, error) {
+	return q.Get("GetBySubject")
+}
+
+func (q *identityQueries) GetByUser() (*sqlx.Stmt, error) {
+	return q.Get("GetByUser")
+}
+
+func (q *identityQueries) Delete() (*sqlx.Stmt, error) {
+	return q.Get("Delete")
+}
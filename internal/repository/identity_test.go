@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+)
+
+const identityIssuer = "https://idp.example.com"
+
+func identityRepo(t *testing.T) (*repository.IdentityRepository, dto.User) {
+	db := GetDb(t)
+	repo := repository.NewIdentityRepository(db)
+	userRepo := repository.NewUserRepository(db)
+
+	data := userData()
+	user := dto.NewExternalUser(
+		data.Email,
+		data.Nickname,
+		data.Name,
+		dto.PermissionDefault,
+		true,
+	)
+	assert.NoError(t, userRepo.Create(context.Background(), user))
+
+	return repo, user
+}
+
+func TestIdentity(t *testing.T) {
+	t.Parallel()
+	repo, user := identityRepo(t)
+
+	t.Run("Inexistent", func(t *testing.T) {
+		t.Parallel()
+		_, err := repo.GetBySubject(context.Background(), identityIssuer, randString(16))
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrIdentityNotFound)
+	})
+
+	identity := dto.NewIdentity(user.ID, identityIssuer, randString(16), user.Email)
+	assert.NoError(t, repo.Create(context.Background(), identity))
+
+	t.Run("Duplicate", func(t *testing.T) {
+		other := dto.NewIdentity(user.ID, identityIssuer, identity.Subject, user.Email)
+		err := repo.Create(context.Background(), other)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrIdentityAlreadyExists)
+	})
+
+	t.Run("OtherIssuer", func(t *testing.T) {
+		time.Sleep(2 * time.Millisecond)
+		other := dto.NewIdentity(user.ID, "https://other.example.com", identity.Subject, user.Email)
+		assert.NoError(t, repo.Create(context.Background(), other))
+
+		identities, err := repo.GetByUser(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Len(t, identities, 2)
+		assert.Equal(t, other, identities[0])
+	})
+
+	t.Run("GetBySubject", func(t *testing.T) {
+		identity2, err := repo.GetBySubject(
+			context.Background(),
+			identityIssuer,
+			identity.Subject,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, identity, identity2)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := repo.Delete(context.Background(), identity.ID, dto.NewSnowflake())
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrIdentityNotFound)
+
+		deleted, err := repo.Delete(context.Background(), identity.ID, user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, identity, deleted)
+
+		_, err = repo.GetBySubject(context.Background(), identityIssuer, identity.Subject)
+		assert.ErrorIs(t, err, repository.ErrIdentityNotFound)
+	})
+}
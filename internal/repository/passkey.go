@@ -0,0 +1,414 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/kv"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+)
+
+const (
+	_ = 8000 + iota
+
+	CodePasskeyNotFound
+	CodePasskeyAlreadyExists
+	CodePasskeyInvalid
+	CodePasskeyChallengeInvalid
+)
+
+var (
+	ErrPasskeyNotFound = errutils.NewHttpS(
+		"Passkey not found",
+		http.StatusNotFound,
+		CodePasskeyNotFound,
+		true,
+	)
+	ErrPasskeyAlreadyExists = errutils.NewHttpS(
+		"Passkey already registered",
+		http.StatusConflict,
+		CodePasskeyAlreadyExists,
+		true,
+	)
+	ErrInvalidPasskey = errutils.NewHttpS(
+		"Passkey invalid",
+		http.StatusUnauthorized,
+		CodePasskeyInvalid,
+		true,
+	)
+	ErrInvalidPasskeyChallenge = errutils.NewHttpS(
+		"The passkey request expired, try again",
+		http.StatusBadRequest,
+		CodePasskeyChallengeInvalid,
+		true,
+	)
+)
+
+// Time users have to complete the ceremonies
+const PasskeyChallengeExpiry = 5 * time.Minute
+
+// Stores the passkeys of the users and runs the webauthn ceremonies,
+// keeping their challenges in the kv storage until completed.
+type PasskeyRepository struct {
+	q passkeyQueries
+
+	webauthn *webauthn.WebAuthn
+
+	kv kv.KVStorer
+}
+
+func NewPasskeyRepository(
+	db *sqlx.DB,
+	webauthn *webauthn.WebAuthn,
+	kv kv.KVStorer,
+) *PasskeyRepository {
+	return &PasskeyRepository{
+		q:        newPasskeyQueries(db),
+		webauthn: webauthn,
+		kv:       kv,
+	}
+}
+
+// Fetches all the passkeys of the user, newest first.
+func (r *PasskeyRepository) GetByUser(
+	ctx context.Context,
+	userId dto.Snowflake,
+) ([]dto.Passkey, error) {
+	sttm, err := r.q.GetByUser()
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys := []dto.Passkey{}
+	if err = sttm.SelectContext(ctx, &passkeys, userId); err != nil {
+		slog.Error("PasskeyRepository: GetByUser: sql error", "error", err)
+		return nil, err
+	}
+	return passkeys, nil
+}
+
+func (r *PasskeyRepository) GetByCredential(
+	ctx context.Context,
+	credentialId []byte,
+) (dto.Passkey, error) {
+	var passkey dto.Passkey
+
+	sttm, err := r.q.GetByCredential()
+	if err != nil {
+		return passkey, err
+	}
+
+	if err = sttm.GetContext(ctx, &passkey, credentialId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrPasskeyNotFound
+		} else {
+			slog.Error("PasskeyRepository: GetByCredential: sql error", "error", err)
+		}
+	}
+	return passkey, err
+}
+
+// Starts the registration of a passkey for the user, replacing the
+// pending one, if any. The returned options are passed to
+// `navigator.credentials.create()`.
+func (r *PasskeyRepository) BeginRegistration(
+	ctx context.Context,
+	user *dto.User,
+) (*protocol.CredentialCreation, error) {
+	passkeys, err := r.GetByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pu := dto.PasskeyUser{User: user, Passkeys: passkeys}
+
+	exclusions := make([]protocol.CredentialDescriptor, len(passkeys))
+	for i, cred := range pu.WebAuthnCredentials() {
+		exclusions[i] = cred.Descriptor()
+	}
+
+	creation, session, err := r.webauthn.BeginRegistration(
+		pu,
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			UserVerification: protocol.VerificationRequired,
+		}),
+		// Discoverable credentials let users log in without an email
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.kv.SetValueEx(
+		ctx,
+		passkeyRegistrationKey(user.ID),
+		session,
+		PasskeyChallengeExpiry,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return creation, nil
+}
+
+// Checks the response of the authenticator to the pending registration
+// of the user, storing the created passkey under the given name.
+func (r *PasskeyRepository) FinishRegistration(
+	ctx context.Context,
+	user *dto.User,
+	name string,
+	response io.Reader,
+) (dto.Passkey, error) {
+	parsed, err := protocol.ParseCredentialCreationResponseBody(response)
+	if err != nil {
+		return dto.Passkey{}, ErrInvalidPasskey
+	}
+
+	session, err := r.takeSession(ctx, passkeyRegistrationKey(user.ID))
+	if err != nil {
+		return dto.Passkey{}, err
+	}
+
+	passkeys, err := r.GetByUser(ctx, user.ID)
+	if err != nil {
+		return dto.Passkey{}, err
+	}
+
+	pu := dto.PasskeyUser{User: user, Passkeys: passkeys}
+
+	cred, err := r.webauthn.CreateCredential(pu, session, parsed)
+	if err != nil {
+		slog.Debug("PasskeyRepository: FinishRegistration: invalid credential", "error", err)
+		return dto.Passkey{}, ErrInvalidPasskey
+	}
+
+	passkey := dto.NewPasskey(user.ID, name, cred)
+	if err = r.create(ctx, passkey); err != nil {
+		return dto.Passkey{}, err
+	}
+
+	return passkey, nil
+}
+
+// Starts a passwordless login, in which the authenticator tells who the
+// user is. The returned options are passed to `navigator.credentials.get()`.
+func (r *PasskeyRepository) BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, error) {
+	assertion, session, err := r.webauthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The challenge is sent back in the client data of the response,
+	// so the login does not need to be tracked by the client
+	err = r.kv.SetValueEx(
+		ctx,
+		passkeyLoginKey(session.Challenge),
+		session,
+		PasskeyChallengeExpiry,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return assertion, nil
+}
+
+// Checks the response of the authenticator to a pending login, returning
+// the passkey used, whose owner can have a session started.
+func (r *PasskeyRepository) FinishLogin(
+	ctx context.Context,
+	response io.Reader,
+) (dto.Passkey, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBody(response)
+	if err != nil {
+		return dto.Passkey{}, ErrInvalidPasskey
+	}
+
+	challenge := parsed.Response.CollectedClientData.Challenge
+
+	session, err := r.takeSession(ctx, passkeyLoginKey(challenge))
+	if err != nil {
+		return dto.Passkey{}, err
+	}
+
+	var (
+		passkey   dto.Passkey
+		lookupErr error
+	)
+
+	handler := func(rawId, userHandle []byte) (webauthn.User, error) {
+		passkey, lookupErr = r.GetByCredential(ctx, rawId)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+
+		userId, ok := dto.ParsePasskeyUserHandle(userHandle)
+		if !ok || userId != passkey.UserID {
+			return nil, ErrInvalidPasskey
+		}
+
+		return dto.PasskeyUser{
+			User:     &dto.User{ID: passkey.UserID},
+			Passkeys: []dto.Passkey{passkey},
+		}, nil
+	}
+
+	cred, err := r.webauthn.ValidateDiscoverableLogin(handler, session, parsed)
+	if lookupErr != nil && !errors.Is(lookupErr, ErrPasskeyNotFound) {
+		// Failed to query the database
+		return passkey, lookupErr
+	} else if err != nil {
+		slog.Debug("PasskeyRepository: FinishLogin: invalid assertion", "error", err)
+		return passkey, ErrInvalidPasskey
+	}
+
+	if cred.Authenticator.CloneWarning {
+		slog.Warn(
+			"PasskeyRepository: FinishLogin: signature counter did not increase",
+			"passkey_id", passkey.ID,
+			"user_id", passkey.UserID,
+		)
+		return passkey, ErrInvalidPasskey
+	}
+
+	return r.use(ctx, passkey, cred)
+}
+
+// Deletes the passkey, making sure it belongs to the user.
+func (r *PasskeyRepository) Delete(
+	ctx context.Context,
+	id dto.Snowflake,
+	userId dto.Snowflake,
+) (dto.Passkey, error) {
+	var passkey dto.Passkey
+
+	sttm, err := r.q.Delete()
+	if err != nil {
+		return passkey, err
+	}
+
+	if err = sttm.GetContext(ctx, &passkey, id, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrPasskeyNotFound
+		} else {
+			slog.Error("PasskeyRepository: Delete: sql error", "error", err)
+		}
+	}
+	return passkey, err
+}
+
+func (r *PasskeyRepository) Close() error {
+	return r.q.Close()
+}
+
+func (r *PasskeyRepository) create(ctx context.Context, passkey dto.Passkey) error {
+	sttm, err := r.q.Create()
+	if err != nil {
+		return err
+	}
+
+	_, err = sttm.ExecContext(ctx,
+		passkey.ID,
+		passkey.CreatedAt,
+		passkey.UserID,
+		passkey.Name,
+		passkey.LastUsedAt,
+		passkey.CredentialID,
+		passkey.PublicKey,
+		passkey.AttestationType,
+		passkey.Transports,
+		passkey.AAGUID,
+		passkey.SignCount,
+		passkey.Flags,
+	)
+	if err != nil {
+		if isUniqueConstraintViolation(err) {
+			err = ErrPasskeyAlreadyExists
+		} else {
+			slog.Error("PasskeyRepository: create: sql error", "error", err)
+		}
+	}
+	return err
+}
+
+// Updates the signature counter and flags of the passkey after a login.
+func (r *PasskeyRepository) use(
+	ctx context.Context,
+	passkey dto.Passkey,
+	cred *webauthn.Credential,
+) (dto.Passkey, error) {
+	sttm, err := r.q.Use()
+	if err != nil {
+		return passkey, err
+	}
+
+	updated := passkey
+	updated.SetFlags(cred.Flags)
+	now := time.Now().UnixMilli()
+
+	err = sttm.GetContext(ctx, &updated,
+		now,
+		int64(cred.Authenticator.SignCount),
+		updated.Flags,
+		passkey.ID,
+		passkey.SignCount,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Deleted or used by a concurrent request
+			err = ErrInvalidPasskey
+		} else {
+			slog.Error("PasskeyRepository: use: sql error", "error", err)
+		}
+	}
+	return updated, err
+}
+
+// Fetches the state of a pending ceremony, making sure it can not be
+// completed again.
+func (r *PasskeyRepository) takeSession(
+	ctx context.Context,
+	key string,
+) (webauthn.SessionData, error) {
+	var session webauthn.SessionData
+
+	err := r.kv.GetValue(ctx, key, &session)
+	if err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = ErrInvalidPasskeyChallenge
+		}
+		return session, err
+	}
+
+	// Only one of concurrent requests succeeds deleting it
+	if err = r.kv.Delete(ctx, key); err != nil {
+		if errors.Is(err, kv.ErrValueNotFound) {
+			err = ErrInvalidPasskeyChallenge
+		}
+		return session, err
+	}
+
+	return session, nil
+}
+
+func passkeyRegistrationKey(userId dto.Snowflake) string {
+	return fmt.Sprintf("passkey_registration/%s", userId)
+}
+
+func passkeyLoginKey(challenge string) string {
+	return fmt.Sprintf("passkey_login/%s", challenge)
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/utils"
+)
+
+const passkeyCreateQuery = `INSERT INTO passkeys
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+const passkeyGetByUserQuery = `SELECT * FROM passkeys
+WHERE user_id = $1 ORDER BY id DESC`
+
+const passkeyGetByCredentialQuery = `SELECT * FROM passkeys
+WHERE credential_id = $1`
+
+// Fails if the passkey was used by a concurrent request
+const passkeyUseQuery = `UPDATE passkeys
+SET last_used_at = $1, sign_count = $2, flags = $3
+WHERE id = $4 AND sign_count = $5 RETURNING *`
+
+const passkeyDeleteQuery = `DELETE FROM passkeys
+WHERE id = $1 AND user_id = $2 RETURNING *`
+
+type passkeyQueries struct {
+	*utils.Queries
+}
+
+func newPasskeyQueries(db *sqlx.DB) passkeyQueries {
+	q := utils.NewQueries(db, "PasskeyQueries")
+
+	q.Add(passkeyCreateQuery, "Create")
+	q.Add(passkeyGetByUserQuery, "GetByUser")
+	q.Add(passkeyGetByCredentialQuery, "GetByCredential")
+	q.Add(passkeyUseQuery, "Use")
+	q.Add(passkeyDeleteQuery, "Delete")
+
+	return passkeyQueries{q}
+}
+
+func (q *passkeyQueries) Create() (*sqlx.Stmt, error) {
+	return q.Get("Create")
+}
+
+func (q *passkeyQueries) GetByUser() (*sqlx.Stmt, error) {
+	return q.Get("GetByUser")
+}
+
+func (q *passkeyQueries) GetByCredential() (*sqlx.Stmt, error) {
+	return q.Get("GetByCredential")
+}
+
+func (q *passkeyQueries) Use() (*sqlx.Stmt, error) {
+	return q.Get("Use")
+}
+
+func (q *passkeyQueries) Delete() (*sqlx.Stmt, error) {
+	return q.Get("Delete")
+}
@@ -0,0 +1,352 @@
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	rpId     = "localhost"
+	rpOrigin = "http://localhost:8080"
+)
+
+var b64url = base64.RawURLEncoding
+
+// A software authenticator holding a single p-256 credential, with
+// "none" attestation.
+type softAuthenticator struct {
+	key        *ecdsa.PrivateKey
+	credId     []byte
+	userHandle []byte
+	counter    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	credId := make([]byte, 32)
+	rand.Read(credId)
+
+	return &softAuthenticator{key: key, credId: credId}
+}
+
+func (a *softAuthenticator) clientData(typ, challenge string) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    rpOrigin,
+	})
+	return data
+}
+
+func (a *softAuthenticator) authData(flags protocol.AuthenticatorFlags) []byte {
+	rpIdHash := sha256.Sum256([]byte(rpId))
+
+	data := bytes.Clone(rpIdHash[:])
+	data = append(data, byte(flags))
+	return binary.BigEndian.AppendUint32(data, a.counter)
+}
+
+// Answers to `navigator.credentials.create()`.
+func (a *softAuthenticator) create(
+	t *testing.T,
+	creation *protocol.CredentialCreation,
+) []byte {
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	pub, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	assert.NoError(t, err)
+
+	authData := a.authData(protocol.FlagUserPresent |
+		protocol.FlagUserVerified |
+		protocol.FlagAttestedCredentialData)
+	// Zeroed aaguid
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credId)))
+	authData = append(authData, a.credId...)
+	authData = append(authData, pub...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	assert.NoError(t, err)
+
+	clientData := a.clientData("webauthn.create", creation.Response.Challenge.String())
+
+	body, err := json.Marshal(map[string]any{
+		"id":    b64url.EncodeToString(a.credId),
+		"rawId": b64url.EncodeToString(a.credId),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64url.EncodeToString(clientData),
+			"attestationObject": b64url.EncodeToString(attestation),
+		},
+	})
+	assert.NoError(t, err)
+
+	return body
+}
+
+// Answers to `navigator.credentials.get()`.
+func (a *softAuthenticator) get(
+	t *testing.T,
+	assertion *protocol.CredentialAssertion,
+) []byte {
+	a.counter++
+
+	authData := a.authData(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	clientData := a.clientData("webauthn.get", assertion.Response.Challenge.String())
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(bytes.Clone(authData), clientDataHash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	assert.NoError(t, err)
+
+	body, err := json.Marshal(map[string]any{
+		"id":    b64url.EncodeToString(a.credId),
+		"rawId": b64url.EncodeToString(a.credId),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64url.EncodeToString(clientData),
+			"authenticatorData": b64url.EncodeToString(authData),
+			"signature":         b64url.EncodeToString(sig),
+			"userHandle":        b64url.EncodeToString(a.userHandle),
+		},
+	})
+	assert.NoError(t, err)
+
+	return body
+}
+
+func passkeyRepo(t *testing.T) (*repository.PasskeyRepository, dto.User) {
+	db := GetDb(t)
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          rpId,
+		RPDisplayName: "Blog",
+		RPOrigins:     []string{rpOrigin},
+	})
+	assert.NoError(t, err)
+
+	repo := repository.NewPasskeyRepository(db, wa, kvRepo(t))
+	userRepo := repository.NewUserRepository(db)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.NoError(t, userRepo.Create(context.Background(), user))
+
+	return repo, user
+}
+
+func registerPasskey(
+	t *testing.T,
+	repo *repository.PasskeyRepository,
+	user *dto.User,
+	auth *softAuthenticator,
+) dto.Passkey {
+	creation, err := repo.BeginRegistration(context.Background(), user)
+	assert.NoError(t, err)
+
+	passkey, err := repo.FinishRegistration(
+		context.Background(),
+		user,
+		"Test",
+		bytes.NewReader(auth.create(t, creation)),
+	)
+	assert.NoError(t, err)
+
+	return passkey
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	t.Parallel()
+	repo, user := passkeyRepo(t)
+	auth := newSoftAuthenticator(t)
+
+	creation, err := repo.BeginRegistration(context.Background(), &user)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		protocol.ResidentKeyRequirementRequired,
+		creation.Response.AuthenticatorSelection.ResidentKey,
+	)
+
+	body := auth.create(t, creation)
+
+	passkey, err := repo.FinishRegistration(
+		context.Background(),
+		&user,
+		"Test",
+		bytes.NewReader(body),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, passkey.UserID)
+	assert.Equal(t, "Test", passkey.Name)
+	assert.Equal(t, auth.credId, passkey.CredentialID)
+
+	t.Run("Replay", func(t *testing.T) {
+		_, err := repo.FinishRegistration(
+			context.Background(),
+			&user,
+			"Test",
+			bytes.NewReader(body),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidPasskeyChallenge)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		creation, err := repo.BeginRegistration(context.Background(), &user)
+		assert.NoError(t, err)
+		assert.Len(t, creation.Response.CredentialExcludeList, 1)
+
+		_, err = repo.FinishRegistration(
+			context.Background(),
+			&user,
+			"Test",
+			bytes.NewReader(auth.create(t, creation)),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrPasskeyAlreadyExists)
+	})
+
+	t.Run("WrongChallenge", func(t *testing.T) {
+		creation, err := repo.BeginRegistration(context.Background(), &user)
+		assert.NoError(t, err)
+		creation.Response.Challenge, err = protocol.CreateChallenge()
+		assert.NoError(t, err)
+
+		_, err = repo.FinishRegistration(
+			context.Background(),
+			&user,
+			"Test",
+			bytes.NewReader(newSoftAuthenticator(t).create(t, creation)),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidPasskey)
+	})
+
+	passkeys, err := repo.GetByUser(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, passkeys, 1)
+	assert.Equal(t, passkey.ID, passkeys[0].ID)
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	t.Parallel()
+	repo, user := passkeyRepo(t)
+	auth := newSoftAuthenticator(t)
+
+	registered := registerPasskey(t, repo, &user, auth)
+
+	assertion, err := repo.BeginLogin(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, assertion.Response.AllowedCredentials)
+
+	body := auth.get(t, assertion)
+
+	passkey, err := repo.FinishLogin(context.Background(), bytes.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, registered.ID, passkey.ID)
+	assert.Equal(t, user.ID, passkey.UserID)
+	assert.Equal(t, int64(1), passkey.SignCount)
+	assert.NotNil(t, passkey.LastUsedAt)
+
+	t.Run("Replay", func(t *testing.T) {
+		_, err := repo.FinishLogin(context.Background(), bytes.NewReader(body))
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidPasskeyChallenge)
+	})
+
+	t.Run("ClonedAuthenticator", func(t *testing.T) {
+		assertion, err := repo.BeginLogin(context.Background())
+		assert.NoError(t, err)
+
+		clone := *auth
+		clone.counter = 0
+
+		_, err = repo.FinishLogin(
+			context.Background(),
+			bytes.NewReader(clone.get(t, assertion)),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidPasskey)
+	})
+
+	t.Run("WrongUserHandle", func(t *testing.T) {
+		assertion, err := repo.BeginLogin(context.Background())
+		assert.NoError(t, err)
+
+		other := *auth
+		other.userHandle = dto.PasskeyUserHandle(dto.NewSnowflake())
+
+		_, err = repo.FinishLogin(
+			context.Background(),
+			bytes.NewReader(other.get(t, assertion)),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidPasskey)
+	})
+
+	t.Run("UnknownCredential", func(t *testing.T) {
+		assertion, err := repo.BeginLogin(context.Background())
+		assert.NoError(t, err)
+
+		unknown := newSoftAuthenticator(t)
+		unknown.userHandle = auth.userHandle
+
+		_, err = repo.FinishLogin(
+			context.Background(),
+			bytes.NewReader(unknown.get(t, assertion)),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidPasskey)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := repo.Delete(context.Background(), passkey.ID, dto.NewSnowflake())
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrPasskeyNotFound)
+
+		_, err = repo.Delete(context.Background(), passkey.ID, user.ID)
+		assert.NoError(t, err)
+
+		assertion, err := repo.BeginLogin(context.Background())
+		assert.NoError(t, err)
+
+		_, err = repo.FinishLogin(
+			context.Background(),
+			bytes.NewReader(auth.get(t, assertion)),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidPasskey)
+	})
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+)
+
+const (
+	_ = 10000 + iota
+
+	CodeTagNotFound
+)
+
+var ErrTagNotFound = errutils.NewHttpS(
+	"Tag not found",
+	http.StatusNotFound,
+	CodeTagNotFound,
+	true,
+)
+
+// Stores the tags and which articles they are set on.
+type TagRepository struct {
+	q tagQueries
+}
+
+func NewTagRepository(db *sqlx.DB) *TagRepository {
+	return &TagRepository{q: newTagQueries(db)}
+}
+
+func (r *TagRepository) GetBySlug(ctx context.Context, slug string) (dto.Tag, error) {
+	var tag dto.Tag
+
+	sttm, err := r.q.GetBySlug()
+	if err != nil {
+		return tag, err
+	}
+
+	if err = sttm.GetContext(ctx, &tag, slug); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrTagNotFound
+		} else {
+			slog.Error("TagRepository: GetBySlug: sql error", "error", err)
+		}
+	}
+	return tag, err
+}
+
+// Fetches the tags of the article, ordered by name.
+func (r *TagRepository) GetByArticle(
+	ctx context.Context,
+	articleId dto.Snowflake,
+) ([]dto.Tag, error) {
+	sttm, err := r.q.GetByArticle()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []dto.Tag{}
+
+	if err = sttm.SelectContext(ctx, &tags, articleId); err != nil {
+		slog.Error("TagRepository: GetByArticle: sql error", "error", err)
+	}
+	return tags, err
+}
+
+// Fetches the tags set on any published article, with the count of
+// them, the most used first.
+func (r *TagRepository) GetMany(ctx context.Context) ([]dto.Tag, error) {
+	now := time.Now().UnixMilli()
+
+	sttm, err := r.q.GetMany()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []dto.Tag{}
+
+	if err = sttm.SelectContext(ctx, &tags, now); err != nil {
+		slog.Error("TagRepository: GetMany: sql error", "error", err)
+	}
+	return tags, err
+}
+
+// Replaces the tags of the article, creating the ones that do not exist
+// yet. The names must have been parsed by dto.ParseTagNames.
+func (r *TagRepository) SetArticleTags(
+	ctx context.Context,
+	articleId dto.Snowflake,
+	names []string,
+) ([]dto.Tag, error) {
+	createSttm, err := r.q.Create()
+	if err != nil {
+		return nil, err
+	}
+	getSttm, err := r.q.GetBySlug()
+	if err != nil {
+		return nil, err
+	}
+	clearSttm, err := r.q.ClearArticle()
+	if err != nil {
+		return nil, err
+	}
+	addSttm, err := r.q.AddToArticle()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.q.Begin(ctx)
+	if err != nil {
+		slog.Error("TagRepository: SetArticleTags: sql error", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.StmtxContext(ctx, clearSttm).ExecContext(ctx, articleId)
+	if err != nil {
+		slog.Error("TagRepository: ClearArticle: sql error", "error", err)
+		return nil, err
+	}
+
+	tags := make([]dto.Tag, 0, len(names))
+
+	for _, name := range names {
+		tag := dto.NewTag(name)
+
+		_, err = tx.StmtxContext(ctx, createSttm).ExecContext(ctx,
+			tag.ID,
+			tag.CreatedAt,
+			tag.Name,
+			tag.Slug,
+		)
+		if err != nil {
+			slog.Error("TagRepository: Create: sql error", "error", err)
+			return nil, err
+		}
+
+		// The tag may already exist with another id and name
+		err = tx.StmtxContext(ctx, getSttm).GetContext(ctx, &tag, tag.Slug)
+		if err != nil {
+			slog.Error("TagRepository: GetBySlug: sql error", "error", err)
+			return nil, err
+		}
+
+		_, err = tx.StmtxContext(ctx, addSttm).ExecContext(ctx, articleId, tag.ID)
+		if err != nil {
+			slog.Error("TagRepository: AddToArticle: sql error", "error", err)
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.Error("TagRepository: SetArticleTags: sql error", "error", err)
+		return nil, err
+	}
+
+	slices.SortFunc(tags, func(a, b dto.Tag) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return tags, nil
+}
+
+func (r *TagRepository) Close() error {
+	return r.q.Close()
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/utils"
+)
+
+const tagCreateQuery = `INSERT INTO tags
+(id, created_at, name, slug)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (slug) DO NOTHING`
+
+const tagGetBySlugQuery = `SELECT
+id, created_at, name, slug
+FROM tags WHERE slug = $1`
+
+const tagGetByArticleQuery = `SELECT
+tags.id, tags.created_at, tags.name, tags.slug
+FROM tags
+INNER JOIN article_tags ON article_tags.tag_id = tags.id
+WHERE article_tags.article_id = $1
+ORDER BY tags.name`
+
+const tagGetManyQuery = `SELECT
+tags.id, tags.created_at, tags.name, tags.slug,
+COUNT(articles.id) article_count
+FROM tags
+INNER JOIN article_tags ON article_tags.tag_id = tags.id
+INNER JOIN articles ON article_tags.article_id = articles.id
+WHERE articles.status = 'published' AND articles.publish_at <= $1
+GROUP BY tags.id, tags.created_at, tags.name, tags.slug
+ORDER BY article_count DESC, tags.name`
+
+const tagAddToArticleQuery = `INSERT INTO article_tags
+(article_id, tag_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING`
+
+const tagClearArticleQuery = `DELETE FROM article_tags WHERE article_id = $1`
+
+type tagQueries struct {
+	*utils.Queries
+}
+
+func newTagQueries(db *sqlx.DB) tagQueries {
+	q := utils.NewQueries(db, "TagQueries")
+
+	q.Add(tagCreateQuery, "Create")
+	q.Add(tagGetBySlugQuery, "GetBySlug")
+	q.Add(tagGetByArticleQuery, "GetByArticle")
+	q.Add(tagGetManyQuery, "GetMany")
+
+	q.Add(tagAddToArticleQuery, "AddToArticle")
+	q.Add(tagClearArticleQuery, "ClearArticle")
+
+	return tagQueries{q}
+}
+
+func (q *tagQueries) Create() (*sqlx.Stmt, error) {
+	return q.Get("Create")
+}
+
+func (q *tagQueries) GetBySlug() (*sqlx.Stmt, error) {
+	return q.Get("GetBySlug")
+}
+
+func (q *tagQueries) GetByArticle() (*sqlx.Stmt, error) {
+	return q.Get("GetByArticle")
+}
+
+func (q *tagQueries) GetMany() (*sqlx.Stmt, error) {
+	return q.Get("GetMany")
+}
+
+func (q *tagQueries) AddToArticle() (*sqlx.Stmt, error) {
+	return q.Get("AddToArticle")
+}
+
+func (q *tagQueries) ClearArticle() (*sqlx.Stmt, error) {
+	return q.Get("ClearArticle")
+}
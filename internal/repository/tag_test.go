@@ -0,0 +1,125 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+)
+
+func TestTag(t *testing.T) {
+	t.Parallel()
+
+	db, err := InitDb(t)
+	assert.NoError(t, err)
+
+	users := repository.NewUserRepository(db)
+	articles := repository.NewArticleRepository(db)
+	tags := repository.NewTagRepository(db)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, 4)
+	assert.NoError(t, err)
+	assert.NoError(t, users.Create(context.Background(), user))
+
+	created := make([]dto.Article, 5)
+	for i := range created {
+		data := articleData()
+		if i == len(created)-1 {
+			data.Status = dto.ArticleStatusDraft
+		}
+
+		created[i] = dto.NewArticle(user.ID, nil, nil, nil, data)
+		assert.NoError(t, articles.Create(context.Background(), created[i]))
+
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	for _, article := range created {
+		set, err := tags.SetArticleTags(
+			context.Background(),
+			article.ID,
+			[]string{"Go", "Databases"},
+		)
+		assert.NoError(t, err)
+		assert.Len(t, set, 2)
+		assert.Equal(t, "Databases", set[0].Name)
+		assert.Equal(t, "Go", set[1].Name)
+	}
+
+	t.Run("SetArticleTags", func(t *testing.T) {
+		set, err := tags.SetArticleTags(
+			context.Background(),
+			created[0].ID,
+			[]string{"go", "Web"},
+		)
+		assert.NoError(t, err)
+
+		// Existing tags keep their names
+		assert.Equal(t, "Go", set[0].Name)
+		assert.Equal(t, "web", set[1].Slug)
+
+		got, err := tags.GetByArticle(context.Background(), created[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, set, got)
+	})
+
+	t.Run("GetBySlug", func(t *testing.T) {
+		tag, err := tags.GetBySlug(context.Background(), "databases")
+		assert.NoError(t, err)
+		assert.Equal(t, "Databases", tag.Name)
+
+		_, err = tags.GetBySlug(context.Background(), randString(16))
+		assert.ErrorIs(t, err, repository.ErrTagNotFound)
+	})
+
+	t.Run("GetMany", func(t *testing.T) {
+		got, err := tags.GetMany(context.Background())
+		assert.NoError(t, err)
+
+		counts := map[string]int{}
+		for _, tag := range got {
+			counts[tag.Slug] = tag.ArticleCount
+		}
+		// The draft is not counted
+		assert.Equal(t, map[string]int{"go": 4, "databases": 3, "web": 1}, counts)
+		assert.Equal(t, "go", got[0].Slug)
+	})
+
+	t.Run("GetManyByTag", func(t *testing.T) {
+		tag, err := tags.GetBySlug(context.Background(), "databases")
+		assert.NoError(t, err)
+
+		result := []dto.Article{}
+		for {
+			lastSeen := dto.Snowflake(0)
+			if len(result) != 0 {
+				lastSeen = result[len(result)-1].ID
+			}
+
+			page, err := articles.GetManyByTag(
+				context.Background(),
+				tag.ID,
+				dto.Pagination{Limit: 2, LastSeen: lastSeen},
+			)
+			assert.NoError(t, err)
+
+			result = append(result, page...)
+			if len(page) < 2 {
+				break
+			}
+		}
+
+		ids := []dto.Snowflake{}
+		for _, article := range result {
+			ids = append(ids, article.ID)
+		}
+		assert.Equal(t, []dto.Snowflake{
+			created[3].ID,
+			created[2].ID,
+			created[1].ID,
+		}, ids)
+	})
+}
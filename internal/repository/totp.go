@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+)
+
+const (
+	_ = 7000 + iota
+
+	CodeTOTPNotFound
+	CodeTOTPAlreadyEnabled
+	CodeTOTPInvalid
+)
+
+var (
+	ErrTOTPNotFound = errutils.NewHttpS(
+		"Two-factor authentication is not enabled",
+		http.StatusNotFound,
+		CodeTOTPNotFound,
+		true,
+	)
+	ErrTOTPAlreadyEnabled = errutils.NewHttpS(
+		"Two-factor authentication is already enabled",
+		http.StatusConflict,
+		CodeTOTPAlreadyEnabled,
+		true,
+	)
+	ErrInvalidTOTPCode = errutils.NewHttpS(
+		"Invalid two-factor authentication code",
+		http.StatusUnauthorized,
+		CodeTOTPInvalid,
+		true,
+	)
+)
+
+type TOTPRepository struct {
+	q totpQueries
+}
+
+func NewTOTPRepository(db *sqlx.DB) *TOTPRepository {
+	return &TOTPRepository{q: newTOTPQueries(db)}
+}
+
+// Fetches the totp credential of the user, which may be pending.
+func (r *TOTPRepository) Get(ctx context.Context, userId dto.Snowflake) (dto.TOTP, error) {
+	var totp dto.TOTP
+
+	sttm, err := r.q.GetQ()
+	if err != nil {
+		return totp, err
+	}
+
+	if err = sttm.GetContext(ctx, &totp, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrTOTPNotFound
+		} else {
+			slog.Error("TOTPRepository: Get: sql error", "error", err)
+		}
+	}
+	return totp, err
+}
+
+// Starts the enrollment of the user with a new secret, replacing the
+// pending one, if any. Fails with ErrTOTPAlreadyEnabled if the user
+// already has an enabled credential.
+func (r *TOTPRepository) Begin(ctx context.Context, userId dto.Snowflake) (dto.TOTP, error) {
+	data := dto.NewTOTP(userId)
+
+	var totp dto.TOTP
+
+	sttm, err := r.q.Begin()
+	if err != nil {
+		return totp, err
+	}
+
+	err = sttm.GetContext(ctx, &totp,
+		data.UserID,
+		data.CreatedAt,
+		data.Secret,
+		data.RecoveryCodes,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrTOTPAlreadyEnabled
+		} else {
+			slog.Error("TOTPRepository: Begin: sql error", "error", err)
+		}
+	}
+	return totp, err
+}
+
+// Finishes the enrollment of the user once a valid code is given,
+// returning the recovery codes, which are only stored hashed.
+func (r *TOTPRepository) Enable(
+	ctx context.Context,
+	userId dto.Snowflake,
+	code string,
+) (dto.TOTP, []string, error) {
+	totp, err := r.Get(ctx, userId)
+	if err != nil {
+		return totp, nil, err
+	}
+	if totp.Enabled() {
+		return totp, nil, ErrTOTPAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(code, time.Now())
+	if !ok {
+		return totp, nil, ErrInvalidTOTPCode
+	}
+
+	hashes, codes := dto.NewRecoveryCodes()
+	now := time.Now().UnixMilli()
+
+	sttm, err := r.q.Enable()
+	if err != nil {
+		return totp, nil, err
+	}
+
+	if err = sttm.GetContext(ctx, &totp, now, step, hashes, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Enabled by a concurrent request
+			err = ErrInvalidTOTPCode
+		} else {
+			slog.Error("TOTPRepository: Enable: sql error", "error", err)
+		}
+		return totp, nil, err
+	}
+
+	return totp, codes, nil
+}
+
+// Checks either a totp code or a recovery code of the user, making
+// sure it can not be used again.
+func (r *TOTPRepository) Verify(
+	ctx context.Context,
+	userId dto.Snowflake,
+	code string,
+) (dto.TOTP, error) {
+	totp, err := r.Get(ctx, userId)
+	if err != nil {
+		return totp, err
+	}
+	if !totp.Enabled() {
+		return totp, ErrTOTPNotFound
+	}
+
+	if dto.IsRecoveryCode(code) {
+		return r.useRecoveryCode(ctx, totp, code)
+	}
+
+	step, ok := totp.Validate(code, time.Now())
+	if !ok {
+		return totp, ErrInvalidTOTPCode
+	}
+
+	sttm, err := r.q.UseStep()
+	if err != nil {
+		return totp, err
+	}
+
+	if err = sttm.GetContext(ctx, &totp, step, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Used by a concurrent request
+			err = ErrInvalidTOTPCode
+		} else {
+			slog.Error("TOTPRepository: Verify: sql error", "error", err)
+		}
+	}
+	return totp, err
+}
+
+func (r *TOTPRepository) useRecoveryCode(
+	ctx context.Context,
+	totp dto.TOTP,
+	code string,
+) (dto.TOTP, error) {
+	left, ok := totp.UseRecoveryCode(code)
+	if !ok {
+		return totp, ErrInvalidTOTPCode
+	}
+
+	sttm, err := r.q.SetRecoveryCodes()
+	if err != nil {
+		return totp, err
+	}
+
+	err = sttm.GetContext(ctx, &totp, left, totp.UserID, totp.RecoveryCodes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Used by a concurrent request
+			err = ErrInvalidTOTPCode
+		} else {
+			slog.Error("TOTPRepository: useRecoveryCode: sql error", "error", err)
+		}
+	}
+	return totp, err
+}
+
+// Disables the two-factor authentication of the user.
+func (r *TOTPRepository) Delete(ctx context.Context, userId dto.Snowflake) (dto.TOTP, error) {
+	var totp dto.TOTP
+
+	sttm, err := r.q.Delete()
+	if err != nil {
+		return totp, err
+	}
+
+	if err = sttm.GetContext(ctx, &totp, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrTOTPNotFound
+		} else {
+			slog.Error("TOTPRepository: Delete: sql error", "error", err)
+		}
+	}
+	return totp, err
+}
+
+func (r *TOTPRepository) Close() error {
+	return r.q.Close()
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/zanz1n/blog/internal/utils"
+)
+
+const totpGetQuery = `SELECT * FROM user_totp WHERE user_id = $1`
+
+// Replaces the pending enrollment, if any, but never an enabled one
+const totpBeginQuery = `INSERT INTO user_totp VALUES ($1, $2, $3, NULL, 0, $4)
+ON CONFLICT (user_id) DO UPDATE
+SET created_at = excluded.created_at, secret = excluded.secret
+WHERE user_totp.enabled_at IS NULL RETURNING *`
+
+const totpEnableQuery = `UPDATE user_totp
+SET enabled_at = $1, last_step = $2, recovery_codes = $3
+WHERE user_id = $4 AND enabled_at IS NULL AND last_step < $2 RETURNING *`
+
+const totpUseStepQuery = `UPDATE user_totp SET last_step = $1
+WHERE user_id = $2 AND enabled_at IS NOT NULL AND last_step < $1 RETURNING *`
+
+const totpSetRecoveryCodesQuery = `UPDATE user_totp SET recovery_codes = $1
+WHERE user_id = $2 AND enabled_at IS NOT NULL AND recovery_codes = $3 RETURNING *`
+
+const totpDeleteQuery = `DELETE FROM user_totp WHERE user_id = $1 RETURNING *`
+
+type totpQueries struct {
+	*utils.Queries
+}
+
+func newTOTPQueries(db *sqlx.DB) totpQueries {
+	q := utils.NewQueries(db, "TOTPQueries")
+
+	q.Add(totpGetQuery, "Get")
+	q.Add(totpBeginQuery, "Begin")
+	q.Add(totpEnableQuery, "Enable")
+	q.Add(totpUseStepQuery, "UseStep")
+	q.Add(totpSetRecoveryCodesQuery, "SetRecoveryCodes")
+	q.Add(totpDeleteQuery, "Delete")
+
+	return totpQueries{q}
+}
+
+func (q *totpQueries) GetQ() (*sqlx.Stmt, error) {
+	return q.Get("Get")
+}
+
+func (q *totpQueries) Begin() (*sqlx.Stmt, error) {
+	return q.Get("Begin")
+}
+
+func (q *totpQueries) Enable() (*sqlx.Stmt, error) {
+	return q.Get("Enable")
+}
+
+func (q *totpQueries) UseStep() (*sqlx.Stmt, error) {
+	return q.Get("UseStep")
+}
+
+func (q *totpQueries) SetRecoveryCodes() (*sqlx.Stmt, error) {
+	return q.Get("SetRecoveryCodes")
+}
+
+func (q *totpQueries) Delete() (*sqlx.Stmt, error) {
+	return q.Get("Delete")
+}
@@ -0,0 +1,111 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func totpRepo(t *testing.T) (*repository.TOTPRepository, dto.User) {
+	db := GetDb(t)
+	repo := repository.NewTOTPRepository(db)
+	userRepo := repository.NewUserRepository(db)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.NoError(t, userRepo.Create(context.Background(), user))
+
+	return repo, user
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	code, err := totp.GenerateCode(secret, at)
+	assert.NoError(t, err)
+	return code
+}
+
+func TestTOTPEnroll(t *testing.T) {
+	t.Parallel()
+	repo, user := totpRepo(t)
+
+	t.Run("Inexistent", func(t *testing.T) {
+		_, err := repo.Get(context.Background(), user.ID)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrTOTPNotFound)
+	})
+
+	pending, err := repo.Begin(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.False(t, pending.Enabled())
+
+	t.Run("BeginAgain", func(t *testing.T) {
+		pending2, err := repo.Begin(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.NotEqual(t, pending.Secret, pending2.Secret)
+		pending = pending2
+	})
+
+	t.Run("VerifyPending", func(t *testing.T) {
+		code := totpCode(t, pending.Secret, time.Now())
+		_, err := repo.Verify(context.Background(), user.ID, code)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrTOTPNotFound)
+	})
+
+	t.Run("EnableInvalid", func(t *testing.T) {
+		_, _, err := repo.Enable(context.Background(), user.ID, "000000x")
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidTOTPCode)
+	})
+
+	code := totpCode(t, pending.Secret, time.Now())
+
+	enabled, codes, err := repo.Enable(context.Background(), user.ID, code)
+	assert.NoError(t, err)
+	assert.True(t, enabled.Enabled())
+	assert.Len(t, codes, dto.RecoveryCodeCount)
+	assert.Equal(t, dto.RecoveryCodeCount, enabled.RecoveryCodesLeft())
+
+	t.Run("BeginEnabled", func(t *testing.T) {
+		_, err := repo.Begin(context.Background(), user.ID)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrTOTPAlreadyEnabled)
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		_, err := repo.Verify(context.Background(), user.ID, code)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidTOTPCode)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		next := totpCode(t, pending.Secret, time.Now().Add(30*time.Second))
+		_, err := repo.Verify(context.Background(), user.ID, next)
+		assert.NoError(t, err)
+	})
+
+	t.Run("RecoveryCode", func(t *testing.T) {
+		totp, err := repo.Verify(context.Background(), user.ID, codes[0])
+		assert.NoError(t, err)
+		assert.Equal(t, dto.RecoveryCodeCount-1, totp.RecoveryCodesLeft())
+
+		_, err = repo.Verify(context.Background(), user.ID, codes[0])
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrInvalidTOTPCode)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := repo.Delete(context.Background(), user.ID)
+		assert.NoError(t, err)
+
+		_, err = repo.Delete(context.Background(), user.ID)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrTOTPNotFound)
+	})
+}
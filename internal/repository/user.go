@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	"log/slog"
+	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -18,6 +20,7 @@ const (
 
 	CodeUserNotFound
 	CodeUserAlreadyExists
+	CodeUserBanned
 )
 
 var (
@@ -33,8 +36,18 @@ var (
 		CodeUserAlreadyExists,
 		true,
 	)
+	ErrUserBanned = errutils.NewHttpS(
+		"This account was banned",
+		http.StatusForbidden,
+		CodeUserBanned,
+		true,
+	)
 )
 
+// Escapes the wildcards of LIKE patterns, backslash being
+// the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type UserRepository struct {
 	q userQueries
 }
@@ -58,6 +71,8 @@ func (r *UserRepository) Create(ctx context.Context, user dto.User) error {
 		user.Nickname,
 		user.Name,
 		user.Password,
+		user.EmailVerifiedAt,
+		user.BannedAt,
 	)
 	if err != nil {
 		if isUniqueConstraintViolation(err) {
@@ -105,6 +120,34 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (dto.User
 	return user, err
 }
 
+// Fetches a page of users, newest first, whose email or nickname
+// contains the search term, if not empty.
+func (r *UserRepository) GetMany(
+	ctx context.Context,
+	pag dto.Pagination,
+	search string,
+) ([]dto.User, error) {
+	if pag.LastSeen == 0 {
+		// math.MaxUint64 results int integer overflow
+		pag.LastSeen = math.MaxInt64
+	}
+
+	sttm, err := r.q.GetMany()
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(search)) + "%"
+
+	users := []dto.User{}
+	err = sttm.SelectContext(ctx, &users, pag.LastSeen, pattern, pag.Limit)
+	if err != nil {
+		slog.Error("UserRepository: GetMany: sql error", "error", err)
+		return nil, err
+	}
+	return users, nil
+}
+
 func (r *UserRepository) UpdateName(ctx context.Context, id dto.Snowflake, name string) (dto.User, error) {
 	now := time.Now().UnixMilli()
 
@@ -125,6 +168,162 @@ func (r *UserRepository) UpdateName(ctx context.Context, id dto.Snowflake, name
 	return user, err
 }
 
+func (r *UserRepository) UpdateData(
+	ctx context.Context,
+	id dto.Snowflake,
+	nickname, name string,
+) (dto.User, error) {
+	now := time.Now().UnixMilli()
+
+	var user dto.User
+
+	sttm, err := r.q.UpdateData()
+	if err != nil {
+		return user, err
+	}
+
+	if err = sttm.GetContext(ctx, &user, nickname, name, now, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrUserNotFound
+		} else {
+			slog.Error("UserRepository: UpdateData: sql error", "error", err)
+		}
+	}
+	return user, err
+}
+
+// Changes the email of the user, which needs to be verified again.
+func (r *UserRepository) UpdateEmail(
+	ctx context.Context,
+	id dto.Snowflake,
+	email string,
+) (dto.User, error) {
+	now := time.Now().UnixMilli()
+
+	var user dto.User
+
+	sttm, err := r.q.UpdateEmail()
+	if err != nil {
+		return user, err
+	}
+
+	if err = sttm.GetContext(ctx, &user, email, now, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrUserNotFound
+		} else if isUniqueConstraintViolation(err) {
+			err = ErrUserAlreadyExists
+		} else {
+			slog.Error("UserRepository: UpdateEmail: sql error", "error", err)
+		}
+	}
+	return user, err
+}
+
+// Replaces the password hash of the user.
+func (r *UserRepository) UpdatePassword(
+	ctx context.Context,
+	id dto.Snowflake,
+	password []byte,
+) (dto.User, error) {
+	now := time.Now().UnixMilli()
+
+	var user dto.User
+
+	sttm, err := r.q.UpdatePassword()
+	if err != nil {
+		return user, err
+	}
+
+	if err = sttm.GetContext(ctx, &user, password, now, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrUserNotFound
+		} else {
+			slog.Error("UserRepository: UpdatePassword: sql error", "error", err)
+		}
+	}
+	return user, err
+}
+
+// Marks the email of the user as verified, failing with ErrUserNotFound
+// if the user email is no longer the given one.
+func (r *UserRepository) SetEmailVerified(
+	ctx context.Context,
+	id dto.Snowflake,
+	email string,
+) (dto.User, error) {
+	now := time.Now().UnixMilli()
+
+	var user dto.User
+
+	sttm, err := r.q.SetEmailVerified()
+	if err != nil {
+		return user, err
+	}
+
+	if err = sttm.GetContext(ctx, &user, now, id, email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrUserNotFound
+		} else {
+			slog.Error("UserRepository: SetEmailVerified: sql error", "error", err)
+		}
+	}
+	return user, err
+}
+
+func (r *UserRepository) UpdatePermission(
+	ctx context.Context,
+	id dto.Snowflake,
+	permission dto.Permission,
+) (dto.User, error) {
+	now := time.Now().UnixMilli()
+
+	var user dto.User
+
+	sttm, err := r.q.UpdatePermission()
+	if err != nil {
+		return user, err
+	}
+
+	if err = sttm.GetContext(ctx, &user, permission, now, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrUserNotFound
+		} else {
+			slog.Error("UserRepository: UpdatePermission: sql error", "error", err)
+		}
+	}
+	return user, err
+}
+
+// Bans or unbans the user. Its sessions and tokens are not revoked.
+func (r *UserRepository) SetBanned(
+	ctx context.Context,
+	id dto.Snowflake,
+	banned bool,
+) (dto.User, error) {
+	now := time.Now().UnixMilli()
+
+	var bannedAt *int64
+	if banned {
+		bannedAt = &now
+	}
+
+	var user dto.User
+
+	sttm, err := r.q.SetBanned()
+	if err != nil {
+		return user, err
+	}
+
+	if err = sttm.GetContext(ctx, &user, bannedAt, now, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrUserNotFound
+		} else {
+			slog.Error("UserRepository: SetBanned: sql error", "error", err)
+		}
+	}
+	return user, err
+}
+
 func (r *UserRepository) DeleteById(ctx context.Context, id dto.Snowflake) (dto.User, error) {
 	var user dto.User
 
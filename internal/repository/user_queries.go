@@ -5,14 +5,38 @@ import (
 	"github.com/zanz1n/blog/internal/utils"
 )
 
-const userCreateQuery = `INSERT INTO users VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+const userCreateQuery = `INSERT INTO users VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
 
 const userGetByIdQuery = `SELECT * FROM users WHERE id = $1`
 
 const userGetByEmailQuery = `SELECT * FROM users WHERE email = $1`
 
+// Matches the email or nickname against the lowercase LIKE pattern $2.
+const userGetManyQuery = `SELECT * FROM users
+WHERE id < $1 AND (lower(email) LIKE $2 ESCAPE '\' OR lower(nickname) LIKE $2 ESCAPE '\')
+ORDER BY id DESC LIMIT $3`
+
 const userUpdateNameQuery = `UPDATE users SET name = $1, updated_at = $2 WHERE id = $3 RETURNING *`
 
+const userUpdateDataQuery = `UPDATE users SET nickname = $1, name = $2, updated_at = $3
+WHERE id = $4 RETURNING *`
+
+const userUpdateEmailQuery = `UPDATE users
+SET email = $1, email_verified_at = NULL, updated_at = $2
+WHERE id = $3 RETURNING *`
+
+const userUpdatePasswordQuery = `UPDATE users SET password = $1, updated_at = $2 WHERE id = $3 RETURNING *`
+
+const userSetEmailVerifiedQuery = `UPDATE users
+SET email_verified_at = $1, updated_at = $1
+WHERE id = $2 AND email = $3 RETURNING *`
+
+const userUpdatePermissionQuery = `UPDATE users SET permission = $1, updated_at = $2
+WHERE id = $3 RETURNING *`
+
+const userSetBannedQuery = `UPDATE users SET banned_at = $1, updated_at = $2
+WHERE id = $3 RETURNING *`
+
 const userDeleteByIdQuery = `DELETE FROM users WHERE id = $1 RETURNING *`
 
 type userQueries struct {
@@ -25,7 +49,14 @@ func newUserQueries(db *sqlx.DB) userQueries {
 	q.Add(userCreateQuery, "Create")
 	q.Add(userGetByIdQuery, "GetById")
 	q.Add(userGetByEmailQuery, "GetByEmail")
+	q.Add(userGetManyQuery, "GetMany")
 	q.Add(userUpdateNameQuery, "UpdateName")
+	q.Add(userUpdateDataQuery, "UpdateData")
+	q.Add(userUpdateEmailQuery, "UpdateEmail")
+	q.Add(userUpdatePasswordQuery, "UpdatePassword")
+	q.Add(userSetEmailVerifiedQuery, "SetEmailVerified")
+	q.Add(userUpdatePermissionQuery, "UpdatePermission")
+	q.Add(userSetBannedQuery, "SetBanned")
 	q.Add(userDeleteByIdQuery, "DeleteById")
 
 	return userQueries{q}
@@ -43,10 +74,38 @@ func (q *userQueries) GetByEmail() (*sqlx.Stmt, error) {
 	return q.Get("GetByEmail")
 }
 
+func (q *userQueries) GetMany() (*sqlx.Stmt, error) {
+	return q.Get("GetMany")
+}
+
 func (q *userQueries) UpdateName() (*sqlx.Stmt, error) {
 	return q.Get("UpdateName")
 }
 
+func (q *userQueries) UpdateData() (*sqlx.Stmt, error) {
+	return q.Get("UpdateData")
+}
+
+func (q *userQueries) UpdateEmail() (*sqlx.Stmt, error) {
+	return q.Get("UpdateEmail")
+}
+
+func (q *userQueries) UpdatePassword() (*sqlx.Stmt, error) {
+	return q.Get("UpdatePassword")
+}
+
+func (q *userQueries) SetEmailVerified() (*sqlx.Stmt, error) {
+	return q.Get("SetEmailVerified")
+}
+
+func (q *userQueries) UpdatePermission() (*sqlx.Stmt, error) {
+	return q.Get("UpdatePermission")
+}
+
+func (q *userQueries) SetBanned() (*sqlx.Stmt, error) {
+	return q.Get("SetBanned")
+}
+
 func (q *userQueries) DeleteById() (*sqlx.Stmt, error) {
 	return q.Get("DeleteById")
 }
@@ -3,6 +3,7 @@ package repository_test
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -104,6 +105,162 @@ func TestUserUpdateName(t *testing.T) {
 	})
 }
 
+func TestUserUpdateData(t *testing.T) {
+	t.Parallel()
+	repo := userRepo(t)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	t.Run("Inexistent", func(t *testing.T) {
+		t.Parallel()
+		_, err := repo.UpdateData(
+			context.Background(),
+			dto.NewSnowflake(),
+			randString(12),
+			randString(12),
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+	})
+
+	assert.True(t, t.Run("Create", func(t *testing.T) {
+		err = repo.Create(context.Background(), user)
+		assert.NoError(t, err)
+	}))
+
+	time.Sleep(5 * time.Millisecond)
+
+	t.Run("Update", func(t *testing.T) {
+		nickname, name := randString(12), randString(12)
+		user2, err := repo.UpdateData(context.Background(), user.ID, nickname, name)
+		assert.NoError(t, err)
+
+		user.Nickname = nickname
+		user.Name = name
+		assert.Greater(t, user2.UpdatedAt.UnixMilli(), user.UpdatedAt.UnixMilli())
+		user.UpdatedAt = user2.UpdatedAt
+
+		assert.Equal(t, user, user2)
+	})
+
+	t.Run("Fetch", func(t *testing.T) {
+		user2, err := repo.GetById(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, user, user2)
+	})
+}
+
+func TestUserUpdateEmail(t *testing.T) {
+	t.Parallel()
+	repo := userRepo(t)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+	other, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	assert.True(t, t.Run("Create", func(t *testing.T) {
+		err = repo.Create(context.Background(), user)
+		assert.NoError(t, err)
+		err = repo.Create(context.Background(), other)
+		assert.NoError(t, err)
+
+		user, err = repo.SetEmailVerified(context.Background(), user.ID, user.Email)
+		assert.NoError(t, err)
+		assert.True(t, user.EmailVerified())
+	}))
+
+	t.Run("Conflict", func(t *testing.T) {
+		_, err := repo.UpdateEmail(context.Background(), user.ID, other.Email)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrUserAlreadyExists)
+	})
+
+	time.Sleep(5 * time.Millisecond)
+
+	t.Run("Update", func(t *testing.T) {
+		email := userData().Email
+		user2, err := repo.UpdateEmail(context.Background(), user.ID, email)
+		assert.NoError(t, err)
+		assert.Equal(t, email, user2.Email)
+		assert.False(t, user2.EmailVerified())
+		assert.Greater(t, user2.UpdatedAt.UnixMilli(), user.UpdatedAt.UnixMilli())
+
+		user3, err := repo.GetById(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, user2, user3)
+	})
+}
+
+func TestUserUpdatePassword(t *testing.T) {
+	t.Parallel()
+	repo := userRepo(t)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	t.Run("Inexistent", func(t *testing.T) {
+		t.Parallel()
+		_, err := repo.UpdatePassword(context.Background(), dto.NewSnowflake(), nil)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+	})
+
+	assert.True(t, t.Run("Create", func(t *testing.T) {
+		err = repo.Create(context.Background(), user)
+		assert.NoError(t, err)
+	}))
+
+	time.Sleep(5 * time.Millisecond)
+
+	t.Run("Update", func(t *testing.T) {
+		passwd := randString(16)
+		hash, err := dto.HashPassword(passwd, bcrypt.MinCost)
+		assert.NoError(t, err)
+
+		user2, err := repo.UpdatePassword(context.Background(), user.ID, hash)
+		assert.NoError(t, err)
+		assert.True(t, user2.PasswordMatches(passwd))
+		assert.Greater(t, user2.UpdatedAt.UnixMilli(), user.UpdatedAt.UnixMilli())
+
+		user3, err := repo.GetById(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, user2, user3)
+	})
+}
+
+func TestUserSetEmailVerified(t *testing.T) {
+	t.Parallel()
+	repo := userRepo(t)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.False(t, user.EmailVerified())
+
+	assert.True(t, t.Run("Create", func(t *testing.T) {
+		err = repo.Create(context.Background(), user)
+		assert.NoError(t, err)
+	}))
+
+	t.Run("EmailChanged", func(t *testing.T) {
+		_, err := repo.SetEmailVerified(context.Background(), user.ID, randString(12))
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		user2, err := repo.SetEmailVerified(context.Background(), user.ID, user.Email)
+		assert.NoError(t, err)
+		assert.True(t, user2.EmailVerified())
+		assert.Equal(t, user2.UpdatedAt, *user2.EmailVerifiedAt)
+
+		user3, err := repo.GetById(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, user2, user3)
+	})
+}
+
 func TestUserDelete(t *testing.T) {
 	t.Parallel()
 	repo := userRepo(t)
@@ -136,3 +293,200 @@ func TestUserDelete(t *testing.T) {
 		assert.ErrorIs(t, err, repository.ErrUserNotFound)
 	})
 }
+
+func TestUserDeleteContent(t *testing.T) {
+	t.Parallel()
+	db := GetDb(t)
+	articles := repository.NewArticleRepository(db)
+	users := repository.NewUserRepository(db)
+	comments := repository.NewCommentRepository(db)
+
+	article, user := createArticle(t, articles, users)
+
+	comment := dto.NewComment(article.ID, user.ID, commentData())
+	assert.NoError(t, comments.Create(context.Background(), comment))
+
+	token, _ := dto.NewAccessToken(user.ID, accessTokenData(0))
+	err := repository.NewAccessTokenRepository(db).
+		Create(context.Background(), token)
+	assert.NoError(t, err)
+
+	identity := dto.NewIdentity(user.ID, identityIssuer, randString(16), user.Email)
+	err = repository.NewIdentityRepository(db).
+		Create(context.Background(), identity)
+	assert.NoError(t, err)
+
+	entry, err := dto.NewAuditEntry(
+		&user.ID,
+		dto.AuditUserPermission,
+		dto.NewSnowflake(),
+		dto.AuditPermissionData{},
+	)
+	assert.NoError(t, err)
+	err = repository.NewAuditRepository(db).Create(context.Background(), entry)
+	assert.NoError(t, err)
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := users.DeleteById(context.Background(), user.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("FetchArticle", func(t *testing.T) {
+		_, err := articles.Get(context.Background(), article.ID)
+		assert.ErrorIs(t, err, repository.ErrArticleNotFound)
+	})
+
+	t.Run("FetchComment", func(t *testing.T) {
+		_, err := comments.Get(context.Background(), comment.ID)
+		assert.ErrorIs(t, err, repository.ErrCommentNotFound)
+	})
+
+	for _, query := range []string{
+		"SELECT count(*) FROM access_tokens WHERE user_id = $1",
+		"SELECT count(*) FROM user_identities WHERE user_id = $1",
+		"SELECT count(*) FROM article_revisions WHERE user_id = $1",
+		"SELECT count(*) FROM audit_log WHERE actor_id = $1",
+	} {
+		t.Run("FetchReferences", func(t *testing.T) {
+			var count int
+			err := db.GetContext(context.Background(), &count, query, user.ID)
+			assert.NoError(t, err)
+			assert.Zero(t, count)
+		})
+	}
+}
+
+func TestUserGetMany(t *testing.T) {
+	t.Parallel()
+	repo := userRepo(t)
+
+	// Shared by the users, so they are the only ones matching it
+	term := randString(16)
+
+	users := make([]dto.User, 5)
+	for i := range users {
+		data := userData()
+		data.Nickname = fmt.Sprintf("%s_%d", term, i)
+
+		user, err := dto.NewUser(data, dto.PermissionDefault, bcrypt.MinCost)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Create(context.Background(), user))
+
+		users[len(users)-1-i] = user
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	t.Run("Search", func(t *testing.T) {
+		res, err := repo.GetMany(
+			context.Background(),
+			dto.Pagination{Limit: 100},
+			strings.ToUpper(term),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, users, res)
+	})
+
+	t.Run("Email", func(t *testing.T) {
+		res, err := repo.GetMany(
+			context.Background(),
+			dto.Pagination{Limit: 100},
+			users[2].Email,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, users[2:3], res)
+	})
+
+	t.Run("Paginate", func(t *testing.T) {
+		res, err := repo.GetMany(
+			context.Background(),
+			dto.Pagination{Limit: 2, LastSeen: users[1].ID},
+			term,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, users[2:4], res)
+	})
+
+	t.Run("Wildcards", func(t *testing.T) {
+		// Only matches the literal underscore
+		res, err := repo.GetMany(
+			context.Background(),
+			dto.Pagination{Limit: 100},
+			term+"_",
+		)
+		assert.NoError(t, err)
+		assert.Len(t, res, 5)
+
+		res, err = repo.GetMany(
+			context.Background(),
+			dto.Pagination{Limit: 100},
+			term+"%",
+		)
+		assert.NoError(t, err)
+		assert.Empty(t, res)
+	})
+}
+
+func TestUserUpdatePermission(t *testing.T) {
+	t.Parallel()
+	repo := userRepo(t)
+
+	t.Run("Inexistent", func(t *testing.T) {
+		t.Parallel()
+		_, err := repo.UpdatePermission(
+			context.Background(),
+			dto.NewSnowflake(),
+			dto.PermisisonPublisher,
+		)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+	})
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Create(context.Background(), user))
+
+	user2, err := repo.UpdatePermission(
+		context.Background(),
+		user.ID,
+		dto.PermisisonPublisher|dto.PermissionAdmin,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, dto.PermisisonPublisher|dto.PermissionAdmin, user2.Permission)
+
+	user3, err := repo.GetById(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user2, user3)
+}
+
+func TestUserSetBanned(t *testing.T) {
+	t.Parallel()
+	repo := userRepo(t)
+
+	user, err := dto.NewUser(userData(), dto.PermissionDefault, bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Create(context.Background(), user))
+	assert.False(t, user.Banned())
+
+	t.Run("Ban", func(t *testing.T) {
+		user2, err := repo.SetBanned(context.Background(), user.ID, true)
+		assert.NoError(t, err)
+		assert.True(t, user2.Banned())
+		assert.Equal(t, user2.UpdatedAt, *user2.BannedAt)
+
+		user3, err := repo.GetById(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, user2, user3)
+	})
+
+	t.Run("Unban", func(t *testing.T) {
+		user2, err := repo.SetBanned(context.Background(), user.ID, false)
+		assert.NoError(t, err)
+		assert.False(t, user2.Banned())
+	})
+
+	t.Run("Inexistent", func(t *testing.T) {
+		_, err := repo.SetBanned(context.Background(), dto.NewSnowflake(), true)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+	})
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+var ErrAdminSelf = errutils.NewHttpS(
+	"You can't do that to your own account",
+	http.StatusBadRequest,
+	http.StatusBadRequest,
+	true,
+)
+
+type AdminUserSearchQuery struct {
+	// Part of the email or nickname of the users
+	Search string `schema:"q" validate:"max=128"`
+}
+
+type PermissionUpdateRequest struct {
+	// Permission bits granted to the user, or-ed together
+	Permissions []dto.Permission `json:"permissions"`
+}
+
+func (r *PermissionUpdateRequest) Permission() dto.Permission {
+	var perm dto.Permission
+	for _, p := range r.Permissions {
+		perm |= p
+	}
+	return perm
+}
+
+func (s *Server) wireAdmin(r chi.Router) {
+	r.Get("/admin/users", s.m(s.GetAdminUsers))
+	r.Put(
+		"/admin/users/{userId}/permission",
+		s.pm(s.PutAdminUserPermission, templates.FormError),
+	)
+	r.Put("/admin/users/{userId}/ban", s.pm(s.PutAdminUserBan, templates.FormError))
+	r.Delete("/admin/users/{userId}/ban", s.pm(s.DeleteAdminUserBan, templates.FormError))
+	r.Delete("/admin/users/{userId}", s.pm(s.DeleteAdminUser, templates.FormError))
+
+	r.Get("/admin/audit", s.m(s.GetAdminAudit))
+}
+
+func (s *Server) GetAdminUsers(c *xhttp.Ctx) error {
+	token, err := s.authorize(c, dto.PermissionAdmin)
+	if err != nil {
+		return err
+	}
+
+	var pag dto.Pagination
+	if err = c.ParseQuery(&pag); err != nil {
+		return err
+	}
+
+	var query AdminUserSearchQuery
+	if err = c.ParseQuery(&query); err != nil {
+		return err
+	}
+
+	users, err := s.users.GetMany(c.Context(), pag, query.Search)
+	if err != nil {
+		return err
+	}
+
+	data := templates.AdminUserListData{
+		Users:  templates.NewAdminUsers(users),
+		Search: query.Search,
+	}
+	if len(users) == pag.Limit {
+		data.Next = users[len(users)-1].ID
+	}
+
+	page := templates.PageData[templates.AdminUserListData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	return xhttp.Component(c, templates.AdminUsersPage, page, http.StatusOK)
+}
+
+// Replaces the permission of the user, which is applied to its
+// tokens once they are refreshed.
+func (s *Server) PutAdminUserPermission(c *xhttp.Ctx) error {
+	token, target, err := s.adminTarget(c)
+	if err != nil {
+		return err
+	}
+
+	var data PermissionUpdateRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	err = s.audit(c, token, dto.AuditUserPermission, target.ID, dto.AuditPermissionData{
+		Old: target.Permission,
+		New: data.Permission(),
+	})
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.UpdatePermission(c.Context(), target.ID, data.Permission())
+	if err != nil {
+		return err
+	}
+
+	if err = s.auth.RevokeUserTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	return adminUserResponse(c, &user)
+}
+
+// Bans the user, logging out all of its sessions.
+func (s *Server) PutAdminUserBan(c *xhttp.Ctx) error {
+	token, target, err := s.adminTarget(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.audit(c, token, dto.AuditUserBan, target.ID, auditUserData(&target))
+	if err != nil {
+		return err
+	}
+
+	// Banned first, so that no session can be created once they are deleted
+	user, err := s.users.SetBanned(c.Context(), target.ID, true)
+	if err != nil {
+		return err
+	}
+
+	if err = s.auth.DeleteRefreshTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+	if err = s.auth.RevokeUserTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	return adminUserResponse(c, &user)
+}
+
+func (s *Server) DeleteAdminUserBan(c *xhttp.Ctx) error {
+	token, target, err := s.adminTarget(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.audit(c, token, dto.AuditUserUnban, target.ID, auditUserData(&target))
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.SetBanned(c.Context(), target.ID, false)
+	if err != nil {
+		return err
+	}
+
+	return adminUserResponse(c, &user)
+}
+
+// Deletes the user together with everything it created.
+func (s *Server) DeleteAdminUser(c *xhttp.Ctx) error {
+	token, target, err := s.adminTarget(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.audit(c, token, dto.AuditUserDelete, target.ID, auditUserData(&target))
+	if err != nil {
+		return err
+	}
+
+	// Logged out first, as nothing can be retried once it is deleted
+	if err = s.auth.DeleteRefreshTokens(c.Context(), target.ID); err != nil {
+		return err
+	}
+	if err = s.auth.RevokeUserTokens(c.Context(), target.ID); err != nil {
+		return err
+	}
+
+	user, err := s.users.DeleteById(c.Context(), target.ID)
+	if err != nil {
+		return err
+	}
+
+	return adminUserResponse(c, &user)
+}
+
+func (s *Server) GetAdminAudit(c *xhttp.Ctx) error {
+	token, err := s.authorize(c, dto.PermissionAdmin)
+	if err != nil {
+		return err
+	}
+
+	var pag dto.Pagination
+	if err = c.ParseQuery(&pag); err != nil {
+		return err
+	}
+
+	entries, err := s.audits.GetMany(c.Context(), pag)
+	if err != nil {
+		return err
+	}
+
+	data := templates.AuditLogData{Entries: entries}
+	if len(entries) == pag.Limit {
+		data.Next = entries[len(entries)-1].ID
+	}
+
+	page := templates.PageData[templates.AuditLogData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	return xhttp.Component(c, templates.AuditLogPage, page, http.StatusOK)
+}
+
+// Authorizes the administrator and fetches the user it is acting on,
+// which can not be itself, so that it can not lock itself out.
+func (s *Server) adminTarget(c *xhttp.Ctx) (*dto.AuthToken, dto.User, error) {
+	token, err := s.authorize(c, dto.PermissionAdmin)
+	if err != nil {
+		return nil, dto.User{}, err
+	}
+
+	id, err := snowflakeParam(c, "userId")
+	if err != nil {
+		return nil, dto.User{}, err
+	}
+
+	if id == token.ID {
+		return nil, dto.User{}, ErrAdminSelf
+	}
+
+	user, err := s.users.GetById(c.Context(), id)
+	if err != nil {
+		return nil, dto.User{}, err
+	}
+
+	return token, user, nil
+}
+
+// Records the change made by the administrator. Called before the
+// change is applied, so that none goes unaudited if a later step fails.
+func (s *Server) audit(
+	c *xhttp.Ctx,
+	token *dto.AuthToken,
+	action dto.AuditAction,
+	targetId dto.Snowflake,
+	data any,
+) error {
+	entry, err := dto.NewAuditEntry(&token.ID, action, targetId, data)
+	if err != nil {
+		return err
+	}
+
+	return s.audits.Create(c.Context(), entry)
+}
+
+func auditUserData(user *dto.User) dto.AuditUserData {
+	return dto.AuditUserData{Email: user.Email, Nickname: user.Nickname}
+}
+
+// Responds with the user to json clients, redirecting the other
+// ones to the user list.
+func adminUserResponse(c *xhttp.Ctx, user *dto.User) error {
+	if !c.IsHtmx() && c.AcceptsJSON() {
+		res := templates.AdminUser{User: *user, Permission: user.Permission}
+		return xhttp.JSON(c, res, http.StatusOK)
+	}
+
+	c.Redirect("/admin/users")
+	return nil
+}
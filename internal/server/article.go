@@ -0,0 +1,683 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/markdown"
+	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/slug"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+// Max size of a markdown article source.
+const maxArticleSize = 2 << 20
+
+// Content types accepted as raw markdown request bodies.
+var markdownTypes = []string{"text/markdown", "text/x-markdown"}
+
+// Slugs that would be shadowed by the other article routes.
+var reservedSlugs = []string{"new", "mine"}
+
+// Layout of the datetime-local form inputs, taken as UTC.
+const publishAtLayout = "2006-01-02T15:04"
+
+var (
+	ErrArticleTooLarge = errutils.NewHttpS(
+		"Article content is too large",
+		http.StatusRequestEntityTooLarge,
+		http.StatusRequestEntityTooLarge,
+		true,
+	)
+	ErrTooManyTags = errutils.NewHttpS(
+		fmt.Sprintf("Articles can not have more than %d tags", dto.MaxArticleTags),
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+		true,
+	)
+	ErrInvalidPublishAt = errutils.NewHttpS(
+		"Scheduled articles need a publish time in the future",
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+		true,
+	)
+)
+
+type ArticleCreateRequest struct {
+	dto.ArticleCreateData
+	ArticleContentRequest
+	// Comma separated tag names, merged with the ones declared
+	// in the front matter
+	Tags []string `json:"tags" schema:"tags"`
+}
+
+type ArticleUpdateRequest struct {
+	Title       string `json:"title" schema:"title" validate:"required"`
+	Description string `json:"description" schema:"description"`
+	// Comma separated tag names, the tags are kept if nil
+	Tags []string `json:"tags" schema:"tags"`
+}
+
+type ArticleStatusRequest struct {
+	Status dto.ArticleStatus `json:"status" schema:"status" validate:"required,oneof=draft scheduled published archived"`
+	// Required if scheduling, either in RFC 3339 or in the
+	// datetime-local input format
+	PublishAt string `json:"publish_at" schema:"publish_at"`
+}
+
+type ArticleStatusQuery struct {
+	Status dto.ArticleStatus `schema:"status" validate:"omitempty,oneof=draft scheduled published archived"`
+}
+
+// The markdown source can also be sent as the `file` multipart
+// file or as a raw text/markdown body.
+type ArticleContentRequest struct {
+	Content string `json:"content" schema:"content"`
+}
+
+func (s *Server) wireArticles(r chi.Router) {
+	r.Get("/", s.m(s.GetArticles))
+
+	r.Get("/articles/new", s.m(s.GetArticleNew))
+	r.With(s.rateLimit(s.limits.Articles, keyByUser)).Post("/articles", s.cfm(
+		s.PostArticle,
+		templates.ArticleNewPage,
+		templates.ArticleNewForm,
+	))
+
+	r.Get("/articles/mine", s.m(s.GetOwnArticles))
+	r.Get("/articles/{slug}", s.m(s.GetArticle))
+	r.Get("/articles/{id}/edit", s.m(s.GetArticleEdit))
+	r.Patch("/articles/{id}", s.pm(s.PatchArticle, templates.FormError))
+	r.Put("/articles/{id}/content", s.pm(s.PutArticleContent, templates.FormError))
+	r.Put("/articles/{id}/status", s.pm(s.PutArticleStatus, templates.FormError))
+	r.Delete("/articles/{id}", s.pm(s.DeleteArticle, templates.FormError))
+}
+
+func (s *Server) GetArticles(c *xhttp.Ctx) error {
+	var pag dto.Pagination
+	if err := c.ParseQuery(&pag); err != nil {
+		return err
+	}
+
+	articles, err := s.articles.GetMany(c.Context(), pag)
+	if err != nil {
+		return err
+	}
+
+	data := templates.ArticleListData{Articles: articles}
+	if len(articles) == pag.Limit {
+		data.Next = articles[len(articles)-1].ID
+	}
+
+	token, _ := c.GetAuth()
+	page := templates.PageData[templates.ArticleListData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	return xhttp.Component(c, templates.ArticlesPage, page, http.StatusOK)
+}
+
+// Renders the article addressed by the `slug` url parameter, redirecting
+// previous slugs and ids to the current one.
+func (s *Server) GetArticle(c *xhttp.Ctx) error {
+	slug := c.URLParam("slug")
+
+	article, err := s.articles.GetBySlug(c.Context(), slug)
+	if errors.Is(err, repository.ErrArticleNotFound) {
+		return s.redirectArticle(c, slug)
+	} else if err != nil {
+		return err
+	}
+
+	token := optionalAuth(c)
+	if !canReadArticle(token, article) {
+		return repository.ErrArticleNotFound
+	}
+
+	if article.Tags, err = s.tags.GetByArticle(c.Context(), article.ID); err != nil {
+		return err
+	}
+
+	data := templates.PageData[dto.Article]{
+		Name:  "Blog",
+		Token: token,
+		Data:  article,
+	}
+
+	return xhttp.Component(c, templates.ArticlePage, data, http.StatusOK)
+}
+
+func (s *Server) redirectArticle(c *xhttp.Ctx, slug string) error {
+	article, err := s.articles.GetByOldSlug(c.Context(), slug)
+	if errors.Is(err, repository.ErrArticleNotFound) {
+		var id dto.Snowflake
+		if id.UnmarshalText([]byte(slug)) != nil {
+			return err
+		}
+		article, err = s.articles.Get(c.Context(), id)
+	}
+	if err != nil {
+		return err
+	}
+
+	token := optionalAuth(c)
+	if !canReadArticle(token, article) {
+		return repository.ErrArticleNotFound
+	}
+
+	c.RedirectPermanent(articlePermalink(article))
+	return nil
+}
+
+// Lists the articles of the authenticated user with the given status,
+// including their drafts.
+func (s *Server) GetOwnArticles(c *xhttp.Ctx) error {
+	token, err := s.authorize(c, dto.PermissionWritePosts)
+	if err != nil {
+		return err
+	}
+
+	var query ArticleStatusQuery
+	if err = c.ParseQuery(&query); err != nil {
+		return err
+	}
+	if query.Status == "" {
+		query.Status = dto.ArticleStatusDraft
+	}
+
+	var pag dto.Pagination
+	if err = c.ParseQuery(&pag); err != nil {
+		return err
+	}
+
+	articles, err := s.articles.GetManyByUser(
+		c.Context(),
+		token.ID,
+		query.Status,
+		pag,
+	)
+	if err != nil {
+		return err
+	}
+
+	data := templates.OwnArticleListData{
+		Status: query.Status,
+		ArticleListData: templates.ArticleListData{
+			Articles: articles,
+		},
+	}
+	if len(articles) == pag.Limit {
+		data.Next = articles[len(articles)-1].ID
+	}
+
+	page := templates.PageData[templates.OwnArticleListData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	return xhttp.Component(c, templates.OwnArticlesPage, page, http.StatusOK)
+}
+
+func (s *Server) GetArticleNew(c *xhttp.Ctx) error {
+	token, err := s.authorize(c, dto.PermissionWritePosts)
+	if err != nil {
+		return err
+	}
+
+	data := templates.PageData[error]{
+		Name:  "Blog",
+		Token: token,
+		Data:  nil,
+	}
+
+	return xhttp.Component(c, templates.ArticleNewPage, data, http.StatusOK)
+}
+
+func (s *Server) GetArticleEdit(c *xhttp.Ctx) error {
+	article, token, err := s.ownedArticle(c)
+	if err != nil {
+		return err
+	}
+
+	var query struct {
+		Warnings int `schema:"warnings"`
+	}
+	if err = c.ParseQuery(&query); err != nil {
+		return err
+	}
+
+	article, err = s.articles.GetWithRawContent(c.Context(), article.ID)
+	if err != nil {
+		return err
+	}
+
+	if article.Tags, err = s.tags.GetByArticle(c.Context(), article.ID); err != nil {
+		return err
+	}
+
+	data := templates.PageData[templates.ArticleEditData]{
+		Name:  "Blog",
+		Token: token,
+		Data: templates.ArticleEditData{
+			Article:  article,
+			Warnings: query.Warnings,
+		},
+	}
+
+	return xhttp.Component(c, templates.ArticleEditPage, data, http.StatusOK)
+}
+
+func (s *Server) PostArticle(c *xhttp.Ctx) error {
+	token, err := s.authorize(c, dto.PermissionWritePosts)
+	if err != nil {
+		return err
+	}
+
+	var data ArticleCreateRequest
+	md, err := parseArticleUpload(c, &data, &data.Content)
+	if err != nil {
+		return err
+	}
+
+	tags, err := parseArticleTags(append(data.Tags, md.Tags...))
+	if err != nil {
+		return err
+	}
+
+	article := dto.NewArticle(
+		token.ID,
+		md.Indexing,
+		md.Content,
+		md.RawContent,
+		data.ArticleCreateData,
+	)
+
+	article.Slug, err = s.articleSlug(c, article.Title, article.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.articles.Create(c.Context(), article); err != nil {
+		return err
+	}
+
+	if len(tags) != 0 {
+		_, err = s.tags.SetArticleTags(c.Context(), article.ID, tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.articleUploadResponse(c, article, md.Warnings, http.StatusCreated)
+}
+
+func (s *Server) PatchArticle(c *xhttp.Ctx) error {
+	article, _, err := s.ownedArticle(c)
+	if err != nil {
+		return err
+	}
+
+	var data ArticleUpdateRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	var tags []string
+	if data.Tags != nil {
+		if tags, err = parseArticleTags(data.Tags); err != nil {
+			return err
+		}
+	}
+
+	// The slug only follows title changes
+	newSlug := article.Slug
+	if data.Title != article.Title {
+		newSlug, err = s.articleSlug(c, data.Title, article.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	article, err = s.articles.UpdateData(
+		c.Context(),
+		article.ID,
+		data.Title,
+		data.Description,
+		newSlug,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tags != nil {
+		_, err = s.tags.SetArticleTags(c.Context(), article.ID, tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.articleResponse(c, article, http.StatusOK)
+}
+
+func (s *Server) PutArticleContent(c *xhttp.Ctx) error {
+	article, token, err := s.ownedArticle(c)
+	if err != nil {
+		return err
+	}
+
+	var data ArticleContentRequest
+	md, err := parseArticleUpload(c, &data, &data.Content)
+	if err != nil {
+		return err
+	}
+
+	article, err = s.updateArticleContent(c, article.ID, token.ID, md)
+	if err != nil {
+		return err
+	}
+
+	return s.articleUploadResponse(c, article, md.Warnings, http.StatusOK)
+}
+
+func (s *Server) PutArticleStatus(c *xhttp.Ctx) error {
+	article, _, err := s.ownedArticle(c)
+	if err != nil {
+		return err
+	}
+
+	var data ArticleStatusRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	now := dto.Timestamp{Time: time.Now().Truncate(time.Millisecond)}
+
+	status := data.Status
+	publishAt := article.PublishAt
+
+	switch status {
+	case dto.ArticleStatusDraft:
+		publishAt = nil
+	case dto.ArticleStatusPublished:
+		// Republishing keeps the article in its original place
+		if publishAt == nil || publishAt.After(now.Time) {
+			publishAt = &now
+		}
+	case dto.ArticleStatusScheduled:
+		at, err := parsePublishAt(data.PublishAt)
+		if err != nil || !at.After(now.Time) {
+			return ErrInvalidPublishAt
+		}
+		status, publishAt = dto.ArticleStatusPublished, &at
+	}
+
+	article, err = s.articles.UpdateStatus(
+		c.Context(),
+		article.ID,
+		status,
+		publishAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.articleResponse(c, article, http.StatusOK)
+}
+
+func (s *Server) DeleteArticle(c *xhttp.Ctx) error {
+	article, token, err := s.ownedArticle(c)
+	if err != nil {
+		return err
+	}
+
+	article, err = s.articles.Delete(c.Context(), article.ID)
+	if err != nil {
+		return err
+	}
+
+	if c.IsHtmx() {
+		c.Redirect("/")
+		return nil
+	}
+
+	data := templates.PageData[dto.Article]{
+		Name:  "Blog",
+		Token: token,
+		Data:  article,
+	}
+
+	return xhttp.Component(c, templates.ArticlePage, data, http.StatusOK)
+}
+
+func articleUrl(id dto.Snowflake) string {
+	return fmt.Sprintf("/articles/%s", id)
+}
+
+func articlePermalink(article dto.Article) string {
+	return "/articles/" + article.Slug
+}
+
+// Fetches the article referenced by the `id` url parameter, failing if
+// the authenticated user can not write posts or is not its author.
+func (s *Server) ownedArticle(c *xhttp.Ctx) (dto.Article, *dto.AuthToken, error) {
+	token, err := s.authorize(c, dto.PermissionWritePosts)
+	if err != nil {
+		return dto.Article{}, nil, err
+	}
+
+	id, err := snowflakeParam(c, "id")
+	if err != nil {
+		return dto.Article{}, nil, err
+	}
+
+	article, err := s.articles.Get(c.Context(), id)
+	if err != nil {
+		return dto.Article{}, nil, err
+	}
+
+	if article.UserID != token.ID {
+		return dto.Article{}, nil, ErrForbidden
+	}
+
+	return article, token, nil
+}
+
+// Updates the content of the article, along with its tags if
+// declared in the front matter.
+func (s *Server) updateArticleContent(
+	c *xhttp.Ctx,
+	id dto.Snowflake,
+	userId dto.Snowflake,
+	md markdown.Article,
+) (dto.Article, error) {
+	var tags []string
+	if md.Tags != nil {
+		var err error
+		if tags, err = parseArticleTags(md.Tags); err != nil {
+			return dto.Article{}, err
+		}
+	}
+
+	article, err := s.articles.UpdateContent(
+		c.Context(),
+		id,
+		userId,
+		md.Indexing,
+		md.Content,
+		md.RawContent,
+	)
+	if err != nil {
+		return article, err
+	}
+	article.RawContent = md.RawContent
+
+	if tags != nil {
+		_, err = s.tags.SetArticleTags(c.Context(), id, tags)
+	}
+	return article, err
+}
+
+func parseArticleTags(names []string) ([]string, error) {
+	tags := dto.ParseTagNames(names)
+	if len(tags) > dto.MaxArticleTags {
+		return nil, ErrTooManyTags
+	}
+	return tags, nil
+}
+
+// Generates an unique slug for the article from its title.
+func (s *Server) articleSlug(
+	c *xhttp.Ctx,
+	title string,
+	id dto.Snowflake,
+) (string, error) {
+	base := slug.Make(title)
+	if slices.Contains(reservedSlugs, base) {
+		base += "-article"
+	}
+	return s.articles.UniqueSlug(c.Context(), base, id)
+}
+
+// Reports whether the article can be read by the token, as unpublished
+// articles are only visible to their authors and editors.
+func canReadArticle(token *dto.AuthToken, article dto.Article) bool {
+	if article.Published() {
+		return true
+	}
+	return token != nil && (token.ID == article.UserID ||
+		token.Permission.Has(dto.PermissionReadDrafts))
+}
+
+// Fetches the article with the given id, failing as if it did not exist
+// if it can not be read by the authenticated user.
+func (s *Server) readableArticle(c *xhttp.Ctx, id dto.Snowflake) (dto.Article, error) {
+	article, err := s.articles.Get(c.Context(), id)
+	if err != nil {
+		return dto.Article{}, err
+	}
+
+	token := optionalAuth(c)
+	if !canReadArticle(token, article) {
+		return dto.Article{}, repository.ErrArticleNotFound
+	}
+
+	return article, nil
+}
+
+func parsePublishAt(s string) (dto.Timestamp, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse(publishAtLayout, s)
+	}
+	return dto.Timestamp{Time: t.Truncate(time.Millisecond)}, err
+}
+
+// Parses an article upload request into data and runs its markdown source
+// through the markdown pipeline.
+//
+// Raw markdown bodies take data from the url query, while the other ones
+// take it from the body, with the source being either the content field
+// or the `file` multipart file.
+func parseArticleUpload(
+	c *xhttp.Ctx,
+	data any,
+	content *string,
+) (markdown.Article, error) {
+	var src io.ReadCloser
+
+	if slices.Contains(markdownTypes, c.ContentType()) {
+		if err := c.ParseQuery(data); err != nil {
+			return markdown.Article{}, err
+		}
+		src = c.Request.Body
+	} else {
+		if err := c.Parse(data); err != nil {
+			return markdown.Article{}, err
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			return markdown.Article{}, err
+		}
+
+		if file != nil {
+			src = file
+		} else {
+			src = io.NopCloser(strings.NewReader(*content))
+		}
+	}
+
+	src = http.MaxBytesReader(c, src, maxArticleSize)
+	defer src.Close()
+
+	md, err := markdown.ParseArticle(src)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			err = ErrArticleTooLarge
+		}
+	}
+
+	return md, err
+}
+
+func (s *Server) articleUploadResponse(
+	c *xhttp.Ctx,
+	article dto.Article,
+	warnings int,
+	code int,
+) error {
+	if c.IsHtmx() {
+		if warnings == 0 {
+			c.Redirect(articlePermalink(article))
+		} else {
+			c.Redirect(fmt.Sprintf(
+				"%s/edit?warnings=%d",
+				articleUrl(article.ID),
+				warnings,
+			))
+		}
+		return nil
+	}
+
+	token, _ := c.GetAuth()
+	data := templates.PageData[templates.ArticleEditData]{
+		Name:  "Blog",
+		Token: token,
+		Data: templates.ArticleEditData{
+			Article:  article,
+			Warnings: warnings,
+		},
+	}
+
+	return xhttp.Component(c, templates.ArticleEditPage, data, code)
+}
+
+func (s *Server) articleResponse(c *xhttp.Ctx, article dto.Article, code int) error {
+	if c.IsHtmx() {
+		c.Redirect(articlePermalink(article))
+		return nil
+	}
+
+	token, _ := c.GetAuth()
+	data := templates.PageData[dto.Article]{
+		Name:  "Blog",
+		Token: token,
+		Data:  article,
+	}
+
+	return xhttp.Component(c, templates.ArticlePage, data, code)
+}
@@ -2,7 +2,10 @@ package server
 
 import (
 	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/zanz1n/blog/internal/dto"
@@ -19,6 +22,29 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required,max=256"`
 }
 
+// Either the email and password, the refresh token or the pending login
+// and its code must be provided, depending on the grant type.
+type TokenRequest struct {
+	GrantType      string `json:"grant_type" validate:"required,oneof=password refresh_token two_factor"`
+	Email          string `json:"email" validate:"required_if=GrantType password,omitempty,email,max=128"`
+	Password       string `json:"password" validate:"required_if=GrantType password,max=256"`
+	RefreshToken   string `json:"refresh_token" validate:"required_if=GrantType refresh_token"`
+	TwoFactorToken string `json:"two_factor_token" validate:"required_if=GrantType two_factor"`
+	Code           string `json:"code" validate:"required_if=GrantType two_factor,max=16"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	// Empty if the refresh token was rotated by a concurrent
+	// request, in which case the newest one should be kept
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	// In seconds
+	ExpiresIn int64 `json:"expires_in"`
+	// Only set when two-factor authentication was set up on login
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
 var (
 	ErrUnauthorized = errutils.NewHttpS(
 		"User not found or password doesn't match",
@@ -44,6 +70,27 @@ func (s *Server) wireAuth(r chi.Router) {
 	))
 
 	r.Get("/auth/logout", s.m(s.GetAuthLogout))
+
+	r.Get("/auth/verify", s.m(s.GetAuthVerify))
+	r.With(s.rateLimit(s.limits.Emails, keyByUser)).Post(
+		"/auth/verify",
+		s.pm(s.PostAuthVerify, templates.FormError),
+	)
+
+	r.Get("/auth/forgot", s.m(s.GetAuthForgot))
+	r.With(s.rateLimit(s.limits.Emails, keyByIP)).Post(
+		"/auth/forgot",
+		s.cfm(
+			s.PostAuthForgot,
+			templates.ForgotPasswordPage,
+			templates.ForgotPasswordForm,
+		),
+	)
+
+	r.Get("/auth/reset", s.m(s.GetAuthReset))
+	r.Post("/auth/reset", s.pm(s.PostAuthReset, templates.FormError))
+
+	r.Post("/auth/token", s.m(s.PostAuthToken))
 }
 
 func (s *Server) GetAuthSignup(c *xhttp.Ctx) error {
@@ -58,12 +105,16 @@ func (s *Server) GetAuthSignup(c *xhttp.Ctx) error {
 }
 
 func (s *Server) PostAuthSignup(c *xhttp.Ctx) error {
-	var data SignUpRequest
-	err := c.Parse(&data)
+	err := s.limits.SignupIP.Hit(c.Context(), c.ClientIP())
 	if err != nil {
 		return err
 	}
 
+	var data SignUpRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
 	user, err := dto.NewUser(data, dto.PermissionDefault, s.cfg.BcryptCost)
 	if err != nil {
 		return err
@@ -73,31 +124,21 @@ func (s *Server) PostAuthSignup(c *xhttp.Ctx) error {
 		return err
 	}
 
-	refreshToken, err := s.auth.GenRefreshToken(c.Context(), user.ID)
-	if err != nil {
-		return err
+	// The user can ask for it to be sent again
+	if err = s.sendVerifyEmail(c.Context(), &user); err != nil {
+		slog.Error(
+			"Server: PostAuthSignup: failed to send verification email",
+			"user_id", user.ID,
+			"error", err,
+		)
 	}
 
-	token := dto.NewAuthToken(&user, "", s.cfg.JWT.GetDuration())
-	authToken, err := s.auth.EncodeToken(token)
+	res, err := s.startSession(c, &user)
 	if err != nil {
 		return err
 	}
 
-	c.SetCookie(&http.Cookie{
-		Name:  "refresh_token",
-		Value: refreshToken,
-		Path:  "/",
-	})
-	c.SetCookie(&http.Cookie{
-		Name:    "auth_token",
-		Value:   authToken,
-		Path:    "/",
-		Expires: token.ExpiresAt.Time,
-	})
-
-	c.Redirect("/")
-	return nil
+	return authResponse(c, res)
 }
 
 func (s *Server) GetAuthLogin(c *xhttp.Ctx) error {
@@ -118,48 +159,219 @@ func (s *Server) PostAuthLogin(c *xhttp.Ctx) error {
 		return err
 	}
 
-	user, err := s.users.GetByEmail(c.Context(), data.Email)
+	user, err := s.checkPassword(c, data.Email, data.Password)
+	if err != nil {
+		return err
+	}
+
+	pending, ok, err := s.startTwoFactor(c, &user)
+	if err != nil {
+		return err
+	} else if ok {
+		return twoFactorResponse(c, pending)
+	}
+
+	res, err := s.startSession(c, &user)
 	if err != nil {
-		if errors.Is(err, repository.ErrUserNotFound) {
-			err = ErrUnauthorized
+		return err
+	}
+
+	return authResponse(c, res)
+}
+
+func (s *Server) GetAuthLogout(c *xhttp.Ctx) error {
+	if token, err := c.GetAuth(); err == nil && token != nil {
+		if err = s.auth.RevokeToken(c.Context(), *token); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken := c.GetCookie("refresh_token"); refreshToken != nil {
+		err := s.auth.DeleteRefreshToken(c.Context(), refreshToken.Value)
+		if err != nil && !errors.Is(err, repository.ErrInvalidRefreshToken) {
+			return err
 		}
+	}
+
+	c.DelCookie("refresh_token")
+	c.DelCookie("auth_token")
+
+	c.Redirect("/")
+	return nil
+}
+
+func (s *Server) PostAuthToken(c *xhttp.Ctx) error {
+	var data TokenRequest
+	if err := c.Parse(&data); err != nil {
 		return err
 	}
 
-	if !user.PasswordMatches(data.Password) {
-		return ErrUnauthorized
+	if data.GrantType == "refresh_token" {
+		refreshToken, session, err := s.auth.RotateRefreshToken(
+			c.Context(),
+			data.RefreshToken,
+			c.SessionMetadata(),
+		)
+		if err != nil {
+			return err
+		}
+
+		user, err := s.users.GetById(c.Context(), session.UserID)
+		if err != nil {
+			return err
+		}
+
+		res, err := s.issueToken(&user, session.ID, refreshToken)
+		if err != nil {
+			return err
+		}
+
+		return xhttp.JSON(c, res, http.StatusOK)
+	}
+
+	if data.GrantType == "two_factor" {
+		user, codes, err := s.completeTwoFactor(c, data.TwoFactorToken, data.Code)
+		if err != nil {
+			return err
+		}
+
+		res, err := s.startSession(c, &user)
+		if err != nil {
+			return err
+		}
+		res.RecoveryCodes = codes
+
+		return xhttp.JSON(c, res, http.StatusOK)
 	}
 
-	refreshToken, err := s.auth.GenRefreshToken(c.Context(), user.ID)
+	user, err := s.checkPassword(c, data.Email, data.Password)
 	if err != nil {
 		return err
 	}
 
-	token := dto.NewAuthToken(&user, "", s.cfg.JWT.GetDuration())
-	authToken, err := s.auth.EncodeToken(token)
+	pending, ok, err := s.startTwoFactor(c, &user)
 	if err != nil {
 		return err
+	} else if ok {
+		return xhttp.JSON(c, pending, http.StatusAccepted)
+	}
+
+	res, err := s.startSession(c, &user)
+	if err != nil {
+		return err
+	}
+
+	return xhttp.JSON(c, res, http.StatusOK)
+}
+
+// Checks the credentials of the user, counting the attempt against
+// the login rate limits.
+func (s *Server) checkPassword(
+	c *xhttp.Ctx,
+	email string,
+	password string,
+) (dto.User, error) {
+	account := strings.ToLower(email)
+	if err := s.checkLoginLimits(c, account); err != nil {
+		return dto.User{}, err
+	}
+
+	user, err := s.users.GetByEmail(c.Context(), email)
+	if errors.Is(err, repository.ErrUserNotFound) ||
+		(err == nil && !user.PasswordMatches(password)) {
+		// Unknown emails are also counted, so they can not be told apart
+		err = s.limits.LoginFailures.Fail(c.Context(), account)
+		if err != nil {
+			return user, err
+		}
+		return user, ErrUnauthorized
+	} else if err != nil {
+		return user, err
+	}
+
+	if err = s.limits.LoginFailures.Reset(c.Context(), account); err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+func (s *Server) checkLoginLimits(c *xhttp.Ctx, account string) error {
+	if err := s.limits.LoginIP.Hit(c.Context(), c.ClientIP()); err != nil {
+		return err
+	}
+	if err := s.limits.LoginEmail.Hit(c.Context(), account); err != nil {
+		return err
+	}
+	return s.limits.LoginFailures.Check(c.Context(), account)
+}
+
+// Creates a new session for the user, setting the auth cookies.
+func (s *Server) startSession(c *xhttp.Ctx, user *dto.User) (TokenResponse, error) {
+	// Checked before the session is stored, so that none is left behind
+	if user.Banned() {
+		return TokenResponse{}, repository.ErrUserBanned
+	}
+
+	refreshToken, session, err := s.auth.GenRefreshToken(
+		c.Context(),
+		user.ID,
+		c.SessionMetadata(),
+	)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	res, err := s.issueToken(user, session.ID, refreshToken)
+	if err != nil {
+		return TokenResponse{}, err
 	}
 
 	c.SetCookie(&http.Cookie{
 		Name:  "refresh_token",
-		Value: refreshToken,
+		Value: res.RefreshToken,
 		Path:  "/",
 	})
 	c.SetCookie(&http.Cookie{
 		Name:    "auth_token",
-		Value:   authToken,
-		Expires: token.ExpiresAt.Time,
+		Value:   res.AccessToken,
 		Path:    "/",
+		Expires: time.Now().Add(time.Duration(res.ExpiresIn) * time.Second),
 	})
 
-	c.Redirect("/")
-	return nil
+	return res, nil
 }
 
-func (s *Server) GetAuthLogout(c *xhttp.Ctx) error {
-	c.DelCookie("refresh_token")
-	c.DelCookie("auth_token")
+func (s *Server) issueToken(
+	user *dto.User,
+	sessionId dto.Snowflake,
+	refreshToken string,
+) (TokenResponse, error) {
+	if user.Banned() {
+		return TokenResponse{}, repository.ErrUserBanned
+	}
+
+	token := dto.NewAuthToken(user, "", s.cfg.JWT.GetDuration())
+	token.SessionID = sessionId
+
+	authToken, err := s.auth.EncodeToken(token)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{
+		AccessToken:  authToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(token.ExpiresAt.Time).Seconds()),
+	}, nil
+}
+
+// Responds with the tokens to json clients, redirecting the other ones.
+func authResponse(c *xhttp.Ctx, res TokenResponse) error {
+	if !c.IsHtmx() && c.AcceptsJSON() {
+		return xhttp.JSON(c, res, http.StatusOK)
+	}
 
 	c.Redirect("/")
 	return nil
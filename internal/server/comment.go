@@ -0,0 +1,362 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+// Number of replies fetched together with each comment, the
+// remaining ones being loaded on demand.
+const commentReplies = 3
+
+var ErrCommentTooDeep = errutils.NewHttpS(
+	"Comment replies can not be nested any deeper",
+	http.StatusBadRequest,
+	http.StatusBadRequest,
+	true,
+)
+
+type CommentCreateRequest = dto.CommentCreateData
+
+type CommentUpdateRequest = dto.CommentCreateData
+
+type CommentModerateRequest struct {
+	Hidden bool `json:"hidden" schema:"hidden"`
+}
+
+func (s *Server) wireComments(r chi.Router) {
+	r.Get("/articles/{id}/comments", s.m(s.GetComments))
+	r.Get(
+		"/articles/{id}/comments/{commentId}/replies",
+		s.m(s.GetCommentReplies),
+	)
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.rateLimit(s.limits.Comments, keyByUser))
+
+		r.Post("/articles/{id}/comments", s.pm(s.PostComment, templates.FormError))
+		r.Post(
+			"/articles/{id}/comments/{commentId}/replies",
+			s.pm(s.PostCommentReply, templates.FormError),
+		)
+	})
+
+	r.Patch(
+		"/articles/{id}/comments/{commentId}",
+		s.pm(s.PatchComment, templates.FormError),
+	)
+	r.Delete(
+		"/articles/{id}/comments/{commentId}",
+		s.pm(s.DeleteComment, templates.FormError),
+	)
+	r.Put(
+		"/articles/{id}/comments/{commentId}/moderation",
+		s.pm(s.PutCommentModeration, templates.FormError),
+	)
+}
+
+func (s *Server) GetComments(c *xhttp.Ctx) error {
+	token, err := c.GetAuth()
+	if err != nil {
+		return err
+	}
+
+	if !permissionOf(token).Has(dto.PermissionReadComments) {
+		return ErrForbidden
+	}
+
+	id, err := snowflakeParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var pag dto.Pagination
+	if err = c.ParseQuery(&pag); err != nil {
+		return err
+	}
+
+	if _, err = s.readableArticle(c, id); err != nil {
+		return err
+	}
+
+	comments, err := s.comments.GetThreads(c.Context(), id, pag, commentReplies)
+	if err != nil {
+		return err
+	}
+
+	data := templates.CommentListData{ArticleID: id}
+	return commentListResponse(c, token, data, comments, pag.Limit)
+}
+
+func (s *Server) GetCommentReplies(c *xhttp.Ctx) error {
+	token, err := c.GetAuth()
+	if err != nil {
+		return err
+	}
+
+	if !permissionOf(token).Has(dto.PermissionReadComments) {
+		return ErrForbidden
+	}
+
+	var pag dto.Pagination
+	if err = c.ParseQuery(&pag); err != nil {
+		return err
+	}
+
+	parent, err := s.articleComment(c)
+	if err != nil {
+		return err
+	}
+
+	comments, err := s.comments.GetReplies(
+		c.Context(),
+		parent.ID,
+		pag,
+		commentReplies,
+	)
+	if err != nil {
+		return err
+	}
+
+	data := templates.CommentListData{
+		ArticleID: parent.ArticleID,
+		ParentID:  parent.ID,
+	}
+	return commentListResponse(c, token, data, comments, pag.Limit)
+}
+
+func (s *Server) PostComment(c *xhttp.Ctx) error {
+	token, err := s.authorize(c, dto.PermissionWriteComments)
+	if err != nil {
+		return err
+	}
+
+	id, err := snowflakeParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var data CommentCreateRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	if _, err = s.readableArticle(c, id); err != nil {
+		return err
+	}
+
+	comment := dto.NewComment(id, token.ID, data)
+	if err = s.comments.Create(c.Context(), comment); err != nil {
+		return err
+	}
+
+	return s.commentResponse(c, comment, http.StatusCreated)
+}
+
+func (s *Server) PostCommentReply(c *xhttp.Ctx) error {
+	token, err := s.authorize(c, dto.PermissionWriteComments)
+	if err != nil {
+		return err
+	}
+
+	parent, err := s.articleComment(c)
+	if err != nil {
+		return err
+	}
+
+	if parent.Depth >= dto.MaxCommentDepth {
+		return ErrCommentTooDeep
+	}
+
+	var data CommentCreateRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	comment := dto.NewReply(parent, token.ID, data)
+	if err = s.comments.Create(c.Context(), comment); err != nil {
+		return err
+	}
+
+	return s.commentResponse(c, comment, http.StatusCreated)
+}
+
+func (s *Server) PatchComment(c *xhttp.Ctx) error {
+	token, err := s.authorize(c, dto.PermissionWriteComments)
+	if err != nil {
+		return err
+	}
+
+	comment, err := s.articleComment(c)
+	if err != nil {
+		return err
+	}
+
+	if comment.UserID != token.ID {
+		return ErrForbidden
+	}
+
+	var data CommentUpdateRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	comment, err = s.comments.UpdateContent(c.Context(), comment.ID, data.Content)
+	if err != nil {
+		return err
+	}
+
+	return s.commentResponse(c, comment, http.StatusOK)
+}
+
+func (s *Server) DeleteComment(c *xhttp.Ctx) error {
+	token, err := s.authenticate(c)
+	if err != nil {
+		return err
+	}
+
+	comment, err := s.articleComment(c)
+	if err != nil {
+		return err
+	}
+
+	owner := comment.UserID == token.ID &&
+		token.Permission.Has(dto.PermissionWriteComments)
+
+	if !owner && !token.Permission.Has(dto.PermissionModerateAllComments) {
+		return ErrForbidden
+	}
+
+	comment, err = s.comments.Delete(c.Context(), comment.ID)
+	if err != nil {
+		return err
+	}
+
+	return s.commentResponse(c, comment, http.StatusOK)
+}
+
+func (s *Server) PutCommentModeration(c *xhttp.Ctx) error {
+	_, err := s.authorize(c, dto.PermissionModerateAllComments)
+	if err != nil {
+		return err
+	}
+
+	comment, err := s.articleComment(c)
+	if err != nil {
+		return err
+	}
+
+	var data CommentModerateRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	comment, err = s.comments.UpdateHidden(c.Context(), comment.ID, data.Hidden)
+	if err != nil {
+		return err
+	}
+
+	return s.commentResponse(c, comment, http.StatusOK)
+}
+
+// Fetches the comment referenced by the `commentId` url parameter,
+// failing if it does not belong to the `id` article or if the user
+// can not read the article.
+func (s *Server) articleComment(c *xhttp.Ctx) (dto.Comment, error) {
+	articleId, err := snowflakeParam(c, "id")
+	if err != nil {
+		return dto.Comment{}, err
+	}
+
+	if _, err = s.readableArticle(c, articleId); err != nil {
+		return dto.Comment{}, err
+	}
+
+	id, err := snowflakeParam(c, "commentId")
+	if err != nil {
+		return dto.Comment{}, err
+	}
+
+	comment, err := s.comments.Get(c.Context(), id)
+	if err != nil {
+		return dto.Comment{}, err
+	}
+
+	if comment.ArticleID != articleId {
+		return dto.Comment{}, repository.ErrCommentNotFound
+	}
+
+	return comment, nil
+}
+
+func (s *Server) commentResponse(c *xhttp.Ctx, comment dto.Comment, code int) error {
+	if c.IsHtmx() {
+		c.Redirect(articleUrl(comment.ArticleID) + "#comments")
+		return nil
+	}
+
+	token, _ := c.GetAuth()
+	data := templates.PageData[dto.Comment]{
+		Name:  "Blog",
+		Token: token,
+		Data:  comment,
+	}
+
+	return xhttp.Component(c, templates.CommentPage, data, code)
+}
+
+// Renders a page of comments, only the list partial being rendered
+// if the request was made by htmx.
+func commentListResponse(
+	c *xhttp.Ctx,
+	token *dto.AuthToken,
+	data templates.CommentListData,
+	comments []dto.Comment,
+	limit int,
+) error {
+	redactComments(token, comments)
+
+	data.Comments = comments
+	if len(comments) == limit {
+		data.Next = comments[len(comments)-1].ID
+	}
+
+	page := templates.PageData[templates.CommentListData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	if c.IsHtmx() {
+		return xhttp.Component(c, templates.CommentList, page, http.StatusOK)
+	}
+	return xhttp.Component(c, templates.CommentsPage, page, http.StatusOK)
+}
+
+// Strips the content of hidden comments, including the ones among the
+// replies, if the user is neither their author nor a moderator. Replies
+// of hidden comments are only stripped if hidden themselves.
+func redactComments(token *dto.AuthToken, comments []dto.Comment) {
+	for i := range comments {
+		redactComments(token, comments[i].Replies)
+
+		if !comments[i].Hidden {
+			continue
+		}
+
+		if token != nil {
+			if token.ID == comments[i].UserID ||
+				token.Permission.Has(dto.PermissionModerateAllComments) {
+				continue
+			}
+		}
+
+		comments[i].Content = ""
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/oidc"
+	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+// Binds a pending login to the browser that started it, so users
+// can not be logged in to an account of someone else (login CSRF).
+const oidcStateCookie = "oidc_state"
+
+var (
+	ErrOIDCDisabled = errutils.NewHttpS(
+		"Signing in with an identity provider is not enabled",
+		http.StatusNotFound,
+		http.StatusNotFound,
+		true,
+	)
+	ErrOIDCEmailRequired = errutils.NewHttpS(
+		"The identity provider did not share your email",
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+		true,
+	)
+	ErrOIDCEmailConflict = errutils.NewHttpS(
+		"An account with this email already exists, log in with your "+
+			"password instead",
+		http.StatusConflict,
+		http.StatusConflict,
+		true,
+	)
+)
+
+// The parameters the identity provider redirects the user back with.
+type OIDCCallbackQuery struct {
+	State string `schema:"state"`
+	Code  string `schema:"code"`
+	// Set if the login failed or was denied by the user
+	Error            string `schema:"error"`
+	ErrorDescription string `schema:"error_description"`
+}
+
+func (s *Server) wireOIDC(r chi.Router) {
+	r.Get("/auth/oidc", s.m(s.GetAuthOIDC))
+	r.Get("/auth/oidc/callback", s.cfm(
+		s.GetAuthOIDCCallback,
+		templates.LoginPage,
+		templates.LoginForm,
+	))
+}
+
+// Redirects the user to the identity provider.
+func (s *Server) GetAuthOIDC(c *xhttp.Ctx) error {
+	if s.oidc == nil {
+		return ErrOIDCDisabled
+	}
+
+	err := s.limits.LoginIP.Hit(c.Context(), c.ClientIP())
+	if err != nil {
+		return err
+	}
+
+	url, state, err := s.oidc.AuthURL(c.Context())
+	if err != nil {
+		return err
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/",
+		Expires:  time.Now().Add(oidc.StateExpiry),
+		HttpOnly: true,
+		// Must be sent in the redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	c.Redirect(url)
+	return nil
+}
+
+// Finishes the login on the identity provider, signing the user up
+// if it is the first time.
+func (s *Server) GetAuthOIDCCallback(c *xhttp.Ctx) error {
+	if s.oidc == nil {
+		return ErrOIDCDisabled
+	}
+
+	var query OIDCCallbackQuery
+	if err := c.ParseQuery(&query); err != nil {
+		return err
+	}
+
+	if query.Error != "" {
+		slog.Debug(
+			"Server: GetAuthOIDCCallback: provider returned an error",
+			"error", query.Error,
+			"description", query.ErrorDescription,
+		)
+		return oidc.ErrLoginFailed
+	}
+
+	cookie := c.GetCookie(oidcStateCookie)
+	if cookie == nil || subtle.ConstantTimeCompare(
+		[]byte(cookie.Value),
+		[]byte(query.State),
+	) != 1 {
+		return oidc.ErrInvalidState
+	}
+	c.DelCookie(oidcStateCookie)
+
+	claims, err := s.oidc.Exchange(c.Context(), query.State, query.Code)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.oidcUser(c, &claims)
+	if err != nil {
+		return err
+	}
+
+	pending, ok, err := s.startTwoFactor(c, &user)
+	if err != nil {
+		return err
+	} else if ok {
+		return twoFactorResponse(c, pending)
+	}
+
+	res, err := s.startSession(c, &user)
+	if err != nil {
+		return err
+	}
+
+	return authResponse(c, res)
+}
+
+// Fetches the user the external account is linked to. Unknown accounts
+// are linked to the user with the same email if the provider verified
+// it, otherwise a new user is created.
+func (s *Server) oidcUser(c *xhttp.Ctx, claims *oidc.Claims) (dto.User, error) {
+	issuer := s.oidc.Issuer()
+
+	identity, err := s.identities.GetBySubject(c.Context(), issuer, claims.Subject)
+	if err == nil {
+		return s.users.GetById(c.Context(), identity.UserID)
+	} else if !errors.Is(err, repository.ErrIdentityNotFound) {
+		return dto.User{}, err
+	}
+
+	if claims.Email == "" {
+		return dto.User{}, ErrOIDCEmailRequired
+	}
+
+	user, err := s.users.GetByEmail(c.Context(), claims.Email)
+	if err == nil {
+		// Otherwise anyone could take over the account by setting
+		// its email on the provider
+		if !claims.EmailVerified {
+			return user, ErrOIDCEmailConflict
+		}
+
+		identity = dto.NewIdentity(user.ID, issuer, claims.Subject, claims.Email)
+		return user, s.identities.Create(c.Context(), identity)
+	} else if !errors.Is(err, repository.ErrUserNotFound) {
+		return user, err
+	}
+
+	user = dto.NewExternalUser(
+		claims.Email,
+		oidcNickname(claims),
+		claims.Name,
+		dto.PermissionDefault,
+		claims.EmailVerified,
+	)
+	if err = s.users.Create(c.Context(), user); err != nil {
+		return user, err
+	}
+
+	identity = dto.NewIdentity(user.ID, issuer, claims.Subject, claims.Email)
+	if err = s.identities.Create(c.Context(), identity); err != nil {
+		// Lost a race against a concurrent login of the same account
+		if _, err2 := s.users.DeleteById(c.Context(), user.ID); err2 != nil {
+			slog.Error(
+				"Server: oidcUser: failed to delete unlinked user",
+				"user_id", user.ID,
+				"error", err2,
+			)
+		}
+		return user, err
+	}
+
+	return user, nil
+}
+
+// Picks the nickname of a new user from the claims of the provider.
+func oidcNickname(claims *oidc.Claims) string {
+	nickname := claims.PreferredUsername
+	if nickname == "" {
+		nickname = claims.Name
+	}
+	if nickname == "" {
+		nickname, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	if runes := []rune(nickname); len(runes) > 32 {
+		nickname = string(runes[:32])
+	}
+	return nickname
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+// Max size of the responses of the authenticators, in bytes
+const passkeyResponseLimit = 64 * 1024
+
+type PasskeyCreateRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+	// The response of `navigator.credentials.create()`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+func (s *Server) wirePasskeys(r chi.Router) {
+	r.Post("/auth/passkey/begin", s.m(s.PostAuthPasskeyBegin))
+	r.Post("/auth/passkey", s.m(s.PostAuthPasskey))
+
+	r.Post("/profile/passkeys/begin", s.m(s.PostProfilePasskeyBegin))
+	r.Post("/profile/passkeys", s.m(s.PostProfilePasskey))
+	r.Delete(
+		"/profile/passkeys/{passkeyId}",
+		s.pm(s.DeleteProfilePasskey, templates.FormError),
+	)
+}
+
+// Returns the options of `navigator.credentials.get()` for a
+// passwordless login.
+func (s *Server) PostAuthPasskeyBegin(c *xhttp.Ctx) error {
+	err := s.limits.LoginIP.Hit(c.Context(), c.ClientIP())
+	if err != nil {
+		return err
+	}
+
+	assertion, err := s.passkeys.BeginLogin(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return xhttp.JSON(c, assertion, http.StatusOK)
+}
+
+// Finishes the passwordless login with the response of the authenticator,
+// creating the session. The second factor is not asked for, since the
+// authenticator already verified the user.
+func (s *Server) PostAuthPasskey(c *xhttp.Ctx) error {
+	body := c.BodyReader(passkeyResponseLimit)
+	defer body.Close()
+
+	passkey, err := s.passkeys.FinishLogin(c.Context(), body)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), passkey.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			err = repository.ErrInvalidPasskey
+		}
+		return err
+	}
+
+	res, err := s.startSession(c, &user)
+	if err != nil {
+		return err
+	}
+
+	return authResponse(c, res)
+}
+
+// Returns the options of `navigator.credentials.create()` for
+// registering a passkey.
+func (s *Server) PostProfilePasskeyBegin(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), token.ID)
+	if err != nil {
+		return err
+	}
+
+	creation, err := s.passkeys.BeginRegistration(c.Context(), &user)
+	if err != nil {
+		return err
+	}
+
+	return xhttp.JSON(c, creation, http.StatusOK)
+}
+
+func (s *Server) PostProfilePasskey(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	var req PasskeyCreateRequest
+	if err = c.Parse(&req); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), token.ID)
+	if err != nil {
+		return err
+	}
+
+	passkey, err := s.passkeys.FinishRegistration(
+		c.Context(),
+		&user,
+		req.Name,
+		bytes.NewReader(req.Credential),
+	)
+	if err != nil {
+		return err
+	}
+
+	return xhttp.JSON(c, passkey, http.StatusCreated)
+}
+
+func (s *Server) DeleteProfilePasskey(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := snowflakeParam(c, "passkeyId")
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.passkeys.Delete(c.Context(), id, token.ID); err != nil {
+		return err
+	}
+
+	if c.IsHtmx() {
+		c.Redirect("/profile/settings#passkeys")
+		return nil
+	}
+
+	data, err := s.settingsData(c, token)
+	if err != nil {
+		return err
+	}
+
+	return xhttp.Component(c, templates.SettingsPage, data, http.StatusOK)
+}
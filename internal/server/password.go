@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/mailer"
+	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+const resetPasswordExpiry = 30 * time.Minute
+
+// Min time it takes to respond to a forgot password request, which
+// should be longer than it usually takes to send the email.
+const forgotPasswordMinDuration = 3 * time.Second
+
+const resetPasswordBody = `Hi %s,
+
+Someone asked to reset the password of your account. Choose a new
+one by opening the link below:
+
+%s
+
+The link expires in 30 minutes and can only be used once. If you did
+not ask for it, you can ignore this email.
+`
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=128"`
+}
+
+type ForgotPasswordQuery struct {
+	Sent bool `schema:"sent"`
+}
+
+type ResetPasswordQuery struct {
+	Token string `schema:"token"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=256"`
+}
+
+func (s *Server) GetAuthForgot(c *xhttp.Ctx) error {
+	var query ForgotPasswordQuery
+	if err := c.ParseQuery(&query); err != nil {
+		return err
+	}
+
+	token, _ := c.GetAuth()
+	data := templates.PageData[error]{
+		Name:  "Blog",
+		Token: token,
+		Data:  nil,
+	}
+
+	if query.Sent {
+		return xhttp.Component(c, templates.ForgotPasswordSentPage, data, http.StatusOK)
+	}
+	return xhttp.Component(c, templates.ForgotPasswordPage, data, http.StatusOK)
+}
+
+// Sends a password reset link to the email, responding the same way
+// whether there is an account registered with it or not.
+func (s *Server) PostAuthForgot(c *xhttp.Ctx) error {
+	var data ForgotPasswordRequest
+	if err := c.Parse(&data); err != nil {
+		return err
+	}
+
+	// Unknown emails are also counted, so they can not be told apart
+	account := strings.ToLower(data.Email)
+	if _, err := s.limits.Emails.Take(c.Context(), "email:"+account); err != nil {
+		return err
+	}
+
+	// Both the known and unknown emails take at least the same time,
+	// so that the timing does not tell registered ones apart
+	deadline := time.Now().Add(forgotPasswordMinDuration)
+
+	user, err := s.users.GetByEmail(c.Context(), data.Email)
+	if err == nil {
+		// Failures are not returned, otherwise the response would
+		// tell registered emails apart
+		if err = s.sendResetEmail(c.Context(), &user); err != nil {
+			slog.Error(
+				"Server: PostAuthForgot: failed to send password reset email",
+				"user_id", user.ID,
+				"error", err,
+			)
+		}
+	} else if !errors.Is(err, repository.ErrUserNotFound) {
+		return err
+	}
+
+	select {
+	case <-time.After(time.Until(deadline)):
+	case <-c.Context().Done():
+		return c.Context().Err()
+	}
+
+	if !c.IsHtmx() && c.AcceptsJSON() {
+		c.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+
+	c.Redirect("/auth/forgot?sent=true")
+	return nil
+}
+
+func (s *Server) GetAuthReset(c *xhttp.Ctx) error {
+	var query ResetPasswordQuery
+	if err := c.ParseQuery(&query); err != nil {
+		return err
+	}
+
+	token, _ := c.GetAuth()
+	data := templates.PageData[string]{
+		Name:  "Blog",
+		Token: token,
+		Data:  query.Token,
+	}
+
+	return xhttp.Component(c, templates.ResetPasswordPage, data, http.StatusOK)
+}
+
+// Sets the new password of the user, logging out all of its sessions.
+func (s *Server) PostAuthReset(c *xhttp.Ctx) error {
+	var data ResetPasswordRequest
+	if err := c.Parse(&data); err != nil {
+		return err
+	}
+
+	claims, err := s.auth.ConsumeActionToken(
+		c.Context(),
+		data.Token,
+		dto.ActionResetPassword,
+	)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), claims.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			err = repository.ErrInvalidActionToken
+		}
+		return err
+	}
+	if user.Email != claims.Email {
+		// The email was changed after the token was sent
+		return repository.ErrInvalidActionToken
+	}
+
+	hash, err := dto.HashPassword(data.Password, s.cfg.BcryptCost)
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.users.UpdatePassword(c.Context(), user.ID, hash); err != nil {
+		return err
+	}
+
+	if err = s.auth.DeleteRefreshTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+	if err = s.auth.RevokeUserTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	account := strings.ToLower(user.Email)
+	if err = s.limits.LoginFailures.Reset(c.Context(), account); err != nil {
+		return err
+	}
+
+	c.DelCookie("refresh_token")
+	c.DelCookie("auth_token")
+
+	if !c.IsHtmx() && c.AcceptsJSON() {
+		c.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	c.Redirect("/auth/login")
+	return nil
+}
+
+func (s *Server) sendResetEmail(ctx context.Context, user *dto.User) error {
+	token, err := s.auth.GenActionToken(
+		ctx,
+		user,
+		dto.ActionResetPassword,
+		resetPasswordExpiry,
+	)
+	if err != nil {
+		return err
+	}
+
+	link := s.cfg.PublicURL + "/auth/reset?token=" + url.QueryEscape(token)
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      userAddress(user),
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf(resetPasswordBody, user.Nickname, link),
+	})
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+var (
+	ErrAccessTokenScope = errutils.NewHttpS(
+		"Access tokens can not have permissions you don't have",
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+		true,
+	)
+	ErrPasswordMismatch = errutils.NewHttpS(
+		"Current password doesn't match",
+		http.StatusUnauthorized,
+		http.StatusUnauthorized,
+		true,
+	)
+)
+
+type AccessTokenCreateRequest = dto.AccessTokenCreateData
+
+type ProfileUpdateRequest struct {
+	Nickname string `json:"nickname" validate:"required,max=32"`
+	Name     string `json:"name,omitempty"`
+}
+
+type EmailChangeRequest struct {
+	Email string `json:"email" validate:"required,email,max=128"`
+	// The current password of the user
+	Password string `json:"password" validate:"required,max=256"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=256"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=256"`
+}
+
+func (s *Server) wireProfile(r chi.Router) {
+	r.Get("/profile/settings", s.m(s.GetProfileSettings))
+
+	r.Patch("/profile", s.pm(s.PatchProfile, templates.FormError))
+	r.With(s.rateLimit(s.limits.Emails, keyByUser)).Put(
+		"/profile/email",
+		s.pm(s.PutProfileEmail, templates.FormError),
+	)
+	r.Put("/profile/password", s.pm(s.PutProfilePassword, templates.FormError))
+
+	r.Get("/profile/sessions", s.m(s.GetProfileSessions))
+	r.Delete(
+		"/profile/sessions/{sessionId}",
+		s.pm(s.DeleteProfileSession, templates.FormError),
+	)
+
+	r.Get("/profile/tokens", s.m(s.GetProfileTokens))
+	r.Post("/profile/tokens", s.pm(s.PostProfileToken, templates.FormError))
+	r.Delete(
+		"/profile/tokens/{tokenId}",
+		s.pm(s.DeleteProfileToken, templates.FormError),
+	)
+}
+
+func (s *Server) GetProfileSettings(c *xhttp.Ctx) error {
+	token, err := s.authenticate(c)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.settingsData(c, token)
+	if err != nil {
+		return err
+	}
+
+	return xhttp.Component(c, templates.SettingsPage, data, http.StatusOK)
+}
+
+func (s *Server) PatchProfile(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	var data ProfileUpdateRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	user, err := s.users.UpdateData(c.Context(), token.ID, data.Nickname, data.Name)
+	if err != nil {
+		return err
+	}
+
+	// The tokens carry the name of the user
+	if err = s.auth.RevokeUserTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	return profileResponse(c, &user)
+}
+
+// Changes the email of the user, sending a verification link to
+// the new address.
+func (s *Server) PutProfileEmail(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	var data EmailChangeRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), token.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.checkCurrentPassword(c, &user, data.Password); err != nil {
+		return err
+	}
+
+	if data.Email == user.Email {
+		return profileResponse(c, &user)
+	}
+
+	user, err = s.users.UpdateEmail(c.Context(), user.ID, data.Email)
+	if err != nil {
+		return err
+	}
+
+	// The tokens issued with the permission of verified users
+	// are replaced once refreshed
+	if err = s.auth.RevokeUserTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	// The user can ask for it to be sent again
+	if err = s.sendVerifyEmail(c.Context(), &user); err != nil {
+		slog.Error(
+			"Server: PutProfileEmail: failed to send verification email",
+			"user_id", user.ID,
+			"error", err,
+		)
+	}
+
+	return profileResponse(c, &user)
+}
+
+// Changes the password of the user, logging out all of its
+// other sessions.
+func (s *Server) PutProfilePassword(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	var data PasswordChangeRequest
+	if err = c.Parse(&data); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), token.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.checkCurrentPassword(c, &user, data.CurrentPassword); err != nil {
+		return err
+	}
+
+	hash, err := dto.HashPassword(data.NewPassword, s.cfg.BcryptCost)
+	if err != nil {
+		return err
+	}
+
+	user, err = s.users.UpdatePassword(c.Context(), user.ID, hash)
+	if err != nil {
+		return err
+	}
+
+	err = s.auth.DeleteOtherSessions(c.Context(), user.ID, token.SessionID)
+	if err != nil {
+		return err
+	}
+
+	// The current session gets a new token once refreshed
+	if err = s.auth.RevokeUserTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	return profileResponse(c, &user)
+}
+
+// Checks the password of the user before changing its credentials,
+// counting failures against the login lockout of the account.
+func (s *Server) checkCurrentPassword(
+	c *xhttp.Ctx,
+	user *dto.User,
+	password string,
+) error {
+	account := strings.ToLower(user.Email)
+	if err := s.limits.LoginFailures.Check(c.Context(), account); err != nil {
+		return err
+	}
+
+	if !user.PasswordMatches(password) {
+		err := s.limits.LoginFailures.Fail(c.Context(), account)
+		if err != nil {
+			return err
+		}
+		return ErrPasswordMismatch
+	}
+
+	return s.limits.LoginFailures.Reset(c.Context(), account)
+}
+
+// Responds with the user to json clients, redirecting the other
+// ones to the settings page.
+func profileResponse(c *xhttp.Ctx, user *dto.User) error {
+	if !c.IsHtmx() && c.AcceptsJSON() {
+		return xhttp.JSON(c, user, http.StatusOK)
+	}
+
+	c.Redirect("/profile/settings")
+	return nil
+}
+
+func (s *Server) GetProfileSessions(c *xhttp.Ctx) error {
+	token, err := s.authenticate(c)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.settingsData(c, token)
+	if err != nil {
+		return err
+	}
+
+	if c.IsHtmx() {
+		return xhttp.Component(c, templates.SessionList, data, http.StatusOK)
+	}
+	return xhttp.Component(c, templates.SettingsPage, data, http.StatusOK)
+}
+
+func (s *Server) DeleteProfileSession(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := snowflakeParam(c, "sessionId")
+	if err != nil {
+		return err
+	}
+
+	if err = s.auth.DeleteSession(c.Context(), token.ID, id); err != nil {
+		return err
+	}
+
+	if id == token.SessionID {
+		if err = s.auth.RevokeToken(c.Context(), *token); err != nil {
+			return err
+		}
+
+		c.DelCookie("refresh_token")
+		c.DelCookie("auth_token")
+
+		if c.IsHtmx() {
+			c.Redirect("/")
+			return nil
+		}
+	} else if c.IsHtmx() {
+		c.Redirect("/profile/settings")
+		return nil
+	}
+
+	data, err := s.settingsData(c, token)
+	if err != nil {
+		return err
+	}
+
+	return xhttp.Component(c, templates.SettingsPage, data, http.StatusOK)
+}
+
+func (s *Server) GetProfileTokens(c *xhttp.Ctx) error {
+	token, err := s.authenticate(c)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.settingsData(c, token)
+	if err != nil {
+		return err
+	}
+
+	if c.IsHtmx() {
+		return xhttp.Component(c, templates.AccessTokenList, data, http.StatusOK)
+	}
+	return xhttp.Component(c, templates.SettingsPage, data, http.StatusOK)
+}
+
+func (s *Server) PostProfileToken(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	var req AccessTokenCreateRequest
+	if err = c.Parse(&req); err != nil {
+		return err
+	}
+
+	if !token.Permission.Has(req.Permission()) {
+		return ErrAccessTokenScope
+	}
+
+	accessToken, secret := dto.NewAccessToken(token.ID, req)
+	if err = s.tokens.Create(c.Context(), accessToken); err != nil {
+		return err
+	}
+
+	data, err := s.settingsData(c, token)
+	if err != nil {
+		return err
+	}
+	data.Data.NewAccessToken = secret
+
+	if c.IsHtmx() {
+		return xhttp.Component(c, templates.AccessTokenCreated, data, http.StatusCreated)
+	}
+	return xhttp.Component(c, templates.SettingsPage, data, http.StatusCreated)
+}
+
+func (s *Server) DeleteProfileToken(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := snowflakeParam(c, "tokenId")
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.tokens.Delete(c.Context(), id, token.ID); err != nil {
+		return err
+	}
+
+	if c.IsHtmx() {
+		c.Redirect("/profile/settings#tokens")
+		return nil
+	}
+
+	data, err := s.settingsData(c, token)
+	if err != nil {
+		return err
+	}
+
+	return xhttp.Component(c, templates.SettingsPage, data, http.StatusOK)
+}
+
+func (s *Server) settingsData(
+	c *xhttp.Ctx,
+	token *dto.AuthToken,
+) (templates.PageData[templates.SettingsData], error) {
+	sessions, err := s.auth.GetSessions(c.Context(), token.ID)
+	if err != nil {
+		return templates.PageData[templates.SettingsData]{}, err
+	}
+
+	accessTokens, err := s.tokens.GetByUser(c.Context(), token.ID)
+	if err != nil {
+		return templates.PageData[templates.SettingsData]{}, err
+	}
+
+	user, err := s.users.GetById(c.Context(), token.ID)
+	if err != nil {
+		return templates.PageData[templates.SettingsData]{}, err
+	}
+
+	totp, err := s.totp.Get(c.Context(), token.ID)
+	if err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		return templates.PageData[templates.SettingsData]{}, err
+	}
+
+	passkeys, err := s.passkeys.GetByUser(c.Context(), token.ID)
+	if err != nil {
+		return templates.PageData[templates.SettingsData]{}, err
+	}
+
+	return templates.PageData[templates.SettingsData]{
+		Name:  "Blog",
+		Token: token,
+		Data: templates.SettingsData{
+			User:          user,
+			Sessions:      sessions,
+			AccessTokens:  accessTokens,
+			EmailVerified: user.EmailVerified(),
+			TwoFactor: templates.TwoFactorStatus{
+				Enabled:           totp.Enabled(),
+				Required:          s.twoFactorRequired(&user),
+				RecoveryCodesLeft: totp.RecoveryCodesLeft(),
+			},
+			Passkeys: passkeys,
+		},
+	}, nil
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zanz1n/blog/config"
+	"github.com/zanz1n/blog/internal/kv"
+	"github.com/zanz1n/blog/internal/ratelimit"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+)
+
+const (
+	loginLockBase   = 30 * time.Second
+	loginLockMax    = time.Hour
+	loginLockMemory = 24 * time.Hour
+
+	// Time it takes for each user to be able to post one more comment
+	commentRefill = 30 * time.Second
+)
+
+// Rate limits of the endpoints, the nil ones being disabled.
+type Limits struct {
+	LoginIP       *ratelimit.Limiter
+	LoginEmail    *ratelimit.Limiter
+	LoginFailures *ratelimit.Backoff
+	SignupIP      *ratelimit.Limiter
+
+	Comments *ratelimit.TokenBucket
+	Articles *ratelimit.FixedWindow
+	Emails   *ratelimit.FixedWindow
+	Search   *ratelimit.FixedWindow
+}
+
+func NewLimits(kv kv.KVStorer, cfg config.RateLimitConfig) Limits {
+	var limits Limits
+
+	if cfg.LoginIP > 0 {
+		limits.LoginIP = ratelimit.NewLimiter(kv, "login_ip", ratelimit.Limit{
+			Max:    cfg.LoginIP,
+			Window: time.Minute,
+		})
+	}
+	if cfg.LoginEmail > 0 {
+		limits.LoginEmail = ratelimit.NewLimiter(kv, "login_email", ratelimit.Limit{
+			Max:    cfg.LoginEmail,
+			Window: time.Minute,
+		})
+	}
+	if cfg.LoginFailures > 0 {
+		limits.LoginFailures = ratelimit.NewBackoff(
+			kv,
+			"login",
+			cfg.LoginFailures,
+			loginLockBase,
+			loginLockMax,
+			loginLockMemory,
+		)
+	}
+	if cfg.SignupIP > 0 {
+		limits.SignupIP = ratelimit.NewLimiter(kv, "signup_ip", ratelimit.Limit{
+			Max:    cfg.SignupIP,
+			Window: time.Hour,
+		})
+	}
+
+	if cfg.CommentBurst > 0 {
+		limits.Comments = ratelimit.NewTokenBucket(
+			kv,
+			"comments",
+			cfg.CommentBurst,
+			commentRefill,
+		)
+	}
+	if cfg.Articles > 0 {
+		limits.Articles = ratelimit.NewFixedWindow(kv, "articles", ratelimit.Limit{
+			Max:    cfg.Articles,
+			Window: time.Hour,
+		})
+	}
+
+	if cfg.Emails > 0 {
+		limits.Emails = ratelimit.NewFixedWindow(kv, "emails", ratelimit.Limit{
+			Max:    cfg.Emails,
+			Window: time.Hour,
+		})
+	}
+
+	if cfg.Search > 0 {
+		limits.Search = ratelimit.NewFixedWindow(kv, "search", ratelimit.Limit{
+			Max:    cfg.Search,
+			Window: time.Minute,
+		})
+	}
+
+	return limits
+}
+
+// Returns the key requests are rate limited by.
+type KeyFunc func(c *xhttp.Ctx) (string, error)
+
+func keyByIP(c *xhttp.Ctx) (string, error) {
+	return "ip:" + c.ClientIP(), nil
+}
+
+// Keys requests by the authenticated user, falling back
+// to the client ip address.
+func keyByUser(c *xhttp.Ctx) (string, error) {
+	token, err := c.GetAuth()
+	if err != nil {
+		return "", err
+	} else if token == nil {
+		return keyByIP(c)
+	}
+	return "user:" + token.ID.String(), nil
+}
+
+// Middleware limiting the rate of requests to the routes it is attached to,
+// setting the X-RateLimit-* headers. Policies holding nil pointers allow
+// every request.
+func (s *Server) rateLimit(
+	policy ratelimit.Policy,
+	key KeyFunc,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return xhttp.CtxHandler(func(c *xhttp.Ctx) error {
+			k, err := key(c)
+			if err != nil {
+				return err
+			}
+
+			status, err := policy.Take(c.Context(), k)
+			if status.Limit != 0 {
+				reset := int64(math.Ceil(status.Reset.Seconds()))
+
+				h := c.Header()
+				h.Set("X-RateLimit-Limit", strconv.FormatInt(status.Limit, 10))
+				h.Set("X-RateLimit-Remaining", strconv.FormatInt(status.Remaining, 10))
+				h.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+			}
+			if err != nil {
+				return err
+			}
+
+			// The handler reuses the authentication parsed by the key func
+			next.ServeHTTP(c, c.SharedRequest())
+			return nil
+		}, s.auth, s.users, s.tokens, s.cfg, false)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/diff"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/markdown"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+type RevisionDiffQuery struct {
+	From dto.Snowflake `schema:"from" validate:"required"`
+	// Zero to compare against the current content
+	To dto.Snowflake `schema:"to"`
+}
+
+func (s *Server) wireRevisions(r chi.Router) {
+	r.Get("/articles/{id}/revisions", s.m(s.GetArticleRevisions))
+	r.Get("/articles/{id}/revisions/diff", s.m(s.GetArticleRevisionDiff))
+	r.Post(
+		"/articles/{id}/revisions/{revisionId}/restore",
+		s.pm(s.PostArticleRevisionRestore, templates.FormError),
+	)
+}
+
+func (s *Server) GetArticleRevisions(c *xhttp.Ctx) error {
+	article, token, err := s.ownedArticle(c)
+	if err != nil {
+		return err
+	}
+
+	var pag dto.Pagination
+	if err = c.ParseQuery(&pag); err != nil {
+		return err
+	}
+
+	revisions, err := s.articles.GetRevisions(c.Context(), article.ID, pag)
+	if err != nil {
+		return err
+	}
+
+	data := templates.ArticleRevisionListData{
+		Article:   article,
+		Revisions: revisions,
+	}
+	if len(revisions) == pag.Limit {
+		data.Next = revisions[len(revisions)-1].ID
+	}
+
+	page := templates.PageData[templates.ArticleRevisionListData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	return xhttp.Component(c, templates.ArticleRevisionsPage, page, http.StatusOK)
+}
+
+func (s *Server) GetArticleRevisionDiff(c *xhttp.Ctx) error {
+	article, token, err := s.ownedArticle(c)
+	if err != nil {
+		return err
+	}
+
+	var query RevisionDiffQuery
+	if err = c.ParseQuery(&query); err != nil {
+		return err
+	}
+
+	from, err := s.articles.GetRevision(c.Context(), article.ID, query.From)
+	if err != nil {
+		return err
+	}
+
+	var to dto.ArticleRawContent
+	if query.To == 0 {
+		article, err = s.articles.GetWithRawContent(c.Context(), article.ID)
+		to = article.RawContent
+	} else {
+		var revision dto.ArticleRevision
+		revision, err = s.articles.GetRevision(c.Context(), article.ID, query.To)
+		to = revision.RawContent
+	}
+	if err != nil {
+		return err
+	}
+	article.RawContent = nil
+
+	page := templates.PageData[templates.ArticleDiffData]{
+		Name:  "Blog",
+		Token: token,
+		Data: templates.ArticleDiffData{
+			Article: article,
+			From:    query.From,
+			To:      query.To,
+			Lines:   diff.Lines(string(from.RawContent), string(to)),
+		},
+	}
+
+	return xhttp.Component(c, templates.ArticleDiffPage, page, http.StatusOK)
+}
+
+// Restores an old revision, running it through the markdown pipeline
+// again and recording it as a new revision.
+func (s *Server) PostArticleRevisionRestore(c *xhttp.Ctx) error {
+	article, token, err := s.ownedArticle(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := snowflakeParam(c, "revisionId")
+	if err != nil {
+		return err
+	}
+
+	revision, err := s.articles.GetRevision(c.Context(), article.ID, id)
+	if err != nil {
+		return err
+	}
+
+	md, err := markdown.ParseArticle(bytes.NewReader(revision.RawContent))
+	if err != nil {
+		return err
+	}
+
+	article, err = s.updateArticleContent(c, article.ID, token.ID, md)
+	if err != nil {
+		return err
+	}
+
+	return s.articleUploadResponse(c, article, md.Warnings, http.StatusOK)
+}
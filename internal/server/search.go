@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+type SearchQuery struct {
+	Query string `schema:"q" validate:"max=256"`
+}
+
+func (s *Server) wireSearch(r chi.Router) {
+	r.With(s.rateLimit(s.limits.Search, keyByUser)).Get("/search", s.m(s.GetSearch))
+}
+
+// Searches the published articles, only rendering the search form if
+// the query is empty.
+func (s *Server) GetSearch(c *xhttp.Ctx) error {
+	var query SearchQuery
+	if err := c.ParseQuery(&query); err != nil {
+		return err
+	}
+	query.Query = strings.TrimSpace(query.Query)
+
+	var pag dto.Pagination
+	if err := c.ParseQuery(&pag); err != nil {
+		return err
+	}
+
+	data := templates.SearchData{
+		Query:   query.Query,
+		Results: []dto.ArticleSearchResult{},
+	}
+
+	if query.Query != "" {
+		results, err := s.articles.Search(c.Context(), query.Query, pag)
+		if err != nil {
+			return err
+		}
+
+		data.Results = results
+		if len(results) == pag.Limit {
+			data.Next = results[len(results)-1].ID
+		}
+	}
+
+	token, _ := c.GetAuth()
+	page := templates.PageData[templates.SearchData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	return xhttp.Component(c, templates.SearchPage, page, http.StatusOK)
+}
@@ -7,33 +7,112 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/zanz1n/blog/config"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/mailer"
+	"github.com/zanz1n/blog/internal/oidc"
 	"github.com/zanz1n/blog/internal/repository"
 	"github.com/zanz1n/blog/internal/utils/errutils"
 	"github.com/zanz1n/blog/internal/utils/xhttp"
 	"github.com/zanz1n/blog/web/templates"
 )
 
+var (
+	ErrAuthRequired = errutils.NewHttpS(
+		"Authentication required",
+		http.StatusUnauthorized,
+		http.StatusUnauthorized,
+		true,
+	)
+	ErrForbidden = errutils.NewHttpS(
+		"You don't have permission to do that",
+		http.StatusForbidden,
+		http.StatusForbidden,
+		true,
+	)
+	ErrSessionRequired = errutils.NewHttpS(
+		"This action requires logging in",
+		http.StatusForbidden,
+		http.StatusForbidden,
+		true,
+	)
+	ErrInvalidId = errutils.NewHttpS(
+		"Invalid id",
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+		true,
+	)
+)
+
 type Server struct {
-	users *repository.UserRepository
-	auth  *repository.AuthRepository
+	users    *repository.UserRepository
+	articles *repository.ArticleRepository
+	tags     *repository.TagRepository
+	comments *repository.CommentRepository
+	tokens   *repository.AccessTokenRepository
+	totp     *repository.TOTPRepository
+	passkeys *repository.PasskeyRepository
+	auth     *repository.AuthRepository
+
+	identities *repository.IdentityRepository
+	audits     *repository.AuditRepository
+	// Nil if signing in with an identity provider is disabled
+	oidc *oidc.Provider
+
+	limits Limits
+	mailer mailer.Mailer
 
 	cfg *config.Config
 }
 
 func New(
 	users *repository.UserRepository,
+	articles *repository.ArticleRepository,
+	tags *repository.TagRepository,
+	comments *repository.CommentRepository,
+	tokens *repository.AccessTokenRepository,
+	totp *repository.TOTPRepository,
+	passkeys *repository.PasskeyRepository,
 	auth *repository.AuthRepository,
+	identities *repository.IdentityRepository,
+	audits *repository.AuditRepository,
+	oidc *oidc.Provider,
+	limits Limits,
+	mailer mailer.Mailer,
 	cfg *config.Config,
 ) *Server {
 	return &Server{
-		users: users,
-		auth:  auth,
-		cfg:   cfg,
+		users:    users,
+		articles: articles,
+		tags:     tags,
+		comments: comments,
+		tokens:   tokens,
+		totp:     totp,
+		passkeys: passkeys,
+		auth:     auth,
+
+		identities: identities,
+		audits:     audits,
+		oidc:       oidc,
+
+		limits: limits,
+		mailer: mailer,
+		cfg:    cfg,
 	}
 }
 
 func (s *Server) Wire(r chi.Router) {
 	s.wireAuth(r)
+	s.wireArticles(r)
+	s.wireRevisions(r)
+	s.wireTags(r)
+	s.wireSearch(r)
+	s.wireComments(r)
+	s.wireProfile(r)
+	s.wireTwoFactor(r)
+	s.wirePasskeys(r)
+	s.wireOIDC(r)
+	s.wireAdmin(r)
+	s.wireWellKnown(r)
 }
 
 func (s *Server) NotFoundHandler() http.HandlerFunc {
@@ -48,7 +127,7 @@ func (s *Server) NotFoundHandler() http.HandlerFunc {
 }
 
 func (s *Server) m(h xhttp.HandlerFunc) http.HandlerFunc {
-	return xhttp.CtxHandler(h, s.auth, s.users, s.cfg, true)
+	return xhttp.CtxHandler(h, s.auth, s.users, s.tokens, s.cfg, true)
 }
 
 func (s *Server) cfm(
@@ -58,15 +137,15 @@ func (s *Server) cfm(
 ) http.HandlerFunc {
 	return s.m(func(c *xhttp.Ctx) error {
 		err := h(c)
+		if err != nil && !c.IsHtmx() && c.AcceptsJSON() {
+			// Json clients get the default error response
+			return err
+		}
+
 		if err != nil {
 			herr := errutils.Http(err)
-
-			if herr.Transparent() {
-				errs := strings.ReplaceAll(herr.Error(), "\n", "<br/>")
-				err = errors.New(errs)
-			} else {
-				err = errors.New(http.StatusText(herr.HttpStatus()))
-			}
+			xhttp.ErrorHeaders(c, herr)
+			err = formError(herr)
 
 			if c.IsHtmx() {
 				xhttp.Component(c, partial, err, http.StatusOK)
@@ -84,3 +163,99 @@ func (s *Server) cfm(
 		return nil
 	})
 }
+
+// Like cfm, but only htmx requests get the partial component rendered,
+// the other ones fall back to the default error handling.
+func (s *Server) pm(
+	h xhttp.HandlerFunc,
+	partial xhttp.ComponentFunc[error],
+) http.HandlerFunc {
+	return s.m(func(c *xhttp.Ctx) error {
+		err := h(c)
+		if err != nil && c.IsHtmx() {
+			xhttp.ErrorHeaders(c, err)
+			err = formError(errutils.Http(err))
+			return xhttp.Component(c, partial, err, http.StatusOK)
+		}
+		return err
+	})
+}
+
+// Returns the token of the authenticated user, or nil if there is none.
+// Invalid authentication is treated as anonymous.
+func optionalAuth(c *xhttp.Ctx) *dto.AuthToken {
+	token, err := c.GetAuth()
+	if err != nil {
+		return nil
+	}
+	return token
+}
+
+// Returns the token of the authenticated user, failing if there is none.
+func (s *Server) authenticate(c *xhttp.Ctx) (*dto.AuthToken, error) {
+	token, err := c.GetAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	if token == nil {
+		return nil, ErrAuthRequired
+	}
+
+	return token, nil
+}
+
+// Returns the token of the authenticated user, failing if there is none
+// or it lacks the given permission.
+func (s *Server) authorize(c *xhttp.Ctx, perm dto.Permission) (*dto.AuthToken, error) {
+	token, err := s.authenticate(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Permission.Has(perm) {
+		return nil, ErrForbidden
+	}
+
+	return token, nil
+}
+
+// Like authenticate, but fails if the user was authenticated by some
+// means other than a login session, like a personal access token.
+func (s *Server) authenticateSession(c *xhttp.Ctx) (*dto.AuthToken, error) {
+	token, err := s.authenticate(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.SessionID == 0 {
+		return nil, ErrSessionRequired
+	}
+
+	return token, nil
+}
+
+// Returns the permission of the token, defaulting to the visitor
+// permission for unauthenticated users.
+func permissionOf(token *dto.AuthToken) dto.Permission {
+	if token == nil {
+		return dto.PermissionVisitor
+	}
+	return token.Permission
+}
+
+func snowflakeParam(c *xhttp.Ctx, key string) (dto.Snowflake, error) {
+	var id dto.Snowflake
+	if err := id.UnmarshalText([]byte(c.URLParam(key))); err != nil {
+		return 0, ErrInvalidId
+	}
+	return id, nil
+}
+
+func formError(herr errutils.HttpError) error {
+	if herr.Transparent() {
+		errs := strings.ReplaceAll(herr.Error(), "\n", "<br/>")
+		return errors.New(errs)
+	}
+	return errors.New(http.StatusText(herr.HttpStatus()))
+}
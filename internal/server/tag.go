@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+func (s *Server) wireTags(r chi.Router) {
+	r.Get("/tags", s.m(s.GetTags))
+	r.Get("/tags/{slug}", s.m(s.GetTagArticles))
+}
+
+func (s *Server) GetTags(c *xhttp.Ctx) error {
+	tags, err := s.tags.GetMany(c.Context())
+	if err != nil {
+		return err
+	}
+
+	token, _ := c.GetAuth()
+	page := templates.PageData[templates.TagListData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  templates.TagListData{Tags: tags},
+	}
+
+	return xhttp.Component(c, templates.TagsPage, page, http.StatusOK)
+}
+
+func (s *Server) GetTagArticles(c *xhttp.Ctx) error {
+	var pag dto.Pagination
+	if err := c.ParseQuery(&pag); err != nil {
+		return err
+	}
+
+	tag, err := s.tags.GetBySlug(c.Context(), c.URLParam("slug"))
+	if err != nil {
+		return err
+	}
+
+	articles, err := s.articles.GetManyByTag(c.Context(), tag.ID, pag)
+	if err != nil {
+		return err
+	}
+
+	data := templates.TagArticleListData{
+		Tag: tag,
+		ArticleListData: templates.ArticleListData{
+			Articles: articles,
+		},
+	}
+	if len(articles) == pag.Limit {
+		data.Next = articles[len(articles)-1].ID
+	}
+
+	token, _ := c.GetAuth()
+	page := templates.PageData[templates.TagArticleListData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	return xhttp.Component(c, templates.TagArticlesPage, page, http.StatusOK)
+}
@@ -0,0 +1,428 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pquerna/otp"
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+	"github.com/zanz1n/blog/web/templates"
+)
+
+// Holds the pending login of html clients
+const twoFactorCookie = "two_factor_token"
+
+// Size of the qr code image, in pixels
+const totpQRSize = 200
+
+var ErrTwoFactorRequired = errutils.NewHttpS(
+	"Two-factor authentication is required for your account",
+	http.StatusForbidden,
+	http.StatusForbidden,
+	true,
+)
+
+type TwoFactorRequest struct {
+	// Defaults to the two_factor_token cookie
+	Token string `json:"two_factor_token"`
+	// Either a totp code or a recovery code
+	Code string `json:"code" validate:"required,max=16"`
+}
+
+// Returned instead of the tokens when the login needs a second factor.
+type TwoFactorResponse struct {
+	TwoFactorToken string `json:"two_factor_token"`
+	// In seconds
+	ExpiresIn int64 `json:"expires_in"`
+	// Set if the user must set up two-factor authentication with
+	// the otpauth uri before logging in
+	Setup bool   `json:"setup,omitempty"`
+	URI   string `json:"uri,omitempty"`
+}
+
+type TwoFactorEnableRequest struct {
+	Code string `json:"code" validate:"required,max=16"`
+}
+
+type TwoFactorDisableRequest struct {
+	// The current password of the user
+	Password string `json:"password" validate:"required,max=256"`
+}
+
+func (s *Server) wireTwoFactor(r chi.Router) {
+	r.Get("/auth/2fa", s.m(s.GetAuthTwoFactor))
+	r.Post("/auth/2fa", s.pm(s.PostAuthTwoFactor, templates.FormError))
+
+	r.Post("/profile/2fa", s.pm(s.PostProfileTwoFactor, templates.FormError))
+	r.Post(
+		"/profile/2fa/enable",
+		s.pm(s.PostProfileTwoFactorEnable, templates.FormError),
+	)
+	r.Post(
+		"/profile/2fa/disable",
+		s.pm(s.PostProfileTwoFactorDisable, templates.FormError),
+	)
+}
+
+// Renders the second step of the login, where the code is entered.
+func (s *Server) GetAuthTwoFactor(c *xhttp.Ctx) error {
+	cookie := c.GetCookie(twoFactorCookie)
+	if cookie == nil {
+		c.Redirect("/auth/login")
+		return nil
+	}
+
+	userId, err := s.auth.GetTwoFactorToken(c.Context(), cookie.Value)
+	if errors.Is(err, repository.ErrInvalidTwoFactorToken) {
+		c.DelCookie(twoFactorCookie)
+		c.Redirect("/auth/login")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), userId)
+	if err != nil {
+		return err
+	}
+
+	totp, err := s.totp.Get(c.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	var data templates.TwoFactorData
+	if !totp.Enabled() {
+		setup, err := s.totpSetup(&totp, &user)
+		if err != nil {
+			return err
+		}
+		data.Setup = &setup
+	}
+
+	token, _ := c.GetAuth()
+	p := templates.PageData[templates.TwoFactorData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	return xhttp.Component(c, templates.TwoFactorPage, p, http.StatusOK)
+}
+
+// Finishes the login with the second factor, creating the session.
+func (s *Server) PostAuthTwoFactor(c *xhttp.Ctx) error {
+	var data TwoFactorRequest
+	if err := c.Parse(&data); err != nil {
+		return err
+	}
+
+	if cookie := c.GetCookie(twoFactorCookie); data.Token == "" && cookie != nil {
+		data.Token = cookie.Value
+	}
+
+	user, codes, err := s.completeTwoFactor(c, data.Token, data.Code)
+	if err != nil {
+		return err
+	}
+
+	c.DelCookie(twoFactorCookie)
+
+	res, err := s.startSession(c, &user)
+	if err != nil {
+		return err
+	}
+
+	if codes == nil {
+		return authResponse(c, res)
+	}
+
+	// Enrolled while logging in
+	if !c.IsHtmx() && c.AcceptsJSON() {
+		res.RecoveryCodes = codes
+		return xhttp.JSON(c, res, http.StatusOK)
+	}
+
+	return recoveryCodesResponse(c, "#two-factor", templates.RecoveryCodesData{
+		Codes: codes,
+		Next:  "/",
+	})
+}
+
+// Starts the enrollment, replacing the pending one, if any.
+func (s *Server) PostProfileTwoFactor(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), token.ID)
+	if err != nil {
+		return err
+	}
+
+	totp, err := s.totp.Begin(c.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	setup, err := s.totpSetup(&totp, &user)
+	if err != nil {
+		return err
+	}
+
+	if c.IsHtmx() {
+		c.Header().Set("HX-Retarget", "#two-factor-content")
+		c.Header().Set("HX-Reswap", "innerHTML")
+		return xhttp.Component(c, templates.TwoFactorSetup, setup, http.StatusOK)
+	}
+
+	data, err := s.settingsData(c, token)
+	if err != nil {
+		return err
+	}
+	data.Data.TwoFactorSetup = &setup
+
+	return xhttp.Component(c, templates.SettingsPage, data, http.StatusOK)
+}
+
+func (s *Server) PostProfileTwoFactorEnable(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	var req TwoFactorEnableRequest
+	if err = c.Parse(&req); err != nil {
+		return err
+	}
+
+	_, codes, err := s.totp.Enable(c.Context(), token.ID, req.Code)
+	if err != nil {
+		return err
+	}
+
+	if c.IsHtmx() {
+		return recoveryCodesResponse(c, "#two-factor-content", templates.RecoveryCodesData{
+			Codes: codes,
+			Next:  "/profile/settings#two-factor",
+		})
+	}
+
+	data, err := s.settingsData(c, token)
+	if err != nil {
+		return err
+	}
+	data.Data.RecoveryCodes = codes
+
+	return xhttp.Component(c, templates.SettingsPage, data, http.StatusOK)
+}
+
+func (s *Server) PostProfileTwoFactorDisable(c *xhttp.Ctx) error {
+	token, err := s.authenticateSession(c)
+	if err != nil {
+		return err
+	}
+
+	var req TwoFactorDisableRequest
+	if err = c.Parse(&req); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), token.ID)
+	if err != nil {
+		return err
+	}
+
+	if s.twoFactorRequired(&user) {
+		return ErrTwoFactorRequired
+	}
+
+	if err = s.checkCurrentPassword(c, &user, req.Password); err != nil {
+		return err
+	}
+
+	if _, err = s.totp.Delete(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	return profileResponse(c, &user)
+}
+
+// Issues a pending login if the user has two-factor authentication
+// enabled, or must set it up, starting the enrollment in that case.
+// Reports false if the session can be created right away.
+func (s *Server) startTwoFactor(
+	c *xhttp.Ctx,
+	user *dto.User,
+) (TwoFactorResponse, bool, error) {
+	var res TwoFactorResponse
+
+	// Told before the second factor is asked for
+	if user.Banned() {
+		return res, false, repository.ErrUserBanned
+	}
+
+	totp, err := s.totp.Get(c.Context(), user.ID)
+	if err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		return res, false, err
+	}
+
+	if err != nil || !totp.Enabled() {
+		if !s.twoFactorRequired(user) {
+			return res, false, nil
+		}
+
+		totp, err = s.totp.Begin(c.Context(), user.ID)
+		if err != nil {
+			return res, false, err
+		}
+
+		res.Setup = true
+		res.URI = totp.URI(s.cfg.TwoFactor.Issuer, user.Email)
+	}
+
+	res.TwoFactorToken, err = s.auth.GenTwoFactorToken(c.Context(), user.ID)
+	if err != nil {
+		return res, false, err
+	}
+	res.ExpiresIn = int64(repository.TwoFactorTokenExpiry.Seconds())
+
+	return res, true, nil
+}
+
+// Checks the code entered for the pending login, enabling two-factor
+// authentication if the user was enrolling. The recovery codes are only
+// returned in this case.
+func (s *Server) completeTwoFactor(
+	c *xhttp.Ctx,
+	token string,
+	code string,
+) (dto.User, []string, error) {
+	userId, err := s.auth.GetTwoFactorToken(c.Context(), token)
+	if err != nil {
+		return dto.User{}, nil, err
+	}
+
+	user, err := s.users.GetById(c.Context(), userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			err = repository.ErrInvalidTwoFactorToken
+		}
+		return user, nil, err
+	}
+
+	totp, err := s.totp.Get(c.Context(), user.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPNotFound) {
+			// Disabled after the login started
+			err = repository.ErrInvalidTwoFactorToken
+		}
+		return user, nil, err
+	}
+
+	var codes []string
+	if totp.Enabled() {
+		_, err = s.totp.Verify(c.Context(), user.ID, code)
+	} else {
+		_, codes, err = s.totp.Enable(c.Context(), user.ID, code)
+	}
+
+	if errors.Is(err, repository.ErrInvalidTOTPCode) {
+		if ferr := s.auth.FailTwoFactorToken(c.Context(), token); ferr != nil {
+			return user, nil, ferr
+		}
+	}
+	if err != nil {
+		return user, nil, err
+	}
+
+	if err = s.auth.ConsumeTwoFactorToken(c.Context(), token); err != nil {
+		return user, nil, err
+	}
+
+	return user, codes, nil
+}
+
+// Responds with the pending login to json clients, redirecting the
+// other ones to the page where the code is entered.
+func twoFactorResponse(c *xhttp.Ctx, res TwoFactorResponse) error {
+	if !c.IsHtmx() && c.AcceptsJSON() {
+		return xhttp.JSON(c, res, http.StatusAccepted)
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     twoFactorCookie,
+		Value:    res.TwoFactorToken,
+		Path:     "/",
+		Expires:  time.Now().Add(time.Duration(res.ExpiresIn) * time.Second),
+		HttpOnly: true,
+	})
+
+	c.Redirect("/auth/2fa")
+	return nil
+}
+
+// Renders the recovery codes in place of the htmx target element,
+// or in a page of their own.
+func recoveryCodesResponse(
+	c *xhttp.Ctx,
+	target string,
+	data templates.RecoveryCodesData,
+) error {
+	if c.IsHtmx() {
+		c.Header().Set("HX-Retarget", target)
+		c.Header().Set("HX-Reswap", "innerHTML")
+		return xhttp.Component(c, templates.RecoveryCodes, data, http.StatusOK)
+	}
+
+	token, _ := c.GetAuth()
+	p := templates.PageData[templates.RecoveryCodesData]{
+		Name:  "Blog",
+		Token: token,
+		Data:  data,
+	}
+
+	return xhttp.Component(c, templates.RecoveryCodesPage, p, http.StatusOK)
+}
+
+func (s *Server) twoFactorRequired(user *dto.User) bool {
+	return s.cfg.TwoFactor.RequirePublishers &&
+		user.Permission.Has(dto.PermissionWritePosts)
+}
+
+func (s *Server) totpSetup(
+	totp *dto.TOTP,
+	user *dto.User,
+) (templates.TwoFactorSetupData, error) {
+	uri := totp.URI(s.cfg.TwoFactor.Issuer, user.Email)
+
+	key, err := otp.NewKeyFromURL(uri)
+	if err != nil {
+		return templates.TwoFactorSetupData{}, err
+	}
+
+	img, err := key.Image(totpQRSize, totpQRSize)
+	if err != nil {
+		return templates.TwoFactorSetupData{}, err
+	}
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return templates.TwoFactorSetupData{}, err
+	}
+
+	return templates.TwoFactorSetupData{
+		Secret: totp.Secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
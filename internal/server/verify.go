@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/zanz1n/blog/internal/dto"
+	"github.com/zanz1n/blog/internal/mailer"
+	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/utils/errutils"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+)
+
+const verifyEmailExpiry = 24 * time.Hour
+
+const verifyEmailBody = `Hi %s,
+
+Confirm your email address by opening the link below:
+
+%s
+
+The link expires in 24 hours. If you did not create an account,
+you can ignore this email.
+`
+
+var ErrEmailAlreadyVerified = errutils.NewHttpS(
+	"Your email is already verified",
+	http.StatusBadRequest,
+	http.StatusBadRequest,
+	true,
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" schema:"token" validate:"required"`
+}
+
+func (s *Server) GetAuthVerify(c *xhttp.Ctx) error {
+	var data VerifyEmailRequest
+	if err := c.ParseQuery(&data); err != nil {
+		return err
+	}
+
+	claims, err := s.auth.ConsumeActionToken(
+		c.Context(),
+		data.Token,
+		dto.ActionVerifyEmail,
+	)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.SetEmailVerified(c.Context(), claims.ID, claims.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			// The email was changed after the token was sent
+			err = repository.ErrInvalidActionToken
+		}
+		return err
+	}
+
+	// The tokens issued with the permission of unverified users
+	// are replaced once refreshed
+	if err = s.auth.RevokeUserTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	if c.AcceptsJSON() {
+		return xhttp.JSON(c, user, http.StatusOK)
+	}
+
+	c.Redirect("/profile/settings")
+	return nil
+}
+
+// Sends the verification email again.
+func (s *Server) PostAuthVerify(c *xhttp.Ctx) error {
+	token, err := s.authenticate(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(c.Context(), token.ID)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
+
+	if err = s.sendVerifyEmail(c.Context(), &user); err != nil {
+		return err
+	}
+
+	if !c.IsHtmx() && c.AcceptsJSON() {
+		c.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+
+	c.Redirect("/profile/settings")
+	return nil
+}
+
+func (s *Server) sendVerifyEmail(ctx context.Context, user *dto.User) error {
+	token, err := s.auth.GenActionToken(
+		ctx,
+		user,
+		dto.ActionVerifyEmail,
+		verifyEmailExpiry,
+	)
+	if err != nil {
+		return err
+	}
+
+	link := s.cfg.PublicURL + "/auth/verify?token=" + url.QueryEscape(token)
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      userAddress(user),
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf(verifyEmailBody, user.Nickname, link),
+	})
+}
+
+func userAddress(user *dto.User) string {
+	addr := mail.Address{Name: user.Name, Address: user.Email}
+	return addr.String()
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zanz1n/blog/internal/utils/xhttp"
+)
+
+const jwksPath = "/.well-known/jwks.json"
+
+// Minimal OpenID Provider metadata, just enough for other services
+// to discover the keys auth tokens are signed with.
+type OpenIDConfiguration struct {
+	Issuer                 string   `json:"issuer"`
+	JwksUri                string   `json:"jwks_uri"`
+	SigningAlgsSupported   []string `json:"id_token_signing_alg_values_supported"`
+	SubjectTypesSupported  []string `json:"subject_types_supported"`
+	ResponseTypesSupported []string `json:"response_types_supported"`
+}
+
+func (s *Server) wireWellKnown(r chi.Router) {
+	r.Get(jwksPath, s.m(s.GetJWKS))
+	r.Get("/.well-known/openid-configuration", s.m(s.GetOpenIDConfiguration))
+}
+
+func (s *Server) GetJWKS(c *xhttp.Ctx) error {
+	c.Header().Set("Cache-Control", "public, max-age=300")
+	return xhttp.JSON(c, s.auth.JWKS(), http.StatusOK)
+}
+
+func (s *Server) GetOpenIDConfiguration(c *xhttp.Ctx) error {
+	issuer := s.auth.Issuer()
+
+	// The jwks must be served under the issuer url if it is one
+	base := strings.TrimSuffix(issuer, "/")
+	if !strings.HasPrefix(base, "https://") && !strings.HasPrefix(base, "http://") {
+		base = requestOrigin(c)
+	}
+
+	data := OpenIDConfiguration{
+		Issuer:                 issuer,
+		JwksUri:                base + jwksPath,
+		SigningAlgsSupported:   []string{"EdDSA"},
+		SubjectTypesSupported:  []string{"public"},
+		ResponseTypesSupported: []string{"id_token"},
+	}
+
+	c.Header().Set("Cache-Control", "public, max-age=300")
+	return xhttp.JSON(c, data, http.StatusOK)
+}
+
+func requestOrigin(c *xhttp.Ctx) string {
+	scheme := "http"
+	if c.TLS != nil {
+		scheme = "https"
+	} else if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + c.Host
+}
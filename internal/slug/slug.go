@@ -0,0 +1,93 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Max length of the generated slugs, collision suffixes not included.
+const MaxLength = 80
+
+// Returned for strings without anything that could be transliterated.
+const Fallback = "article"
+
+// Letters that are not split into a base letter and marks
+// by the unicode decomposition.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "ae", 'œ': "oe", 'Œ': "oe",
+	'ø': "o", 'Ø': "o", 'đ': "d", 'Đ': "d", 'ð': "d", 'Ð': "d",
+	'ł': "l", 'Ł': "l", 'þ': "th", 'Þ': "th", 'ı': "i", 'ħ': "h",
+
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e",
+	'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k",
+	'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya", 'є': "ye", 'і': "i", 'ї': "yi",
+	'ґ': "g",
+
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z",
+	'η': "i", 'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m",
+	'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s",
+	'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+}
+
+// Generates a lowercase slug from s, made of ascii letters and digits
+// separated by single hyphens.
+//
+// Accented letters lose their accents and some non latin scripts are
+// transliterated, while the remaining characters act as separators.
+func Make(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	// Avoids leading and repeated hyphens
+	sep := false
+	write := func(str string) {
+		if str == "" {
+			return
+		}
+		if sep && b.Len() != 0 {
+			b.WriteByte('-')
+		}
+		sep = false
+		b.WriteString(str)
+	}
+
+	for _, r := range norm.NFKD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Accents split from their letters
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			write(string(unicode.ToLower(r)))
+		case r == '\'' || r == '’':
+			// Keeps contractions together
+		default:
+			if t, ok := transliterations[unicode.ToLower(r)]; ok {
+				write(t)
+			} else {
+				sep = true
+			}
+		}
+	}
+
+	return truncate(b.String())
+}
+
+func truncate(s string) string {
+	if len(s) > MaxLength {
+		s = s[:MaxLength]
+		// Cuts at the last whole word, if any
+		if i := strings.LastIndexByte(s, '-'); i > 0 {
+			s = s[:i]
+		}
+		s = strings.TrimSuffix(s, "-")
+	}
+	if s == "" {
+		return Fallback
+	}
+	return s
+}
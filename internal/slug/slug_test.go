@@ -0,0 +1,40 @@
+package slug_test
+
+import (
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/zanz1n/blog/internal/slug"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  --Go 1.23   is out--  ", "go-1-23-is-out"},
+		{"Don't panic", "dont-panic"},
+		{"Crème brûlée à la française", "creme-brulee-a-la-francaise"},
+		{"Straße und Øl", "strasse-und-ol"},
+		{"Ｆｕｌｌｗｉｄｔｈ", "fullwidth"},
+		{"Привет, мир", "privet-mir"},
+		{"Καλημέρα", "kalimera"},
+		{"日本語", slug.Fallback},
+		{"", slug.Fallback},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			assert.Equal(t, test.want, slug.Make(test.in))
+		})
+	}
+}
+
+func TestMakeTruncate(t *testing.T) {
+	s := slug.Make(strings.Repeat("abcdefghi ", 20))
+
+	assert.LessOrEqual(t, len(s), slug.MaxLength)
+	assert.False(t, strings.HasSuffix(s, "-"))
+	assert.Equal(t, strings.TrimSuffix(strings.Repeat("abcdefghi-", 8), "-"), s)
+}
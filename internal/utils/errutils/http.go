@@ -68,3 +68,35 @@ func Http(err error) HttpError {
 		error:       err,
 	}
 }
+
+// An HttpError that also sets headers on the response,
+// like Retry-After.
+type HttpHeaderError interface {
+	HttpError
+	Header() http.Header
+}
+
+type httpHeader struct {
+	HttpError
+	header http.Header
+}
+
+func (e *httpHeader) Header() http.Header {
+	return e.header
+}
+
+func (e *httpHeader) Unwrap() error {
+	return e.HttpError
+}
+
+// Wraps the error so the header is set on the response, keeping
+// the headers already set on err.
+func WithHeader(err HttpError, key, value string) HttpHeaderError {
+	header := http.Header{}
+	if herr, ok := err.(HttpHeaderError); ok {
+		header = herr.Header().Clone()
+	}
+	header.Set(key, value)
+
+	return &httpHeader{HttpError: err, header: header}
+}
@@ -78,6 +78,15 @@ func (q *Queries) Get(name string) (*sqlx.Stmt, error) {
 	return lz.Get()
 }
 
+// Begins a transaction, in which the queries can be run
+// with sqlx.Tx.StmtxContext.
+//
+// The queries must be fetched before beginning it, as preparing them
+// may need another connection.
+func (q *Queries) Begin(ctx context.Context) (*sqlx.Tx, error) {
+	return q.db.BeginTxx(ctx, nil)
+}
+
 // Close implements io.Closer.
 //
 // This is thread safe and can be called at any time.
@@ -2,12 +2,21 @@ package xhttp
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/zanz1n/blog/config"
 	"github.com/zanz1n/blog/internal/dto"
 	"github.com/zanz1n/blog/internal/repository"
+	"github.com/zanz1n/blog/internal/utils/errutils"
 	"github.com/zanz1n/blog/web/templates"
 )
 
@@ -17,13 +26,14 @@ func CtxHandler(
 	h HandlerFunc,
 	auth *repository.AuthRepository,
 	users *repository.UserRepository,
+	tokens *repository.AccessTokenRepository,
 	cfg *config.Config,
 	logs bool,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		c := newCtx(w, r, auth, users, cfg)
+		c := newCtx(w, r, auth, users, tokens, cfg)
 		defer c.cancel()
 
 		err := h(c)
@@ -47,19 +57,42 @@ func newCtx(
 	r *http.Request,
 	auth *repository.AuthRepository,
 	users *repository.UserRepository,
+	tokens *repository.AccessTokenRepository,
 	cfg *config.Config,
 ) *Ctx {
 	ctx, cancel := context.WithTimeout(r.Context(), cfg.GetTimeout())
 
-	return &Ctx{
+	c := &Ctx{
 		w:       w,
 		Request: r,
 		authr:   auth,
 		users:   users,
+		tokens:  tokens,
 		cfg:     cfg,
 		ctx:     ctx,
 		cancel:  cancel,
 	}
+
+	if token, ok := r.Context().Value(sharedAuthKey{}).(*dto.AuthToken); ok {
+		c.auth = token
+		c.authParsed = true
+	}
+
+	return c
+}
+
+type sharedAuthKey struct{}
+
+// Returns the request carrying the authentication already parsed by
+// the ctx, which is reused by the ctxs created down the handler chain.
+// Parsing it again could rotate the refresh token twice.
+func (c *Ctx) SharedRequest() *http.Request {
+	if !c.authParsed {
+		return c.Request
+	}
+
+	ctx := context.WithValue(c.Request.Context(), sharedAuthKey{}, c.auth)
+	return c.Request.WithContext(ctx)
 }
 
 var _ http.ResponseWriter = &Ctx{}
@@ -68,9 +101,10 @@ type Ctx struct {
 	w http.ResponseWriter
 	*http.Request
 
-	authr *repository.AuthRepository
-	users *repository.UserRepository
-	cfg   *config.Config
+	authr  *repository.AuthRepository
+	users  *repository.UserRepository
+	tokens *repository.AccessTokenRepository
+	cfg    *config.Config
 
 	statusCode int
 
@@ -120,9 +154,59 @@ func (c *Ctx) GetHeader(key string) string {
 }
 
 func (c *Ctx) Parse(v any) error {
+	c.limitMultipart()
 	return parse(c.Request, v)
 }
 
+// Returns the media type of the request body, without parameters.
+func (c *Ctx) ContentType() string {
+	ct, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	return ct
+}
+
+// Returns the request body, limited to n bytes.
+func (c *Ctx) BodyReader(n int64) io.ReadCloser {
+	return http.MaxBytesReader(c, c.Request.Body, n)
+}
+
+// The file can be nil if the request is not multipart
+// or does not contain it.
+func (c *Ctx) FormFile(key string) (multipart.File, error) {
+	if c.ContentType() != "multipart/form-data" {
+		return nil, nil
+	}
+	c.limitMultipart()
+
+	file, _, err := c.Request.FormFile(key)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errutils.NewHttp(
+			fmt.Errorf("parse request: multipart: %s", err),
+			http.StatusUnprocessableEntity,
+			http.StatusUnprocessableEntity,
+			true,
+		)
+	}
+
+	return file, nil
+}
+
+func (c *Ctx) limitMultipart() {
+	if c.Request.MultipartForm == nil && c.ContentType() == "multipart/form-data" {
+		c.Request.Body = c.BodyReader(MaxMultipartSize)
+	}
+}
+
+// Decodes the url query parameters into v, using the `schema` struct tags.
+func (c *Ctx) ParseQuery(v any) error {
+	return parseQuery(c.Request, v)
+}
+
+func (c *Ctx) URLParam(key string) string {
+	return chi.URLParam(c.Request, key)
+}
+
 func (c *Ctx) Cookies() []*http.Cookie {
 	if !c.cookiesParsed {
 		c.cookies = c.Request.Cookies()
@@ -154,6 +238,29 @@ func (c *Ctx) DelCookie(name string) {
 	})
 }
 
+// Returns the ip address of the client, without the port.
+func (c *Ctx) ClientIP() string {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return c.Request.RemoteAddr
+	}
+	return host
+}
+
+func (c *Ctx) SessionMetadata() dto.SessionMetadata {
+	return dto.SessionMetadata{
+		UserAgent: c.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// Reports whether json responses are preferred by the client,
+// following the same content negotiation as Component.
+func (c *Ctx) AcceptsJSON() bool {
+	isJson, _ := negotiate(c)
+	return isJson
+}
+
 func (c *Ctx) IsHtmx() bool {
 	return c.GetHeader("HX-Request") == "true"
 }
@@ -167,55 +274,137 @@ func (c *Ctx) Redirect(url string) {
 	}
 }
 
-// The token can be nil
+// Redirects with a 301 status, as the resource moved for good.
+func (c *Ctx) RedirectPermanent(url string) {
+	http.Redirect(c, c.Request, url, http.StatusMovedPermanently)
+}
+
+// The token is nil if there is no authentication or it is invalid.
 func (c *Ctx) GetAuth() (*dto.AuthToken, error) {
 	if c.authParsed {
 		return c.auth, nil
 	}
 
+	if bearer, ok := c.bearerToken(); ok {
+		if dto.IsAccessToken(bearer) {
+			return c.accessTokenAuth(bearer)
+		}
+
+		token, err := c.authr.DecodeToken(c.Context(), bearer)
+		if err != nil {
+			// The claims of invalid tokens must never be trusted
+			return nil, err
+		}
+
+		c.auth = &token
+		c.authParsed = true
+		return &token, nil
+	}
+
+	refreshToken := c.GetCookie("refresh_token")
+
 	authToken := c.GetCookie("auth_token")
 	if authToken == nil {
-		refreshToken := c.GetCookie("refresh_token")
 		if refreshToken == nil {
 			c.authParsed = true
 			return nil, nil
 		}
+		return c.refreshAuth(refreshToken.Value)
+	}
 
-		userId, err := c.authr.ValidateRefreshToken(
-			c.Context(),
-			refreshToken.Value,
-		)
-		if err != nil {
-			return nil, err
+	token, err := c.authr.DecodeToken(c.Context(), authToken.Value)
+	if err != nil {
+		if refreshToken != nil && errors.Is(err, repository.ErrRevokedAuthToken) {
+			// Gets a token with up to date claims if the session is still valid
+			return c.refreshAuth(refreshToken.Value)
 		}
+		return nil, err
+	}
 
-		user, err := c.users.GetById(c.Context(), userId)
-		if err != nil {
-			return nil, err
-		}
+	c.auth = &token
+	c.authParsed = true
+	return &token, nil
+}
 
-		token := dto.NewAuthToken(&user, "", c.cfg.JWT.GetDuration())
-		authToken, err := c.authr.EncodeToken(token)
-		if err != nil {
-			return nil, err
-		}
+// Returns the token of the `Authorization: Bearer` header, if present.
+func (c *Ctx) bearerToken() (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
 
-		c.auth = &token
-		c.authParsed = true
+// Authenticates a personal access token, granting the permissions
+// of its scope the user still has.
+func (c *Ctx) accessTokenAuth(bearer string) (*dto.AuthToken, error) {
+	accessToken, err := c.tokens.Authenticate(c.Context(), bearer)
+	if err != nil {
+		return nil, err
+	}
 
-		c.SetCookie(&http.Cookie{
-			Name:    "auth_token",
-			Value:   authToken,
-			Path:    "/",
-			Expires: token.ExpiresAt.Time,
-		})
-		return &token, nil
+	user, err := c.users.GetById(c.Context(), accessToken.UserID)
+	if err != nil {
+		return nil, err
+	} else if user.Banned() {
+		return nil, repository.ErrUserBanned
 	}
 
-	token, err := c.authr.DecodeToken(authToken.Value)
-	if err == nil {
-		c.auth = &token
-		c.authParsed = true
+	exp := c.cfg.JWT.GetDuration()
+	if accessToken.ExpiresAt != nil {
+		exp = time.Until(accessToken.ExpiresAt.Time)
+	}
+
+	token := dto.NewAuthToken(&user, "", exp)
+	token.TokenID = accessToken.ID
+	token.Permission &= accessToken.Permission
+
+	c.auth = &token
+	c.authParsed = true
+	return &token, nil
+}
+
+// Issues a new auth token from the refresh token, rotating it.
+func (c *Ctx) refreshAuth(refreshToken string) (*dto.AuthToken, error) {
+	newRefreshToken, session, err := c.authr.RotateRefreshToken(
+		c.Context(),
+		refreshToken,
+		c.SessionMetadata(),
+	)
+	if err != nil {
+		return nil, err
 	}
-	return &token, err
+
+	user, err := c.users.GetById(c.Context(), session.UserID)
+	if err != nil {
+		return nil, err
+	} else if user.Banned() {
+		return nil, repository.ErrUserBanned
+	}
+
+	token := dto.NewAuthToken(&user, "", c.cfg.JWT.GetDuration())
+	token.SessionID = session.ID
+	authToken, err := c.authr.EncodeToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	c.auth = &token
+	c.authParsed = true
+
+	// Empty if the token was rotated by a concurrent request
+	if newRefreshToken != "" {
+		c.SetCookie(&http.Cookie{
+			Name:  "refresh_token",
+			Value: newRefreshToken,
+			Path:  "/",
+		})
+	}
+	c.SetCookie(&http.Cookie{
+		Name:    "auth_token",
+		Value:   authToken,
+		Path:    "/",
+		Expires: token.ExpiresAt.Time,
+	})
+	return &token, nil
 }
@@ -2,6 +2,7 @@ package xhttp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -29,8 +30,16 @@ func Component[T any](
 	return handler(c, cf, v, code, false)
 }
 
+// Encodes v as json regardless of the Accept header, for endpoints
+// consumed by other services.
+func JSON(c *Ctx, v any, code int) error {
+	c.Header().Set("Content-Type", "application/json")
+	return encodeJson(c, v, code)
+}
+
 func Error(c *Ctx, p templates.PageData[error]) {
 	errd := errutils.Http(p.Data)
+	ErrorHeaders(c, errd)
 	data := templates.ErrorData{
 		Code:       errd.ErrorCode(),
 		HttpStatus: errd.HttpStatus(),
@@ -51,6 +60,20 @@ func Error(c *Ctx, p templates.PageData[error]) {
 	handler(c, templates.ErrorPage, p2, data.HttpStatus, true)
 }
 
+// Sets the headers carried by the error, if any.
+func ErrorHeaders(c *Ctx, err error) {
+	var herr errutils.HttpHeaderError
+	if !errors.As(err, &herr) {
+		return
+	}
+
+	for key, values := range herr.Header() {
+		for _, value := range values {
+			c.AddHeader(key, value)
+		}
+	}
+}
+
 func handler[T any](
 	c *Ctx,
 	cf ComponentFunc[T],
@@ -58,18 +81,12 @@ func handler[T any](
 	code int,
 	ignoreparsing bool,
 ) error {
-	mt, _, err := contenttype.GetAcceptableMediaType(c.Request, mediaTypes)
+	isJson, err := negotiate(c)
 	if err != nil && !ignoreparsing {
-		return errutils.NewHttp(
-			fmt.Errorf("content negotiation: parse Accept header: %s", err),
-			http.StatusBadRequest,
-			0,
-			true,
-		)
+		return err
 	}
 
-	isWildcard := mt.Type == `*` && mt.Subtype == `*`
-	if !isWildcard && mt.Matches(ctypeJson) {
+	if isJson {
 		if err = encodeJson(c, v, code); err != nil {
 			return fmt.Errorf("encode json response: %s", err)
 		}
@@ -84,6 +101,22 @@ func handler[T any](
 	return nil
 }
 
+// Reports whether the client prefers json over html responses.
+func negotiate(c *Ctx) (bool, error) {
+	mt, _, err := contenttype.GetAcceptableMediaType(c.Request, mediaTypes)
+	if err != nil {
+		return false, errutils.NewHttp(
+			fmt.Errorf("content negotiation: parse Accept header: %s", err),
+			http.StatusBadRequest,
+			0,
+			true,
+		)
+	}
+
+	isWildcard := mt.Type == `*` && mt.Subtype == `*`
+	return !isWildcard && mt.Matches(ctypeJson), nil
+}
+
 func encodeTemplate(
 	c templ.Component,
 	w http.ResponseWriter,
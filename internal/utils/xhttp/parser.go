@@ -14,8 +14,21 @@ import (
 	"github.com/zanz1n/blog/internal/utils/errutils"
 )
 
+const (
+	// Max size of a multipart request body.
+	MaxMultipartSize = 8 << 20
+	// Max size of a multipart request kept in memory,
+	// the rest is stored in temporary files.
+	maxMultipartMemory = 2 << 20
+)
+
 var validate = validator.New()
 var schemaDecoder = schema.NewDecoder()
+var queryDecoder = schema.NewDecoder()
+
+// File fields sent without a file name end up among the values of
+// multipart forms, so unknown keys are only ignored there.
+var multipartDecoder = schema.NewDecoder()
 
 func init() {
 	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
@@ -24,6 +37,9 @@ func init() {
 		return s
 	})
 	schemaDecoder.SetAliasTag("json")
+	multipartDecoder.SetAliasTag("json")
+	multipartDecoder.IgnoreUnknownKeys(true)
+	queryDecoder.IgnoreUnknownKeys(true)
 }
 
 func parse(req *http.Request, v any) error {
@@ -58,6 +74,16 @@ func parse(req *http.Request, v any) error {
 			)
 		}
 
+	case "multipart/form-data":
+		if err = parseMultipartReq(v, req); err != nil {
+			return errutils.NewHttp(
+				fmt.Errorf("parse request: multipart: %s", err),
+				http.StatusUnprocessableEntity,
+				http.StatusUnprocessableEntity,
+				true,
+			)
+		}
+
 	default:
 		return errutils.NewHttp(
 			fmt.Errorf("parse request: invalid content type: %s", ct),
@@ -74,6 +100,24 @@ func parse(req *http.Request, v any) error {
 	return nil
 }
 
+func parseQuery(req *http.Request, v any) error {
+	err := queryDecoder.Decode(v, req.URL.Query())
+	if err != nil {
+		return errutils.NewHttp(
+			fmt.Errorf("parse request: query: %s", err),
+			http.StatusBadRequest,
+			http.StatusBadRequest,
+			true,
+		)
+	}
+
+	if err = validate.StructCtx(req.Context(), v); err != nil {
+		return convertValidateError(err)
+	}
+
+	return nil
+}
+
 func Parse[T any](req *http.Request) (T, error) {
 	var value T
 	return value, parse(req, value)
@@ -87,6 +131,16 @@ func parseFormReq(dst any, req *http.Request) (err error) {
 	return
 }
 
+func parseMultipartReq(dst any, req *http.Request) (err error) {
+	if req.MultipartForm == nil {
+		if err = req.ParseMultipartForm(maxMultipartMemory); err != nil {
+			return
+		}
+	}
+	err = multipartDecoder.Decode(dst, req.MultipartForm.Value)
+	return
+}
+
 func convertValidateError(err error) error {
 	errs, ok := err.(validator.ValidationErrors)
 	if !ok {
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE comments (
+    id bigint PRIMARY KEY,
+    created_at bigint NOT NULL,
+    updated_at bigint NOT NULL,
+    article_id bigint NOT NULL,
+    user_id bigint NOT NULL DEFAULT 0,
+    content text NOT NULL,
+    hidden boolean NOT NULL DEFAULT false
+);
+
+ALTER TABLE comments ADD CONSTRAINT comments_article_id_fkey
+FOREIGN KEY (article_id) REFERENCES articles(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE comments ADD CONSTRAINT comments_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id)
+ON DELETE SET DEFAULT ON UPDATE CASCADE;
+
+CREATE INDEX comments_article_id_idx ON comments(article_id);
+CREATE INDEX comments_user_id_idx ON comments(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS comments;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE comments ADD COLUMN parent_id bigint;
+ALTER TABLE comments ADD COLUMN depth integer NOT NULL DEFAULT 0;
+
+ALTER TABLE comments ADD CONSTRAINT comments_parent_id_fkey
+FOREIGN KEY (parent_id) REFERENCES comments(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE INDEX comments_parent_id_idx ON comments(parent_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP INDEX IF EXISTS comments_parent_id_idx;
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS comments_parent_id_fkey;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS depth;
+ALTER TABLE comments DROP COLUMN IF EXISTS parent_id;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE access_tokens (
+    id bigint PRIMARY KEY,
+    created_at bigint NOT NULL,
+    user_id bigint NOT NULL,
+    name varchar(64) NOT NULL,
+    permission integer NOT NULL,
+    expires_at bigint,
+    token_hash bytea NOT NULL
+);
+
+ALTER TABLE access_tokens ADD CONSTRAINT access_tokens_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE INDEX access_tokens_user_id_idx ON access_tokens(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS access_tokens;
+-- +goose StatementEnd
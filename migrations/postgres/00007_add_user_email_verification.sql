@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE users ADD COLUMN email_verified_at bigint;
+
+-- Accounts created before email verification are trusted
+UPDATE users SET email_verified_at = created_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE users DROP COLUMN email_verified_at;
+-- +goose StatementEnd
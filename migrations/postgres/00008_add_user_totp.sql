@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE user_totp (
+    user_id bigint PRIMARY KEY,
+    created_at bigint NOT NULL,
+    secret varchar(64) NOT NULL,
+    enabled_at bigint,
+    last_step bigint NOT NULL,
+    recovery_codes bytea NOT NULL
+);
+
+ALTER TABLE user_totp ADD CONSTRAINT user_totp_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS user_totp;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE passkeys (
+    id bigint PRIMARY KEY,
+    created_at bigint NOT NULL,
+    user_id bigint NOT NULL,
+    name varchar(64) NOT NULL,
+    last_used_at bigint,
+    credential_id bytea NOT NULL,
+    public_key bytea NOT NULL,
+    attestation_type varchar(32) NOT NULL,
+    transports varchar(128) NOT NULL,
+    aaguid bytea NOT NULL,
+    sign_count bigint NOT NULL,
+    flags smallint NOT NULL
+);
+
+ALTER TABLE passkeys ADD CONSTRAINT passkeys_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE UNIQUE INDEX passkeys_credential_id_idx ON passkeys(credential_id);
+CREATE INDEX passkeys_user_id_idx ON passkeys(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS passkeys;
+-- +goose StatementEnd
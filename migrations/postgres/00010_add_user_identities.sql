@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE user_identities (
+    id bigint PRIMARY KEY,
+    created_at bigint NOT NULL,
+    user_id bigint NOT NULL,
+    issuer varchar(256) NOT NULL,
+    subject varchar(256) NOT NULL,
+    email varchar(128) NOT NULL
+);
+
+ALTER TABLE user_identities ADD CONSTRAINT user_identities_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE UNIQUE INDEX user_identities_subject_idx ON user_identities(issuer, subject);
+CREATE INDEX user_identities_user_id_idx ON user_identities(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS user_identities;
+-- +goose StatementEnd
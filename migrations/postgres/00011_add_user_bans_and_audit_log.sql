@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE users ADD COLUMN banned_at bigint;
+
+CREATE TABLE audit_log (
+    id bigint PRIMARY KEY,
+    created_at bigint NOT NULL,
+    actor_id bigint,
+    action varchar(64) NOT NULL,
+    target_id bigint NOT NULL,
+    data text NOT NULL
+);
+
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_actor_id_fkey
+FOREIGN KEY (actor_id) REFERENCES users(id)
+ON DELETE SET NULL ON UPDATE CASCADE;
+
+CREATE INDEX audit_log_target_id_idx ON audit_log(target_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS audit_log;
+
+ALTER TABLE users DROP COLUMN banned_at;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE articles ADD COLUMN status varchar(16) NOT NULL DEFAULT 'published';
+ALTER TABLE articles ADD COLUMN publish_at bigint;
+
+UPDATE articles SET publish_at = created_at;
+
+CREATE INDEX articles_status_publish_at_idx ON articles(status, publish_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP INDEX IF EXISTS articles_status_publish_at_idx;
+
+ALTER TABLE articles DROP COLUMN publish_at;
+ALTER TABLE articles DROP COLUMN status;
+-- +goose StatementEnd
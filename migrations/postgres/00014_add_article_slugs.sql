@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE articles ADD COLUMN slug varchar(128);
+
+-- The existing articles keep being addressable by their ids
+UPDATE articles SET slug = CAST(id AS text);
+
+ALTER TABLE articles ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX articles_slug_idx ON articles(slug);
+
+-- Previous slugs of the articles, redirected to the current ones
+CREATE TABLE article_slugs (
+    slug varchar(128) PRIMARY KEY,
+    created_at bigint NOT NULL,
+    article_id bigint NOT NULL
+);
+
+ALTER TABLE article_slugs ADD CONSTRAINT article_slugs_article_id_fkey
+FOREIGN KEY (article_id) REFERENCES articles(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE INDEX article_slugs_article_id_idx ON article_slugs(article_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS article_slugs;
+
+DROP INDEX IF EXISTS articles_slug_idx;
+
+ALTER TABLE articles DROP COLUMN slug;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE tags (
+    id bigint PRIMARY KEY,
+    created_at bigint NOT NULL,
+    name varchar(64) NOT NULL,
+    slug varchar(128) NOT NULL
+);
+
+CREATE UNIQUE INDEX tags_slug_idx ON tags(slug);
+
+CREATE TABLE article_tags (
+    article_id bigint NOT NULL,
+    tag_id bigint NOT NULL,
+
+    PRIMARY KEY (article_id, tag_id)
+);
+
+ALTER TABLE article_tags ADD CONSTRAINT article_tags_article_id_fkey
+FOREIGN KEY (article_id) REFERENCES articles(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE article_tags ADD CONSTRAINT article_tags_tag_id_fkey
+FOREIGN KEY (tag_id) REFERENCES tags(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE INDEX article_tags_tag_id_idx ON article_tags(tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS article_tags;
+DROP TABLE IF EXISTS tags;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE articles ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+    setweight(to_tsvector('english', raw_content), 'C')
+) STORED;
+
+CREATE INDEX articles_search_idx ON articles USING GIN (search);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP INDEX IF EXISTS articles_search_idx;
+
+ALTER TABLE articles DROP COLUMN search;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE articles ALTER COLUMN user_id DROP DEFAULT;
+ALTER TABLE articles DROP CONSTRAINT articles_user_id_fkey;
+ALTER TABLE articles ADD CONSTRAINT articles_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE comments ALTER COLUMN user_id DROP DEFAULT;
+ALTER TABLE comments DROP CONSTRAINT comments_user_id_fkey;
+ALTER TABLE comments ADD CONSTRAINT comments_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id)
+ON DELETE CASCADE ON UPDATE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE comments DROP CONSTRAINT comments_user_id_fkey;
+ALTER TABLE comments ADD CONSTRAINT comments_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id)
+ON DELETE SET DEFAULT ON UPDATE CASCADE;
+ALTER TABLE comments ALTER COLUMN user_id SET DEFAULT 0;
+
+ALTER TABLE articles DROP CONSTRAINT articles_user_id_fkey;
+ALTER TABLE articles ADD CONSTRAINT articles_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id)
+ON DELETE SET DEFAULT ON UPDATE CASCADE;
+ALTER TABLE articles ALTER COLUMN user_id SET DEFAULT 0;
+-- +goose StatementEnd
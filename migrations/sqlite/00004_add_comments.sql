@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE comments (
+    id integer PRIMARY KEY,
+    created_at integer NOT NULL,
+    updated_at integer NOT NULL,
+    article_id integer NOT NULL,
+    user_id integer NOT NULL DEFAULT 0,
+    content text NOT NULL,
+    hidden integer NOT NULL DEFAULT 0,
+
+    FOREIGN KEY (article_id) REFERENCES articles(id)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE SET DEFAULT ON UPDATE CASCADE
+) STRICT;
+
+CREATE INDEX comments_article_id_idx ON comments(article_id);
+CREATE INDEX comments_user_id_idx ON comments(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS comments;
+-- +goose StatementEnd
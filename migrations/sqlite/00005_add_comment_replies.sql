@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE comments ADD COLUMN parent_id integer
+    REFERENCES comments(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE comments ADD COLUMN depth integer NOT NULL DEFAULT 0;
+
+CREATE INDEX comments_parent_id_idx ON comments(parent_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+-- Columns referenced by foreign keys can not be dropped in sqlite,
+-- so the table needs to be rebuilt.
+CREATE TABLE comments_old (
+    id integer PRIMARY KEY,
+    created_at integer NOT NULL,
+    updated_at integer NOT NULL,
+    article_id integer NOT NULL,
+    user_id integer NOT NULL DEFAULT 0,
+    content text NOT NULL,
+    hidden integer NOT NULL DEFAULT 0,
+
+    FOREIGN KEY (article_id) REFERENCES articles(id)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE SET DEFAULT ON UPDATE CASCADE
+) STRICT;
+
+INSERT INTO comments_old
+SELECT id, created_at, updated_at, article_id, user_id, content, hidden
+FROM comments;
+
+DROP TABLE comments;
+ALTER TABLE comments_old RENAME TO comments;
+
+CREATE INDEX comments_article_id_idx ON comments(article_id);
+CREATE INDEX comments_user_id_idx ON comments(user_id);
+-- +goose StatementEnd
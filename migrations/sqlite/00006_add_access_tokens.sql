@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE access_tokens (
+    id integer PRIMARY KEY,
+    created_at integer NOT NULL,
+    user_id integer NOT NULL,
+    name text NOT NULL,
+    permission integer NOT NULL,
+    expires_at integer,
+    token_hash blob NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) STRICT;
+
+CREATE INDEX access_tokens_user_id_idx ON access_tokens(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS access_tokens;
+-- +goose StatementEnd
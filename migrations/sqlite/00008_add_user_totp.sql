@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE user_totp (
+    user_id integer PRIMARY KEY,
+    created_at integer NOT NULL,
+    secret text NOT NULL,
+    enabled_at integer,
+    last_step integer NOT NULL,
+    recovery_codes blob NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) STRICT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS user_totp;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE passkeys (
+    id integer PRIMARY KEY,
+    created_at integer NOT NULL,
+    user_id integer NOT NULL,
+    name text NOT NULL,
+    last_used_at integer,
+    credential_id blob NOT NULL,
+    public_key blob NOT NULL,
+    attestation_type text NOT NULL,
+    transports text NOT NULL,
+    aaguid blob NOT NULL,
+    sign_count integer NOT NULL,
+    flags integer NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) STRICT;
+
+CREATE UNIQUE INDEX passkeys_credential_id_idx ON passkeys(credential_id);
+CREATE INDEX passkeys_user_id_idx ON passkeys(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS passkeys;
+-- +goose StatementEnd
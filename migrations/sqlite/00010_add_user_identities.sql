@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE user_identities (
+    id integer PRIMARY KEY,
+    created_at integer NOT NULL,
+    user_id integer NOT NULL,
+    issuer text NOT NULL,
+    subject text NOT NULL,
+    email text NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) STRICT;
+
+CREATE UNIQUE INDEX user_identities_subject_idx ON user_identities(issuer, subject);
+CREATE INDEX user_identities_user_id_idx ON user_identities(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS user_identities;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE users ADD COLUMN banned_at integer;
+
+CREATE TABLE audit_log (
+    id integer PRIMARY KEY,
+    created_at integer NOT NULL,
+    actor_id integer,
+    action text NOT NULL,
+    target_id integer NOT NULL,
+    data text NOT NULL,
+
+    FOREIGN KEY (actor_id) REFERENCES users(id)
+        ON DELETE SET NULL ON UPDATE CASCADE
+) STRICT;
+
+CREATE INDEX audit_log_target_id_idx ON audit_log(target_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS audit_log;
+
+ALTER TABLE users DROP COLUMN banned_at;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE article_revisions (
+    id integer PRIMARY KEY,
+    created_at integer NOT NULL,
+    article_id integer NOT NULL,
+    user_id integer,
+    raw_content text NOT NULL,
+
+    FOREIGN KEY (article_id) REFERENCES articles(id)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE SET NULL ON UPDATE CASCADE
+) STRICT;
+
+CREATE INDEX article_revisions_article_id_idx ON article_revisions(article_id);
+
+-- The current content of the existing articles becomes their first revision
+INSERT INTO article_revisions (id, created_at, article_id, user_id, raw_content)
+SELECT id, updated_at, id, user_id, raw_content FROM articles;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS article_revisions;
+-- +goose StatementEnd
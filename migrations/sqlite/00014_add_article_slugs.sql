@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE articles ADD COLUMN slug text NOT NULL DEFAULT '';
+
+-- The existing articles keep being addressable by their ids
+UPDATE articles SET slug = CAST(id AS text);
+
+CREATE UNIQUE INDEX articles_slug_idx ON articles(slug);
+
+-- Previous slugs of the articles, redirected to the current ones
+CREATE TABLE article_slugs (
+    slug text PRIMARY KEY,
+    created_at integer NOT NULL,
+    article_id integer NOT NULL,
+
+    FOREIGN KEY (article_id) REFERENCES articles(id)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) STRICT;
+
+CREATE INDEX article_slugs_article_id_idx ON article_slugs(article_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS article_slugs;
+
+DROP INDEX IF EXISTS articles_slug_idx;
+
+ALTER TABLE articles DROP COLUMN slug;
+-- +goose StatementEnd
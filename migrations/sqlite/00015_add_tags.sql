@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE TABLE tags (
+    id integer PRIMARY KEY,
+    created_at integer NOT NULL,
+    name text NOT NULL,
+    slug text NOT NULL
+) STRICT;
+
+CREATE UNIQUE INDEX tags_slug_idx ON tags(slug);
+
+CREATE TABLE article_tags (
+    article_id integer NOT NULL,
+    tag_id integer NOT NULL,
+
+    PRIMARY KEY (article_id, tag_id),
+
+    FOREIGN KEY (article_id) REFERENCES articles(id)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) STRICT;
+
+CREATE INDEX article_tags_tag_id_idx ON article_tags(tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS article_tags;
+DROP TABLE IF EXISTS tags;
+-- +goose StatementEnd
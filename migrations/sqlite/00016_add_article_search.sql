@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+CREATE VIRTUAL TABLE articles_fts USING fts5(
+    title,
+    description,
+    raw_content,
+    content = 'articles',
+    content_rowid = 'id',
+    tokenize = 'porter unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER articles_fts_insert AFTER INSERT ON articles BEGIN
+    INSERT INTO articles_fts (rowid, title, description, raw_content)
+    VALUES (new.id, new.title, new.description, new.raw_content);
+END;
+
+CREATE TRIGGER articles_fts_delete AFTER DELETE ON articles BEGIN
+    INSERT INTO articles_fts (articles_fts, rowid, title, description, raw_content)
+    VALUES ('delete', old.id, old.title, old.description, old.raw_content);
+END;
+
+CREATE TRIGGER articles_fts_update AFTER UPDATE OF title, description, raw_content ON articles BEGIN
+    INSERT INTO articles_fts (articles_fts, rowid, title, description, raw_content)
+    VALUES ('delete', old.id, old.title, old.description, old.raw_content);
+    INSERT INTO articles_fts (rowid, title, description, raw_content)
+    VALUES (new.id, new.title, new.description, new.raw_content);
+END;
+
+INSERT INTO articles_fts (articles_fts) VALUES ('rebuild');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TRIGGER IF EXISTS articles_fts_update;
+DROP TRIGGER IF EXISTS articles_fts_delete;
+DROP TRIGGER IF EXISTS articles_fts_insert;
+
+DROP TABLE IF EXISTS articles_fts;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- Foreign keys are not enforced on sqlite connections and can't be
+-- altered, so the deletion of the content of users is done by a trigger
+CREATE TRIGGER users_delete_content AFTER DELETE ON users BEGIN
+    DELETE FROM comments WHERE user_id = old.id OR article_id IN (
+        SELECT id FROM articles WHERE user_id = old.id
+    );
+    DELETE FROM articles WHERE user_id = old.id;
+
+    DELETE FROM access_tokens WHERE user_id = old.id;
+    DELETE FROM user_totp WHERE user_id = old.id;
+    DELETE FROM passkeys WHERE user_id = old.id;
+    DELETE FROM user_identities WHERE user_id = old.id;
+
+    UPDATE article_revisions SET user_id = NULL WHERE user_id = old.id;
+    UPDATE audit_log SET actor_id = NULL WHERE actor_id = old.id;
+END;
+
+-- Clears the rows left behind by deletions before this migration
+DELETE FROM access_tokens WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM user_totp WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM passkeys WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM user_identities WHERE user_id NOT IN (SELECT id FROM users);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TRIGGER IF EXISTS users_delete_content;
+-- +goose StatementEnd
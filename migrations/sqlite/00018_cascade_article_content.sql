@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- Foreign keys are not enforced on sqlite connections, so the cascades
+-- of the tables that reference articles and comments are done by triggers
+CREATE TRIGGER articles_delete_content AFTER DELETE ON articles BEGIN
+    DELETE FROM comments WHERE article_id = old.id;
+    DELETE FROM article_revisions WHERE article_id = old.id;
+    DELETE FROM article_slugs WHERE article_id = old.id;
+    DELETE FROM article_tags WHERE article_id = old.id;
+END;
+
+CREATE TRIGGER comments_delete_replies AFTER DELETE ON comments BEGIN
+    DELETE FROM comments WHERE id IN (
+        WITH RECURSIVE replies(id) AS (
+            SELECT id FROM comments WHERE parent_id = old.id
+            UNION ALL
+            SELECT c.id FROM comments c JOIN replies r ON c.parent_id = r.id
+        )
+        SELECT id FROM replies
+    );
+END;
+
+CREATE TRIGGER tags_delete_articles AFTER DELETE ON tags BEGIN
+    DELETE FROM article_tags WHERE tag_id = old.id;
+END;
+
+-- Clears the rows left behind by deletions before this migration
+DELETE FROM comments WHERE article_id NOT IN (SELECT id FROM articles);
+DELETE FROM comments WHERE parent_id IS NOT NULL
+    AND parent_id NOT IN (SELECT id FROM comments);
+DELETE FROM article_revisions WHERE article_id NOT IN (SELECT id FROM articles);
+DELETE FROM article_slugs WHERE article_id NOT IN (SELECT id FROM articles);
+DELETE FROM article_tags WHERE article_id NOT IN (SELECT id FROM articles)
+    OR tag_id NOT IN (SELECT id FROM tags);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TRIGGER IF EXISTS tags_delete_articles;
+DROP TRIGGER IF EXISTS comments_delete_replies;
+DROP TRIGGER IF EXISTS articles_delete_content;
+-- +goose StatementEnd
@@ -0,0 +1,61 @@
+import {
+    create,
+    CreationOptions,
+    get,
+    post,
+    RequestOptions,
+    supported,
+} from "@lib/passkey";
+
+function showError(el: Element, err: unknown) {
+    const msg = err instanceof Error ? err.message : String(err);
+
+    const p = el.querySelector(".form-error");
+    if (p) {
+        p.textContent = msg;
+        p.classList.remove("invisible");
+    }
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+    const login = document.getElementById("passkey-login");
+    const register = document.getElementById(
+        "passkey-register",
+    ) as HTMLFormElement | null;
+
+    if (!supported()) {
+        login?.remove();
+        register?.remove();
+        return;
+    }
+
+    login?.querySelector("button")?.addEventListener("click", async () => {
+        try {
+            const options = await post<RequestOptions>("/auth/passkey/begin");
+            const assertion = await get(options);
+            await post("/auth/passkey", assertion);
+
+            window.location.href = "/";
+        } catch (err) {
+            showError(login, err);
+        }
+    });
+
+    register?.addEventListener("submit", async (e) => {
+        e.preventDefault();
+
+        try {
+            const options = await post<CreationOptions>("/profile/passkeys/begin");
+            const credential = await create(options);
+            await post("/profile/passkeys", {
+                name: new FormData(register).get("name"),
+                credential,
+            });
+
+            window.location.href = "/profile/settings#passkeys";
+            window.location.reload();
+        } catch (err) {
+            showError(register, err);
+        }
+    });
+});
@@ -0,0 +1,128 @@
+// The server encodes the binary fields of the webauthn options
+// and responses as unpadded base64url strings.
+
+export function decode(s: string): ArrayBuffer {
+    const b64 = s.replace(/-/g, "+").replace(/_/g, "/");
+    const bin = atob(b64.padEnd(b64.length + ((4 - (b64.length % 4)) % 4), "="));
+
+    const buf = new Uint8Array(bin.length);
+    for (let i = 0; i < bin.length; i++) {
+        buf[i] = bin.charCodeAt(i);
+    }
+    return buf.buffer;
+}
+
+export function encode(buf: ArrayBuffer | null): string | undefined {
+    if (!buf) {
+        return undefined;
+    }
+
+    let bin = "";
+    for (const b of new Uint8Array(buf)) {
+        bin += String.fromCharCode(b);
+    }
+    return btoa(bin).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+export function supported(): boolean {
+    return window.PublicKeyCredential !== undefined;
+}
+
+// Sends a json request, throwing the message of the error
+// response, if any.
+export async function post<T>(url: string, body?: unknown): Promise<T> {
+    const res = await fetch(url, {
+        method: "POST",
+        headers: {
+            Accept: "application/json",
+            "Content-Type": "application/json",
+        },
+        body: body === undefined ? undefined : JSON.stringify(body),
+    });
+
+    const data = await res.json().catch(() => null);
+    if (!res.ok) {
+        throw new Error(data?.message ?? res.statusText);
+    }
+    return data as T;
+}
+
+export type CreationOptions = {
+    publicKey: PublicKeyCredentialCreationOptions & {
+        challenge: string;
+        user: { id: string };
+        excludeCredentials?: { id: string }[];
+    };
+};
+
+export type RequestOptions = {
+    publicKey: PublicKeyCredentialRequestOptions & {
+        challenge: string;
+        allowCredentials?: { id: string }[];
+    };
+};
+
+// Runs `navigator.credentials.create()` with the options of the
+// server, returning the credential to be sent back to it.
+export async function create(options: CreationOptions) {
+    const publicKey = {
+        ...options.publicKey,
+        challenge: decode(options.publicKey.challenge),
+        user: {
+            ...options.publicKey.user,
+            id: decode(options.publicKey.user.id),
+        },
+        excludeCredentials: options.publicKey.excludeCredentials?.map((c) => ({
+            ...c,
+            id: decode(c.id),
+        })),
+    } as PublicKeyCredentialCreationOptions;
+
+    const cred = (await navigator.credentials.create({
+        publicKey,
+    })) as PublicKeyCredential;
+    const res = cred.response as AuthenticatorAttestationResponse;
+
+    return {
+        id: cred.id,
+        rawId: encode(cred.rawId),
+        type: cred.type,
+        authenticatorAttachment: cred.authenticatorAttachment,
+        response: {
+            clientDataJSON: encode(res.clientDataJSON),
+            attestationObject: encode(res.attestationObject),
+            transports: res.getTransports?.() ?? [],
+        },
+    };
+}
+
+// Runs `navigator.credentials.get()` with the options of the
+// server, returning the assertion to be sent back to it.
+export async function get(options: RequestOptions) {
+    const publicKey = {
+        ...options.publicKey,
+        challenge: decode(options.publicKey.challenge),
+        allowCredentials: options.publicKey.allowCredentials?.map((c) => ({
+            ...c,
+            id: decode(c.id),
+        })),
+    } as PublicKeyCredentialRequestOptions;
+
+    const cred = (await navigator.credentials.get({
+        publicKey,
+    })) as PublicKeyCredential;
+    const res = cred.response as AuthenticatorAssertionResponse;
+
+    return {
+        id: cred.id,
+        rawId: encode(cred.rawId),
+        type: cred.type,
+        authenticatorAttachment: cred.authenticatorAttachment,
+        response: {
+            clientDataJSON: encode(res.clientDataJSON),
+            authenticatorData: encode(res.authenticatorData),
+            signature: encode(res.signature),
+            userHandle: encode(res.userHandle),
+        },
+    };
+}
@@ -0,0 +1,9 @@
+package templates
+
+var oidcProvider string
+
+// Shows a button on the login page to sign in through the identity
+// provider with the given name. Empty hides it.
+func SetOIDCProvider(name string) {
+	oidcProvider = name
+}